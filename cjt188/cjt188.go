@@ -0,0 +1,611 @@
+/*
+ * @filename	cjt188.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Framing, decoder and client of CJ/T 188 household meter protocol
+ * @comment		frames are carried through MBRTGateway raw link on the same RS-485 bus as Modbus-RTU meters
+ */
+
+package cjt188
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+)
+
+// meter type identifiers
+const (
+	TYPE_WATER_COLD byte = 0x10
+	TYPE_WATER_HOT  byte = 0x11
+	TYPE_WATER_WARM byte = 0x12
+	TYPE_HEAT       byte = 0x20
+	TYPE_COLD       byte = 0x21
+	TYPE_GAS        byte = 0x30
+)
+
+// control codes of master requests, slave responses have bit 7 set, and bit 6 set on abnormal response
+const (
+	CTRL_READ      byte = 0x01
+	CTRL_READ_ADDR byte = 0x03
+	CTRL_WRITE     byte = 0x04
+)
+
+// control code flags of slave responses
+const (
+	CTRL_FLAG_RESPONSE byte = 0x80
+	CTRL_FLAG_ABNORMAL byte = 0x40
+)
+
+// data identifiers, DI0 in the low byte
+const (
+	DI_METER_DATA  uint16 = 0x901F
+	DI_VALVE_CTL   uint16 = 0xA017
+	DI_READ_ADDR   uint16 = 0x810A
+	DI_WRITE_ADDR  uint16 = 0xA018
+	DI_SETTLE_DATE uint16 = 0xA015
+)
+
+// valve commands of DI_VALVE_CTL
+const (
+	VALVE_CMD_OPEN  byte = 0x55
+	VALVE_CMD_CLOSE byte = 0x99
+)
+
+// valve status of status word, bits D0 - D1
+const (
+	VALVE_STAT_OPENED   uint8 = 0x00
+	VALVE_STAT_CLOSED   uint8 = 0x01
+	VALVE_STAT_ABNORMAL uint8 = 0x03
+)
+
+// bits of status word besides valve status
+const (
+	STATUS_VALVE_MASK  uint16 = 0x0003
+	STATUS_BATTERY_LOW uint16 = 0x0004
+)
+
+// counters of energy and volume are 4 BCD bytes with 2 decimals, wrapping at this value in the unit coded
+const COUNTER_SPAN float64 = 1e6
+
+// preamble sent before frames to wake up the receiver
+var Preamble = []byte{0xFE, 0xFE}
+
+// wildcard address, used to read address of the only meter on the bus
+var AddrWildcard = [7]byte{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}
+
+// frame of CJ/T 188
+type Frame struct {
+	// meter type, using macro TYPE_*
+	Type byte
+	// meter address, A0 (lowest 2 digits) first
+	Addr [7]byte
+	// control code
+	Ctrl byte
+	// data field
+	Data []byte
+}
+
+/*
+encode frame into bytes, without preamble
+
+# Params
+
+f Frame: frame to encode
+
+# Returns
+
+buf []byte: encoded frame
+*/
+func Encode(f Frame) (buf []byte) {
+	buf = make([]byte, 0, 13+len(f.Data))
+	buf = append(buf, 0x68, f.Type)
+	buf = append(buf, f.Addr[:]...)
+	buf = append(buf, f.Ctrl, byte(len(f.Data)))
+	buf = append(buf, f.Data...)
+	buf = append(buf, checksum(buf), 0x16)
+	return
+}
+
+/*
+decode bytes into frame, leading preamble bytes are skipped
+
+# Params
+
+buf []byte: frame bytes
+
+# Returns
+
+f Frame: decoded frame
+
+err error: error if the frame is malformed
+*/
+func Decode(buf []byte) (f Frame, err error) {
+	for len(buf) > 0 && buf[0] == 0xFE {
+		buf = buf[1:]
+	}
+	if len(buf) < 13 || buf[0] != 0x68 {
+		err = errors.New("cjt188 bad frame header")
+		return
+	}
+	l := int(buf[10])
+	if len(buf) < 13+l {
+		err = errors.New("cjt188 short frame")
+		return
+	}
+	if buf[12+l] != 0x16 {
+		err = errors.New("cjt188 bad frame tail")
+		return
+	}
+	if checksum(buf[:11+l]) != buf[11+l] {
+		err = errors.New("cjt188 bad checksum")
+		return
+	}
+	f.Type = buf[1]
+	copy(f.Addr[:], buf[2:9])
+	f.Ctrl = buf[9]
+	f.Data = append([]byte{}, buf[11:11+l]...)
+	return
+}
+
+/*
+read exactly one frame from link, for gateway.Transact
+
+# Params
+
+r io.Reader: link to read from
+
+# Returns
+
+buf []byte: frame bytes, without preamble
+
+err error: error
+*/
+func ReadFrame(r io.Reader) (buf []byte, err error) {
+	br := bufio.NewReader(r)
+	var b byte
+	for {
+		b, err = br.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == 0x68 {
+			break
+		}
+	}
+	head := make([]byte, 11)
+	head[0] = b
+	_, err = io.ReadFull(br, head[1:])
+	if err != nil {
+		return
+	}
+	rest := make([]byte, int(head[10])+2)
+	_, err = io.ReadFull(br, rest)
+	if err != nil {
+		return
+	}
+	buf = append(head, rest...)
+	return
+}
+
+func checksum(buf []byte) (cs byte) {
+	for _, b := range buf {
+		cs += b
+	}
+	return
+}
+
+/*
+parse meter address printed on nameplate, up to 14 decimal digits
+
+# Params
+
+s string: address such as "00000012345678", shorter ones are left padded with 0
+
+# Returns
+
+addr [7]byte: address in frame order, A0 first
+
+err error: error
+*/
+func ParseAddr(s string) (addr [7]byte, err error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || len(s) > 14 {
+		err = fmt.Errorf("invalid cjt188 address %q", s)
+		return
+	}
+	s = strings.Repeat("0", 14-len(s)) + s
+	for i := 0; i < 7; i++ {
+		hi, lo := s[12-2*i], s[13-2*i]
+		if hi < '0' || hi > '9' || lo < '0' || lo > '9' {
+			err = fmt.Errorf("invalid cjt188 address %q", s)
+			return
+		}
+		addr[i] = (hi-'0')<<4 | (lo - '0')
+	}
+	return
+}
+
+// format address into 14 decimal digits as printed on nameplate
+func FormatAddr(addr [7]byte) (s string) {
+	for i := 6; i >= 0; i-- {
+		s += fmt.Sprintf("%02x", addr[i])
+	}
+	return
+}
+
+// decode little-endian unsigned BCD value with decimals
+func decodeBCD(data []byte, decimals int) (val float64, err error) {
+	for i := len(data) - 1; i >= 0; i-- {
+		hi, lo := data[i]>>4, data[i]&0x0F
+		if hi > 9 || lo > 9 {
+			err = fmt.Errorf("cjt188 bad bcd byte 0x%02x", data[i])
+			return
+		}
+		val = val*100 + float64(hi)*10 + float64(lo)
+	}
+	val /= math.Pow10(decimals)
+	return
+}
+
+// decode real time in ssmmhhDDMMYYYY BCD, second first
+func decodeTime(data []byte) (t time.Time, err error) {
+	var v [7]int
+	for i := 0; i < 7; i++ {
+		hi, lo := data[i]>>4, data[i]&0x0F
+		if hi > 9 || lo > 9 {
+			err = fmt.Errorf("cjt188 bad bcd byte 0x%02x", data[i])
+			return
+		}
+		v[i] = int(hi)*10 + int(lo)
+	}
+	t = time.Date(v[6]*100+v[5], time.Month(v[4]), v[3], v[2], v[1], v[0], 0, time.Local)
+	return
+}
+
+/*
+get multiplier converting value of unit code into base unit of the driver, kWh for energy, kW for power, m^3 for volume and m^3/h for flow
+
+# Params
+
+unit byte: unit code following values in meter data
+
+# Returns
+
+mul float64: multiplier
+
+err error: error if unit code is unknown
+*/
+func UnitMultiplier(unit byte) (mul float64, err error) {
+	switch unit {
+	case 0x02: // Wh
+		mul = 0.001
+	case 0x05: // kWh
+		mul = 1
+	case 0x08: // MWh
+		mul = 1000
+	case 0x0A: // MWh x 100
+		mul = 100000
+	case 0x01: // J
+		mul = 1 / 3.6e6
+	case 0x0B: // kJ
+		mul = 1 / 3.6e3
+	case 0x0E: // MJ
+		mul = 1 / 3.6
+	case 0x11: // GJ
+		mul = 1e3 / 3.6
+	case 0x13: // GJ x 100
+		mul = 1e5 / 3.6
+	case 0x14: // W
+		mul = 0.001
+	case 0x17: // kW
+		mul = 1
+	case 0x1A: // MW
+		mul = 1000
+	case 0x29: // L
+		mul = 0.001
+	case 0x2C: // m^3
+		mul = 1
+	case 0x32: // L/h
+		mul = 0.001
+	case 0x35: // m^3/h
+		mul = 1
+	default:
+		err = fmt.Errorf("cjt188 unknown unit code 0x%02x", unit)
+	}
+	return
+}
+
+// value with unit code, 4 BCD bytes with 2 decimals followed by unit
+func decodeUnitValue(data []byte, decimals int) (val float64, err error) {
+	val, err = decodeBCD(data[:4], decimals)
+	if err != nil {
+		return
+	}
+	var mul float64
+	mul, err = UnitMultiplier(data[4])
+	if err != nil {
+		return
+	}
+	val *= mul
+	return
+}
+
+// metering data of water meters, answer of DI_METER_DATA
+type WaterData struct {
+	// current cumulative volume, in m^3
+	Volume float64
+	// cumulative volume on last settlement day, in m^3
+	SettleVolume float64
+	// real time of meter
+	Time time.Time
+	// status word, using macro STATUS_*
+	Status uint16
+}
+
+/*
+decode metering data of water meters
+
+# Params
+
+data []byte: data field of response, including DI and SER
+
+# Returns
+
+wd WaterData: metering data
+
+err error: error
+*/
+func DecodeWaterData(data []byte) (wd WaterData, err error) {
+	if len(data) < 3+5+5+7+2 {
+		err = fmt.Errorf("cjt188 short water meter data, %d bytes", len(data))
+		return
+	}
+	data = data[3:]
+	wd.Volume, err = decodeUnitValue(data[0:5], 2)
+	if err != nil {
+		return
+	}
+	wd.SettleVolume, err = decodeUnitValue(data[5:10], 2)
+	if err != nil {
+		return
+	}
+	wd.Time, err = decodeTime(data[10:17])
+	if err != nil {
+		return
+	}
+	wd.Status = uint16(data[17]) | uint16(data[18])<<8
+	return
+}
+
+// metering data of heat meters, answer of DI_METER_DATA
+type HeatData struct {
+	// heat energy on last settlement day, in kWh
+	SettleEnergy float64
+	// current heat energy, in kWh
+	Energy float64
+	// thermal power, in kW
+	Power float64
+	// flow, in m^3/h
+	Flow float64
+	// cumulative volume, in m^3
+	Volume float64
+	// supply water temperature, in degC
+	TempSupply float64
+	// return water temperature, in degC
+	TempReturn float64
+	// accumulated working time, in h
+	OperatingHours float64
+	// real time of meter
+	Time time.Time
+	// status word, using macro STATUS_*
+	Status uint16
+}
+
+/*
+decode metering data of heat meters
+
+# Params
+
+data []byte: data field of response, including DI and SER
+
+# Returns
+
+hd HeatData: metering data
+
+err error: error
+*/
+func DecodeHeatData(data []byte) (hd HeatData, err error) {
+	if len(data) < 3+5*5+3+3+3+7+2 {
+		err = fmt.Errorf("cjt188 short heat meter data, %d bytes", len(data))
+		return
+	}
+	data = data[3:]
+	fields := []*float64{&hd.SettleEnergy, &hd.Energy, &hd.Power, &hd.Flow, &hd.Volume}
+	decimals := []int{2, 2, 2, 4, 2}
+	for i, field := range fields {
+		*field, err = decodeUnitValue(data[i*5:i*5+5], decimals[i])
+		if err != nil {
+			return
+		}
+	}
+	data = data[25:]
+	hd.TempSupply, err = decodeBCD(data[0:3], 2)
+	if err != nil {
+		return
+	}
+	hd.TempReturn, err = decodeBCD(data[3:6], 2)
+	if err != nil {
+		return
+	}
+	hd.OperatingHours, err = decodeBCD(data[6:9], 0)
+	if err != nil {
+		return
+	}
+	hd.Time, err = decodeTime(data[9:16])
+	if err != nil {
+		return
+	}
+	hd.Status = uint16(data[16]) | uint16(data[17])<<8
+	return
+}
+
+// client of one CJ/T 188 meter behind a gateway
+type Client struct {
+	gateway   *gateway.MBRTGateway
+	meterType byte
+	addr      [7]byte
+	ser       byte
+	retries   int
+}
+
+/*
+initialize client instance
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, and needs to be initialized in advance
+
+meterType byte: meter type, using macro TYPE_*
+
+addr [7]byte: meter address, could be parsed by ParseAddr
+
+# Returns
+
+err error: error
+*/
+func (c *Client) Init(gw *gateway.MBRTGateway, meterType byte, addr [7]byte) (err error) {
+	if gw == nil {
+		err = errors.New("nil gateway")
+		return
+	}
+	c.gateway = gw
+	c.meterType = meterType
+	c.addr = addr
+	c.retries = 3
+	return
+}
+
+/*
+send request with data identifier and return the data field of normal response, with retries
+
+# Params
+
+ctrl byte: control code, using macro CTRL_*
+
+di uint16: data identifier, using macro DI_*
+
+payload []byte: data following DI and SER
+
+# Returns
+
+data []byte: data field of response, including DI and SER
+
+err error: error
+*/
+func (c *Client) Request(ctrl byte, di uint16, payload []byte) (data []byte, err error) {
+	if c.gateway == nil {
+		err = errors.New("cjt188 client not initialized")
+		return
+	}
+	c.ser++
+	reqData := append([]byte{byte(di), byte(di >> 8), c.ser}, payload...)
+	req := append(append([]byte{}, Preamble...), Encode(Frame{Type: c.meterType, Addr: c.addr, Ctrl: ctrl, Data: reqData})...)
+	var buf []byte
+	var resp Frame
+	for retry := c.retries; ; retry-- {
+		buf, err = c.gateway.Transact(req, ReadFrame)
+		if err == nil {
+			resp, err = Decode(buf)
+		}
+		if err == nil {
+			break
+		}
+		if retry <= 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if resp.Ctrl&^CTRL_FLAG_ABNORMAL != ctrl|CTRL_FLAG_RESPONSE {
+		err = fmt.Errorf("cjt188 unexpected control code 0x%02x", resp.Ctrl)
+		return
+	}
+	if c.addr != AddrWildcard && resp.Addr != c.addr {
+		err = fmt.Errorf("cjt188 response from unexpected address %s", FormatAddr(resp.Addr))
+		return
+	}
+	if resp.Ctrl&CTRL_FLAG_ABNORMAL != 0 {
+		err = fmt.Errorf("cjt188 abnormal response 0x%02x, data % x", resp.Ctrl, resp.Data)
+		return
+	}
+	if len(resp.Data) < 3 || uint16(resp.Data[0])|uint16(resp.Data[1])<<8 != di {
+		err = errors.New("cjt188 response of unexpected data identifier")
+		return
+	}
+	data = resp.Data
+	return
+}
+
+/*
+read metering data of water meters
+
+# Returns
+
+wd WaterData: metering data
+
+err error: error
+*/
+func (c *Client) ReadWaterData() (wd WaterData, err error) {
+	var data []byte
+	data, err = c.Request(CTRL_READ, DI_METER_DATA, nil)
+	if err != nil {
+		return
+	}
+	wd, err = DecodeWaterData(data)
+	return
+}
+
+/*
+read metering data of heat meters
+
+# Returns
+
+hd HeatData: metering data
+
+err error: error
+*/
+func (c *Client) ReadHeatData() (hd HeatData, err error) {
+	var data []byte
+	data, err = c.Request(CTRL_READ, DI_METER_DATA, nil)
+	if err != nil {
+		return
+	}
+	hd, err = DecodeHeatData(data)
+	return
+}
+
+/*
+valve open or close command
+
+# Params
+
+open bool: true to open, false to close
+
+# Returns
+
+err error: error
+*/
+func (c *Client) SetValve(open bool) (err error) {
+	cmd := VALVE_CMD_CLOSE
+	if open {
+		cmd = VALVE_CMD_OPEN
+	}
+	_, err = c.Request(CTRL_WRITE, DI_VALVE_CTL, []byte{cmd})
+	return
+}
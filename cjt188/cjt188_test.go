@@ -0,0 +1,213 @@
+package cjt188
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+// read meter data request of cold water meter 12345678
+var (
+	frameReadWater = Frame{
+		Type: TYPE_WATER_COLD,
+		Addr: [7]byte{0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x00},
+		Ctrl: CTRL_READ,
+		Data: []byte{0x1F, 0x90, 0x00},
+	}
+	bytesReadWater = []byte{0x68, 0x10, 0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x00, 0x01, 0x03, 0x1F, 0x90, 0x00, 0x3F, 0x16}
+)
+
+func TestEncode(t *testing.T) {
+	if buf := Encode(frameReadWater); !bytes.Equal(buf, bytesReadWater) {
+		t.Fatalf("encoded % x, want % x", buf, bytesReadWater)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	corrupt := func(i int, b byte) []byte {
+		buf := append([]byte{}, bytesReadWater...)
+		buf[i] = b
+		return buf
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+		bad  bool
+	}{
+		{name: "frame", buf: bytesReadWater},
+		{name: "with preamble", buf: append(append([]byte{}, Preamble...), bytesReadWater...)},
+		{name: "trailing bytes", buf: append(append([]byte{}, bytesReadWater...), 0xFF)},
+		{name: "bad header", buf: corrupt(0, 0x69), bad: true},
+		{name: "short", buf: bytesReadWater[:12], bad: true},
+		{name: "length beyond frame", buf: corrupt(10, 0x04), bad: true},
+		{name: "bad tail", buf: corrupt(15, 0x17), bad: true},
+		{name: "bad checksum", buf: corrupt(14, 0x40), bad: true},
+		{name: "bad data", buf: corrupt(13, 0x01), bad: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Decode(tt.buf)
+			if tt.bad {
+				if err == nil {
+					t.Fatalf("decoded %+v, want error", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Type != frameReadWater.Type || f.Addr != frameReadWater.Addr || f.Ctrl != frameReadWater.Ctrl || !bytes.Equal(f.Data, frameReadWater.Data) {
+				t.Fatalf("decoded %+v, want %+v", f, frameReadWater)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	link := append([]byte{0xFE, 0xFE, 0x00}, bytesReadWater...)
+	link = append(link, 0xFE)
+	buf, err := ReadFrame(bytes.NewReader(link))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, bytesReadWater) {
+		t.Fatalf("read % x, want % x", buf, bytesReadWater)
+	}
+	if _, err = ReadFrame(bytes.NewReader(bytesReadWater[:14])); err == nil {
+		t.Fatal("no error reading truncated frame")
+	}
+}
+
+func TestAddr(t *testing.T) {
+	tests := []struct {
+		s    string
+		addr [7]byte
+		bad  bool
+	}{
+		{s: "00000012345678", addr: [7]byte{0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x00}},
+		{s: "12345678", addr: [7]byte{0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x00}},
+		{s: " 98765432109876 ", addr: [7]byte{0x76, 0x98, 0x10, 0x32, 0x54, 0x76, 0x98}},
+		{s: "", bad: true},
+		{s: "123456789012345", bad: true},
+		{s: "1234567A", bad: true},
+	}
+	for _, tt := range tests {
+		addr, err := ParseAddr(tt.s)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%q: parsed % x, want error", tt.s, addr)
+			}
+			continue
+		}
+		if err != nil || addr != tt.addr {
+			t.Errorf("%q: parsed % x, err %v, want % x", tt.s, addr, err, tt.addr)
+			continue
+		}
+		if s, _ := ParseAddr(FormatAddr(addr)); s != addr {
+			t.Errorf("%q: formatted %s does not parse back", tt.s, FormatAddr(addr))
+		}
+	}
+}
+
+func TestDecodeBCD(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		decimals int
+		val      float64
+		bad      bool
+	}{
+		{data: []byte{0x67, 0x45, 0x23, 0x01}, decimals: 2, val: 12345.67},
+		{data: []byte{0x67, 0x45, 0x23, 0x01}, decimals: 0, val: 1234567},
+		{data: []byte{0x00, 0x00, 0x00, 0x00}, decimals: 2, val: 0},
+		{data: []byte{0x99, 0x99}, decimals: 4, val: 0.9999},
+		{data: []byte{0x0A, 0x00}, bad: true},
+		{data: []byte{0x00, 0xA0}, bad: true},
+	}
+	for _, tt := range tests {
+		val, err := decodeBCD(tt.data, tt.decimals)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("% x: decoded %g, want error", tt.data, val)
+			}
+			continue
+		}
+		if err != nil || math.Abs(val-tt.val) > 1e-9 {
+			t.Errorf("% x: decoded %g, err %v, want %g", tt.data, val, err, tt.val)
+		}
+	}
+}
+
+// real time 2026-10-18 12:34:56 in ssmmhhDDMMYYYY
+var bytesTime = []byte{0x56, 0x34, 0x12, 0x18, 0x10, 0x26, 0x20}
+
+func TestDecodeWaterData(t *testing.T) {
+	data := []byte{0x1F, 0x90, 0x00}
+	// 12345.67 m^3
+	data = append(data, 0x67, 0x45, 0x23, 0x01, 0x2C)
+	// 10000.00 L
+	data = append(data, 0x00, 0x00, 0x00, 0x01, 0x29)
+	data = append(data, bytesTime...)
+	// valve closed, battery low
+	data = append(data, 0x05, 0x00)
+	wd, err := DecodeWaterData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(wd.Volume-12345.67) > 1e-9 || math.Abs(wd.SettleVolume-10) > 1e-9 {
+		t.Fatalf("volume %g, settle volume %g, want 12345.67 and 10", wd.Volume, wd.SettleVolume)
+	}
+	if want := time.Date(2026, 10, 18, 12, 34, 56, 0, time.Local); !wd.Time.Equal(want) {
+		t.Fatalf("time %s, want %s", wd.Time, want)
+	}
+	if uint8(wd.Status&STATUS_VALVE_MASK) != VALVE_STAT_CLOSED || wd.Status&STATUS_BATTERY_LOW == 0 {
+		t.Fatalf("status 0x%04x, want valve closed and battery low", wd.Status)
+	}
+	if _, err = DecodeWaterData(data[:len(data)-1]); err == nil {
+		t.Fatal("no error decoding short data")
+	}
+	data[7] = 0x00
+	if _, err = DecodeWaterData(data); err == nil {
+		t.Fatal("no error decoding unknown unit")
+	}
+}
+
+func TestDecodeHeatData(t *testing.T) {
+	data := []byte{0x1F, 0x90, 0x00}
+	// settlement energy 1.00 MWh, energy 1234.56 kWh, power 12.34 kW, flow 1.2345 m^3/h, volume 678.90 m^3
+	data = append(data, 0x00, 0x01, 0x00, 0x00, 0x08)
+	data = append(data, 0x56, 0x34, 0x12, 0x00, 0x05)
+	data = append(data, 0x34, 0x12, 0x00, 0x00, 0x17)
+	data = append(data, 0x45, 0x23, 0x01, 0x00, 0x35)
+	data = append(data, 0x90, 0x78, 0x06, 0x00, 0x2C)
+	// supply 75.50 degC, return 45.25 degC, 8760 h
+	data = append(data, 0x50, 0x75, 0x00, 0x25, 0x45, 0x00, 0x60, 0x87, 0x00)
+	data = append(data, bytesTime...)
+	data = append(data, 0x00, 0x00)
+	hd, err := DecodeHeatData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"settlement energy", hd.SettleEnergy, 1000},
+		{"energy", hd.Energy, 1234.56},
+		{"power", hd.Power, 12.34},
+		{"flow", hd.Flow, 1.2345},
+		{"volume", hd.Volume, 678.9},
+		{"supply temperature", hd.TempSupply, 75.5},
+		{"return temperature", hd.TempReturn, 45.25},
+		{"operating hours", hd.OperatingHours, 8760},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s %g, want %g", tt.name, tt.got, tt.want)
+		}
+	}
+	if uint8(hd.Status&STATUS_VALVE_MASK) != VALVE_STAT_OPENED {
+		t.Errorf("status 0x%04x, want valve opened", hd.Status)
+	}
+}
@@ -0,0 +1,296 @@
+/*
+ * @filename	consumption.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Consumption from successive cumulative readings, such as energy or volume
+ * @comment		handles counter wraparound by register width and meter replacement
+ */
+
+package consumption
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// cumulative reading of a meter
+type Reading struct {
+	// time of reading
+	Time time.Time
+	// cumulative value, such as energy in kWh or volume in m^3
+	Value float64
+}
+
+// usage over a period
+type Usage struct {
+	// start of period
+	Start time.Time
+	// end of period
+	End time.Time
+	// usage in unit of the cumulative value
+	Value float64
+	// if the counter wrapped in the period
+	Rollover bool
+	// if the counter went backwards or rose faster than MaxRate in the period
+	Implausible bool
+	// if a meter swap happened in the period
+	Swap bool
+}
+
+// configuration of consumption counter
+type Config struct {
+	// value at which the counter wraps back to 0, such as ItemCapability.Rollover, 0 if it never wraps
+	Rollover float64
+	// highest plausible usage per hour, 0 for no check
+	MaxRate float64
+	// number of usages kept, 10000 if 0
+	Retention int
+}
+
+// usage computed from successive cumulative readings of one item of one meter
+type Counter struct {
+	cfg     Config
+	last    Reading
+	started bool
+	usages  []Usage
+}
+
+/*
+build configuration of an item from meter capabilities, taking rollover from register width
+
+# Params
+
+caps meter.Capabilities: capabilities of meter instance, see GetCapabilities
+
+id uint8: item id of a cumulative item, such as watermeter.ID_VOLUME
+
+# Returns
+
+cfg Config: configuration with Rollover set
+
+err error: error if the item is not supported or not cumulative
+*/
+func ConfigOf(caps meter.Capabilities, id uint8) (cfg Config, err error) {
+	for _, item := range caps.Items {
+		if item.ID != id {
+			continue
+		}
+		if !meter.IsCumulative(item.Kind) {
+			err = fmt.Errorf("item %s of %s is not cumulative", item.Name, caps.ModelName)
+			return
+		}
+		cfg.Rollover = item.Rollover
+		return
+	}
+	err = fmt.Errorf("item %d is not supported by %s", id, caps.ModelName)
+	return
+}
+
+/*
+initialize counter with configuration
+
+# Params
+
+cfg Config: configuration, zero fields take defaults
+
+# Returns
+
+err error: error
+*/
+func (c *Counter) Init(cfg Config) (err error) {
+	if cfg.Rollover < 0 || cfg.MaxRate < 0 || cfg.Retention < 0 {
+		err = errors.New("negative consumption configuration")
+		return
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 10000
+	}
+	*c = Counter{cfg: cfg}
+	return
+}
+
+/*
+add a reading and get the usage since the previous one
+
+a drop of more than half the rollover is taken as wraparound, other drops are flagged implausible and the new reading becomes the baseline
+
+# Params
+
+r Reading: reading, later than the previous one
+
+# Returns
+
+u Usage: usage since the previous reading
+
+ok bool: false for the first reading, which only sets the baseline
+
+err error: error if the reading is not later than the previous one
+*/
+func (c *Counter) Add(r Reading) (u Usage, ok bool, err error) {
+	if c.cfg.Retention == 0 {
+		err = errors.New("consumption counter not initialized")
+		return
+	}
+	if !c.started {
+		c.started = true
+		c.last = r
+		return
+	}
+	if !r.Time.After(c.last.Time) {
+		err = fmt.Errorf("reading at %s is not later than the previous one at %s", r.Time.Format(time.DateTime), c.last.Time.Format(time.DateTime))
+		return
+	}
+	u = c.usage(c.last, r, c.cfg.Rollover)
+	c.last = r
+	c.append(u)
+	ok = true
+	return
+}
+
+/*
+record a meter swap, usage up to the final reading of the removed meter is kept and counting goes on from the new one
+
+# Params
+
+oldEnd Reading: final reading of the removed meter, not earlier than the previous reading
+
+newStart Reading: first reading of the new meter, not earlier than oldEnd
+
+rollover float64: rollover of the new meter, such as ItemCapability.Rollover, 0 if it never wraps
+
+# Returns
+
+u Usage: usage of the removed meter since the previous reading, flagged Swap
+
+err error: error if readings are out of order
+*/
+func (c *Counter) Swap(oldEnd Reading, newStart Reading, rollover float64) (u Usage, err error) {
+	if c.cfg.Retention == 0 {
+		err = errors.New("consumption counter not initialized")
+		return
+	}
+	if rollover < 0 {
+		err = errors.New("negative rollover of new meter")
+		return
+	}
+	if newStart.Time.Before(oldEnd.Time) {
+		err = errors.New("new meter starts before the removed one ends")
+		return
+	}
+	if c.started {
+		if oldEnd.Time.Before(c.last.Time) {
+			err = fmt.Errorf("removed meter ends at %s before the previous reading at %s", oldEnd.Time.Format(time.DateTime), c.last.Time.Format(time.DateTime))
+			return
+		}
+		u = c.usage(c.last, oldEnd, c.cfg.Rollover)
+	} else {
+		u = Usage{Start: oldEnd.Time, End: oldEnd.Time}
+	}
+	u.Swap = true
+	c.append(u)
+	c.cfg.Rollover = rollover
+	c.started = true
+	c.last = newStart
+	return
+}
+
+/*
+get total usage of a period, usages partly inside are apportioned by time
+
+# Params
+
+from time.Time: start of period
+
+to time.Time: end of period
+
+# Returns
+
+u Usage: total usage, with flags of any usage counted into it
+*/
+func (c *Counter) Sum(from time.Time, to time.Time) (u Usage) {
+	u.Start, u.End = from, to
+	for _, x := range c.usages {
+		var share float64
+		if span := x.End.Sub(x.Start); span > 0 {
+			lo, hi := x.Start, x.End
+			if lo.Before(from) {
+				lo = from
+			}
+			if hi.After(to) {
+				hi = to
+			}
+			if !hi.After(lo) {
+				continue
+			}
+			share = float64(hi.Sub(lo)) / float64(span)
+		} else {
+			// instant usage, such as swap without previous reading, counts where it happened
+			if x.End.Before(from) || !x.End.Before(to) {
+				continue
+			}
+			share = 1
+		}
+		u.Value += x.Value * share
+		u.Rollover = u.Rollover || x.Rollover
+		u.Implausible = u.Implausible || x.Implausible
+		u.Swap = u.Swap || x.Swap
+	}
+	return
+}
+
+/*
+get usages between successive readings, ordered by time
+
+# Returns
+
+usages []Usage: usages, at most Retention of them
+*/
+func (c *Counter) Usages() (usages []Usage) {
+	usages = append(usages, c.usages...)
+	return
+}
+
+/*
+get the last reading, which is the baseline of next usage
+
+# Returns
+
+r Reading: last reading
+
+ok bool: false if there is no reading yet
+*/
+func (c *Counter) Last() (r Reading, ok bool) {
+	r, ok = c.last, c.started
+	return
+}
+
+// usage between two readings of one meter
+func (c *Counter) usage(from Reading, to Reading, rollover float64) (u Usage) {
+	u = Usage{Start: from.Time, End: to.Time, Value: to.Value - from.Value}
+	if u.Value < 0 {
+		if rollover > 0 && -u.Value > rollover/2 {
+			u.Value += rollover
+			u.Rollover = true
+		} else {
+			// counter reset or read error, nothing can be told about usage
+			u.Value = 0
+			u.Implausible = true
+			return
+		}
+	}
+	if hours := to.Time.Sub(from.Time).Hours(); c.cfg.MaxRate > 0 && hours > 0 && u.Value > c.cfg.MaxRate*hours {
+		u.Implausible = true
+	}
+	return
+}
+
+// keep usage within retention
+func (c *Counter) append(u Usage) {
+	c.usages = append(c.usages, u)
+	if len(c.usages) > c.cfg.Retention {
+		c.usages = append([]Usage{}, c.usages[len(c.usages)-c.cfg.Retention:]...)
+	}
+}
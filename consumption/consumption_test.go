@@ -0,0 +1,166 @@
+package consumption
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// reading of hour h, the counter only looks at elapsed time so the calendar does not matter
+func at(h float64, value float64) Reading {
+	return Reading{Time: time.Unix(0, 0).Add(time.Duration(h * float64(time.Hour))), Value: value}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		from, to    float64
+		value       float64
+		rollover    bool
+		implausible bool
+	}{
+		{name: "rise", from: 100, to: 102.5, value: 2.5},
+		{name: "still", from: 100, to: 100, value: 0},
+		{name: "wrap", cfg: Config{Rollover: 1000}, from: 999, to: 1.5, value: 2.5, rollover: true},
+		{name: "small drop with rollover", cfg: Config{Rollover: 1000}, from: 600, to: 500, implausible: true},
+		{name: "drop without rollover", from: 999, to: 1.5, implausible: true},
+		{name: "within max rate", cfg: Config{MaxRate: 10}, from: 0, to: 10, value: 10},
+		{name: "above max rate", cfg: Config{MaxRate: 10}, from: 0, to: 10.5, value: 10.5, implausible: true},
+		{name: "wrap above max rate", cfg: Config{Rollover: 100, MaxRate: 10}, from: 95, to: 20, value: 25, rollover: true, implausible: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Counter
+			if err := c.Init(tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, err := c.Add(at(0, tt.from)); ok || err != nil {
+				t.Fatalf("first reading: ok %v, err %v", ok, err)
+			}
+			u, ok, err := c.Add(at(1, tt.to))
+			if !ok || err != nil {
+				t.Fatalf("second reading: ok %v, err %v", ok, err)
+			}
+			if math.Abs(u.Value-tt.value) > 1e-9 || u.Rollover != tt.rollover || u.Implausible != tt.implausible || u.Swap {
+				t.Fatalf("usage %+v, want %g rollover %v implausible %v", u, tt.value, tt.rollover, tt.implausible)
+			}
+			if last, _ := c.Last(); last.Value != tt.to {
+				t.Fatalf("baseline %g, want %g", last.Value, tt.to)
+			}
+		})
+	}
+}
+
+func TestAddOutOfOrder(t *testing.T) {
+	var c Counter
+	if _, _, err := c.Add(at(0, 1)); err == nil {
+		t.Fatal("no error before Init")
+	}
+	c.Init(Config{})
+	c.Add(at(1, 1))
+	for _, h := range []float64{1, 0.5} {
+		if _, _, err := c.Add(at(h, 2)); err == nil {
+			t.Fatalf("no error adding reading of hour %g after hour 1", h)
+		}
+	}
+}
+
+func TestSwap(t *testing.T) {
+	var c Counter
+	c.Init(Config{Rollover: 1000})
+	c.Add(at(0, 990))
+	u, err := c.Swap(at(1, 5), at(1.5, 20), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.Swap || !u.Rollover || math.Abs(u.Value-15) > 1e-9 {
+		t.Fatalf("swap usage %+v, want 15 over rollover of removed meter", u)
+	}
+	// new meter counts from its first reading with its own rollover
+	u, _, _ = c.Add(at(2, 99))
+	if u.Swap || math.Abs(u.Value-79) > 1e-9 {
+		t.Fatalf("usage %+v after swap, want 79", u)
+	}
+	u, _, _ = c.Add(at(3, 4))
+	if !u.Rollover || math.Abs(u.Value-5) > 1e-9 {
+		t.Fatalf("usage %+v, want 5 over rollover of new meter", u)
+	}
+	if _, err = c.Swap(at(2.5, 0), at(4, 0), 0); err == nil {
+		t.Fatal("no error swapping before the previous reading")
+	}
+	if _, err = c.Swap(at(5, 0), at(4, 0), 0); err == nil {
+		t.Fatal("no error with new meter starting before the removed one ends")
+	}
+}
+
+func TestSwapUnstarted(t *testing.T) {
+	var c Counter
+	c.Init(Config{})
+	u, err := c.Swap(at(1, 40), at(1, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.Swap || u.Value != 0 || !u.Start.Equal(u.End) {
+		t.Fatalf("swap usage %+v, want instant and empty", u)
+	}
+	if last, ok := c.Last(); !ok || last.Value != 0 {
+		t.Fatalf("baseline %+v ok %v, want new meter start", last, ok)
+	}
+}
+
+func TestSum(t *testing.T) {
+	var c Counter
+	c.Init(Config{Rollover: 100})
+	// 10 per hour over hours 0 - 2, wrap in hours 2 - 4, implausible drop in hours 4 - 5
+	for _, r := range []Reading{at(0, 80), at(1, 90), at(2, 100), at(4, 20), at(5, 10)} {
+		c.Add(r)
+	}
+	tests := []struct {
+		name        string
+		from, to    float64
+		value       float64
+		rollover    bool
+		implausible bool
+	}{
+		{name: "whole", from: 0, to: 5, value: 40, rollover: true, implausible: true},
+		{name: "one usage", from: 0, to: 1, value: 10},
+		{name: "apportioned", from: 0.5, to: 1.5, value: 10},
+		{name: "part of wrap", from: 3, to: 4, value: 10, rollover: true},
+		{name: "before readings", from: -2, to: 0},
+		{name: "after readings", from: 6, to: 8},
+		{name: "empty period", from: 1, to: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := c.Sum(at(tt.from, 0).Time, at(tt.to, 0).Time)
+			if math.Abs(u.Value-tt.value) > 1e-9 || u.Rollover != tt.rollover || u.Implausible != tt.implausible {
+				t.Fatalf("sum %+v, want %g rollover %v implausible %v", u, tt.value, tt.rollover, tt.implausible)
+			}
+		})
+	}
+}
+
+func TestSumInstantSwap(t *testing.T) {
+	var c Counter
+	c.Init(Config{})
+	c.Swap(at(1, 0), at(1, 0), 0)
+	if u := c.Sum(at(0, 0).Time, at(2, 0).Time); !u.Swap {
+		t.Fatalf("sum %+v, want swap counted", u)
+	}
+	if u := c.Sum(at(0, 0).Time, at(1, 0).Time); u.Swap {
+		t.Fatalf("sum %+v, want swap at end of period left out", u)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	var c Counter
+	c.Init(Config{Retention: 3})
+	for h := 0; h < 6; h++ {
+		c.Add(at(float64(h), float64(h)))
+	}
+	usages := c.Usages()
+	if len(usages) != 3 || !usages[0].Start.Equal(at(2, 0).Time) {
+		t.Fatalf("usages %+v, want last 3", usages)
+	}
+}
@@ -0,0 +1,991 @@
+/*
+ * @filename	dlt645.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Framing and client of DL/T 645-2007 multi-function electricity meter protocol
+ * @comment		frames are carried through MBRTGateway raw link on the same RS-485 bus as Modbus-RTU meters
+ */
+
+package dlt645
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+)
+
+// control codes of master requests, slave responses have bit 7 set, and bit 6 set on abnormal response
+const (
+	CTRL_BROADCAST_TIME byte = 0x08
+	CTRL_READ           byte = 0x11
+	CTRL_READ_FOLLOW    byte = 0x12
+	CTRL_READ_ADDR      byte = 0x13
+	CTRL_WRITE          byte = 0x14
+	CTRL_WRITE_ADDR     byte = 0x15
+	CTRL_FREEZE         byte = 0x16
+	CTRL_DEMAND_RESET   byte = 0x19
+	CTRL_REMOTE         byte = 0x1C
+)
+
+// control code flags of slave responses
+const (
+	CTRL_FLAG_RESPONSE byte = 0x80
+	CTRL_FLAG_ABNORMAL byte = 0x40
+	CTRL_FLAG_FOLLOW   byte = 0x20
+)
+
+// remote control command types, N1 of CTRL_REMOTE
+const (
+	REMOTE_TRIP          byte = 0x1A
+	REMOTE_CLOSE_ALLOWED byte = 0x1B
+	REMOTE_CLOSE         byte = 0x1C
+	REMOTE_ALARM         byte = 0x2A
+	REMOTE_ALARM_RELEASE byte = 0x2B
+	REMOTE_KEEP          byte = 0x3A
+	REMOTE_KEEP_RELEASE  byte = 0x3B
+)
+
+// data identifiers used by drivers
+const (
+	DI_ENERGY_ACTIVE_COMBINED uint32 = 0x00000000
+	DI_ENERGY_ACTIVE_POSI     uint32 = 0x00010000
+	DI_ENERGY_ACTIVE_NEGA     uint32 = 0x00020000
+	DI_ENERGY_REACTIVE_COMB1  uint32 = 0x00030000
+	DI_ENERGY_REACTIVE_COMB2  uint32 = 0x00040000
+	DI_VOLTAGE_PHASEA         uint32 = 0x02010100
+	DI_VOLTAGE_PHASEB         uint32 = 0x02010200
+	DI_VOLTAGE_PHASEC         uint32 = 0x02010300
+	DI_CURRENT_PHASEA         uint32 = 0x02020100
+	DI_CURRENT_PHASEB         uint32 = 0x02020200
+	DI_CURRENT_PHASEC         uint32 = 0x02020300
+	DI_POWER_ACTIVE           uint32 = 0x02030000
+	DI_POWER_ACTIVE_PHASEA    uint32 = 0x02030100
+	DI_POWER_ACTIVE_PHASEB    uint32 = 0x02030200
+	DI_POWER_ACTIVE_PHASEC    uint32 = 0x02030300
+	DI_POWER_REACTIVE         uint32 = 0x02040000
+	DI_POWER_REACTIVE_PHASEA  uint32 = 0x02040100
+	DI_POWER_REACTIVE_PHASEB  uint32 = 0x02040200
+	DI_POWER_REACTIVE_PHASEC  uint32 = 0x02040300
+	DI_POWER_APPARENT         uint32 = 0x02050000
+	DI_POWER_APPARENT_PHASEA  uint32 = 0x02050100
+	DI_POWER_APPARENT_PHASEB  uint32 = 0x02050200
+	DI_POWER_APPARENT_PHASEC  uint32 = 0x02050300
+	DI_POWER_FACTOR           uint32 = 0x02060000
+	DI_POWER_FACTOR_PHASEA    uint32 = 0x02060100
+	DI_POWER_FACTOR_PHASEB    uint32 = 0x02060200
+	DI_POWER_FACTOR_PHASEC    uint32 = 0x02060300
+	DI_MAX_DEMAND_ACTIVE_POSI uint32 = 0x01010000
+	DI_MAX_DEMAND_ACTIVE_NEGA uint32 = 0x01020000
+	DI_FREQ                   uint32 = 0x02800002
+	DI_DEMAND_ACTIVE          uint32 = 0x02800004
+	DI_DEMAND_REACTIVE        uint32 = 0x02800005
+	DI_DATE                   uint32 = 0x04000101
+	DI_TIME                   uint32 = 0x04000102
+	DI_DEMAND_PERIOD          uint32 = 0x04000103
+	DI_DEMAND_SLIDE           uint32 = 0x04000104
+	DI_COMM_ADDR              uint32 = 0x04000401
+	DI_RUN_STATUS_3           uint32 = 0x04000503
+	DI_SETTLEMENT_DAY_1       uint32 = 0x04000B01
+	DI_FREEZE_INSTANT_TIME    uint32 = 0x05010001
+	DI_FREEZE_DAY_TIME        uint32 = 0x05060001
+	DI_FREEZE_DAY_ACTIVE_POSI uint32 = 0x05060101
+	DI_FREEZE_DAY_ACTIVE_NEGA uint32 = 0x05060201
+	DI_DAY_SEGMENT_AMOUNT     uint32 = 0x04000203
+	DI_DAY_TABLE_SET1         uint32 = 0x04010001
+	DI_DAY_TABLE_SET2         uint32 = 0x04020001
+)
+
+// most segments of a day table
+const DAY_SEGMENT_MAX = 14
+
+// bit of run status word 3 (DI_RUN_STATUS_3) set when relay is tripped
+const RUN_STATUS_3_RELAY_TRIPPED uint16 = 0x0010
+
+// preamble sent before frames to wake up the receiver
+var Preamble = []byte{0xFE, 0xFE, 0xFE, 0xFE}
+
+// broadcast address, accepted by every meter on the bus
+var AddrBroadcast = [6]byte{0x99, 0x99, 0x99, 0x99, 0x99, 0x99}
+
+// wildcard address, used to read address of the only meter on the bus
+var AddrWildcard = [6]byte{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}
+
+// frame of DL/T 645, data field is kept without +0x33 scrambling
+type Frame struct {
+	// meter address, A0 (lowest 2 digits) first
+	Addr [6]byte
+	// control code
+	Ctrl byte
+	// data field, unscrambled
+	Data []byte
+}
+
+// error reported by meter in abnormal response
+type MeterError struct {
+	// control code of the abnormal response
+	Ctrl byte
+	// error information word
+	Code byte
+}
+
+func (e *MeterError) Error() string {
+	var reasons []string
+	names := []string{"other error", "no requested data", "unauthorized", "baud rate unchangeable", "year zone exceeded", "day slot exceeded", "rate exceeded"}
+	for i, name := range names {
+		if e.Code&(1<<i) != 0 {
+			reasons = append(reasons, name)
+		}
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "unknown error")
+	}
+	return fmt.Sprintf("dlt645 abnormal response 0x%02x: %s (0x%02x)", e.Ctrl, strings.Join(reasons, ", "), e.Code)
+}
+
+/*
+encode frame into bytes, without preamble
+
+# Params
+
+f Frame: frame to encode
+
+# Returns
+
+buf []byte: encoded frame
+*/
+func Encode(f Frame) (buf []byte) {
+	buf = make([]byte, 0, 12+len(f.Data))
+	buf = append(buf, 0x68)
+	buf = append(buf, f.Addr[:]...)
+	buf = append(buf, 0x68, f.Ctrl, byte(len(f.Data)))
+	for _, b := range f.Data {
+		buf = append(buf, b+0x33)
+	}
+	buf = append(buf, checksum(buf), 0x16)
+	return
+}
+
+/*
+decode bytes into frame, leading preamble bytes are skipped
+
+# Params
+
+buf []byte: frame bytes
+
+# Returns
+
+f Frame: decoded frame
+
+err error: error if the frame is malformed
+*/
+func Decode(buf []byte) (f Frame, err error) {
+	for len(buf) > 0 && buf[0] == 0xFE {
+		buf = buf[1:]
+	}
+	if len(buf) < 12 || buf[0] != 0x68 || buf[7] != 0x68 {
+		err = errors.New("dlt645 bad frame header")
+		return
+	}
+	l := int(buf[9])
+	if len(buf) < 12+l {
+		err = errors.New("dlt645 short frame")
+		return
+	}
+	if buf[11+l] != 0x16 {
+		err = errors.New("dlt645 bad frame tail")
+		return
+	}
+	if checksum(buf[:10+l]) != buf[10+l] {
+		err = errors.New("dlt645 bad checksum")
+		return
+	}
+	copy(f.Addr[:], buf[1:7])
+	f.Ctrl = buf[8]
+	f.Data = make([]byte, l)
+	for i := 0; i < l; i++ {
+		f.Data[i] = buf[10+i] - 0x33
+	}
+	return
+}
+
+/*
+read exactly one frame from link, for gateway.Transact
+
+# Params
+
+r io.Reader: link to read from
+
+# Returns
+
+buf []byte: frame bytes, without preamble
+
+err error: error
+*/
+func ReadFrame(r io.Reader) (buf []byte, err error) {
+	br := bufio.NewReader(r)
+	var b byte
+	for {
+		b, err = br.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == 0x68 {
+			break
+		}
+	}
+	head := make([]byte, 10)
+	head[0] = b
+	_, err = io.ReadFull(br, head[1:])
+	if err != nil {
+		return
+	}
+	if head[7] != 0x68 {
+		err = errors.New("dlt645 bad frame header")
+		return
+	}
+	rest := make([]byte, int(head[9])+2)
+	_, err = io.ReadFull(br, rest)
+	if err != nil {
+		return
+	}
+	buf = append(head, rest...)
+	return
+}
+
+func checksum(buf []byte) (cs byte) {
+	for _, b := range buf {
+		cs += b
+	}
+	return
+}
+
+/*
+parse meter address printed on nameplate, up to 12 decimal digits
+
+# Params
+
+s string: address such as "000012345678", shorter ones are left padded with 0
+
+# Returns
+
+addr [6]byte: address in frame order, A0 first
+
+err error: error
+*/
+func ParseAddr(s string) (addr [6]byte, err error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || len(s) > 12 {
+		err = fmt.Errorf("invalid dlt645 address %q", s)
+		return
+	}
+	s = strings.Repeat("0", 12-len(s)) + s
+	for i := 0; i < 6; i++ {
+		hi, lo := s[10-2*i], s[11-2*i]
+		if hi < '0' || hi > '9' || lo < '0' || lo > '9' {
+			err = fmt.Errorf("invalid dlt645 address %q", s)
+			return
+		}
+		addr[i] = (hi-'0')<<4 | (lo - '0')
+	}
+	return
+}
+
+// format address into 12 decimal digits as printed on nameplate
+func FormatAddr(addr [6]byte) (s string) {
+	for i := 5; i >= 0; i-- {
+		s += fmt.Sprintf("%02x", addr[i])
+	}
+	return
+}
+
+/*
+decode little-endian BCD value, the highest bit of the last byte is the sign if signed
+
+# Params
+
+data []byte: BCD bytes, lowest byte first
+
+decimals int: number of decimal digits
+
+signed bool: if the value carries a sign bit
+
+# Returns
+
+val float64: value
+
+err error: error if a nibble is not a decimal digit
+*/
+func DecodeBCD(data []byte, decimals int, signed bool) (val float64, err error) {
+	negative := false
+	for i := len(data) - 1; i >= 0; i-- {
+		b := data[i]
+		if i == len(data)-1 && signed && b&0x80 != 0 {
+			negative = true
+			b &= 0x7F
+		}
+		hi, lo := b>>4, b&0x0F
+		if hi > 9 || lo > 9 {
+			err = fmt.Errorf("dlt645 bad bcd byte 0x%02x", data[i])
+			return
+		}
+		val = val*100 + float64(hi)*10 + float64(lo)
+	}
+	val /= math.Pow10(decimals)
+	if negative {
+		val *= -1
+	}
+	return
+}
+
+/*
+encode value into little-endian BCD, the reverse of DecodeBCD
+
+# Params
+
+val float64: value
+
+size int: number of bytes
+
+decimals int: number of decimal digits
+
+signed bool: if the value carries a sign bit
+
+# Returns
+
+data []byte: BCD bytes, lowest byte first
+
+err error: error if value is out of range
+*/
+func EncodeBCD(val float64, size int, decimals int, signed bool) (data []byte, err error) {
+	negative := val < 0
+	if negative && !signed {
+		err = fmt.Errorf("negative value %g for unsigned bcd", val)
+		return
+	}
+	n := uint64(math.Round(math.Abs(val) * math.Pow10(decimals)))
+	data = make([]byte, size)
+	for i := 0; i < size; i++ {
+		data[i] = byte(n%10) | byte(n/10%10)<<4
+		n /= 100
+	}
+	if n != 0 || (signed && data[size-1]&0x80 != 0) {
+		err = fmt.Errorf("value %g out of bcd range", val)
+		return
+	}
+	if negative {
+		data[size-1] |= 0x80
+	}
+	return
+}
+
+// encode data identifier, DI0 first
+func encodeDI(di uint32) (data []byte) {
+	data = []byte{byte(di), byte(di >> 8), byte(di >> 16), byte(di >> 24)}
+	return
+}
+
+// client of one DL/T 645 meter behind a gateway
+type Client struct {
+	gateway *gateway.MBRTGateway
+	addr    [6]byte
+	// password with its level in the first byte, used by write and remote control
+	password [4]byte
+	// operator code, used by write and remote control
+	operator [4]byte
+	// number of retries after the first failed attempt
+	retries int
+}
+
+/*
+initialize client instance
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, and needs to be initialized in advance
+
+addr [6]byte: meter address, could be parsed by ParseAddr
+
+# Returns
+
+err error: error
+*/
+func (c *Client) Init(gw *gateway.MBRTGateway, addr [6]byte) (err error) {
+	if gw == nil {
+		err = errors.New("nil gateway")
+		return
+	}
+	c.gateway = gw
+	c.addr = addr
+	c.password = [4]byte{0x02, 0x00, 0x00, 0x00}
+	c.retries = 3
+	return
+}
+
+/*
+set password and operator code used by write and remote control
+
+# Params
+
+level byte: password level, such as 0x02 or 0x04
+
+password [3]byte: password, lowest byte first
+
+operator [4]byte: operator code
+*/
+func (c *Client) SetPassword(level byte, password [3]byte, operator [4]byte) {
+	c.password = [4]byte{level, password[0], password[1], password[2]}
+	c.operator = operator
+}
+
+// get meter address
+func (c *Client) GetAddr() (addr [6]byte) {
+	addr = c.addr
+	return
+}
+
+/*
+send request and return the normal response, with retries
+
+# Params
+
+ctrl byte: control code, using macro CTRL_*
+
+data []byte: data field, unscrambled
+
+# Returns
+
+resp Frame: normal response frame
+
+err error: error, *MeterError if meter answered with abnormal response
+*/
+func (c *Client) Request(ctrl byte, data []byte) (resp Frame, err error) {
+	if c.gateway == nil {
+		err = errors.New("dlt645 client not initialized")
+		return
+	}
+	req := append(append([]byte{}, Preamble...), Encode(Frame{Addr: c.addr, Ctrl: ctrl, Data: data})...)
+	var buf []byte
+	for retry := c.retries; ; retry-- {
+		buf, err = c.gateway.Transact(req, ReadFrame)
+		if err == nil {
+			resp, err = Decode(buf)
+		}
+		if err == nil {
+			break
+		}
+		if retry <= 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if resp.Ctrl&^(CTRL_FLAG_ABNORMAL|CTRL_FLAG_FOLLOW) != ctrl|CTRL_FLAG_RESPONSE {
+		err = fmt.Errorf("dlt645 unexpected control code 0x%02x", resp.Ctrl)
+		return
+	}
+	if c.addr != AddrWildcard && c.addr != AddrBroadcast && resp.Addr != c.addr {
+		err = fmt.Errorf("dlt645 response from unexpected address %s", FormatAddr(resp.Addr))
+		return
+	}
+	if resp.Ctrl&CTRL_FLAG_ABNORMAL != 0 {
+		mErr := &MeterError{Ctrl: resp.Ctrl}
+		if len(resp.Data) > 0 {
+			mErr.Code = resp.Data[0]
+		}
+		err = mErr
+	}
+	return
+}
+
+/*
+read data of data identifier
+
+# Params
+
+di uint32: data identifier, such as DI_ENERGY_ACTIVE_POSI
+
+# Returns
+
+data []byte: data bytes following the data identifier, lowest byte first
+
+err error: error
+*/
+func (c *Client) Read(di uint32) (data []byte, err error) {
+	var resp Frame
+	resp, err = c.Request(CTRL_READ, encodeDI(di))
+	if err != nil {
+		return
+	}
+	if len(resp.Data) < 4 || string(resp.Data[:4]) != string(encodeDI(di)) {
+		err = fmt.Errorf("dlt645 response of unexpected data identifier")
+		return
+	}
+	data = resp.Data[4:]
+	return
+}
+
+/*
+read BCD value of data identifier
+
+# Params
+
+di uint32: data identifier, such as DI_ENERGY_ACTIVE_POSI
+
+size int: number of bytes of the value
+
+decimals int: number of decimal digits
+
+signed bool: if the value carries a sign bit
+
+# Returns
+
+val float64: value
+
+err error: error
+*/
+func (c *Client) ReadValue(di uint32, size int, decimals int, signed bool) (val float64, err error) {
+	var data []byte
+	data, err = c.Read(di)
+	if err != nil {
+		return
+	}
+	if len(data) < size {
+		err = fmt.Errorf("dlt645 short data of 0x%08x, %d of %d bytes", di, len(data), size)
+		return
+	}
+	val, err = DecodeBCD(data[:size], decimals, signed)
+	return
+}
+
+/*
+write data of data identifier, authorized with password and operator code
+
+# Params
+
+di uint32: data identifier
+
+data []byte: data bytes, lowest byte first
+
+# Returns
+
+err error: error
+*/
+func (c *Client) Write(di uint32, data []byte) (err error) {
+	payload := encodeDI(di)
+	payload = append(payload, c.password[:]...)
+	payload = append(payload, c.operator[:]...)
+	payload = append(payload, data...)
+	_, err = c.Request(CTRL_WRITE, payload)
+	return
+}
+
+/*
+remote control command, such as trip or close the relay
+
+# Params
+
+cmd byte: command type, using macro REMOTE_*
+
+expire time.Time: the command is rejected by meter after this time
+
+# Returns
+
+err error: error
+*/
+func (c *Client) RemoteControl(cmd byte, expire time.Time) (err error) {
+	payload := append([]byte{}, c.password[:]...)
+	payload = append(payload, c.operator[:]...)
+	payload = append(payload, cmd, 0x00)
+	payload = append(payload, encodeDateTime(expire)...)
+	_, err = c.Request(CTRL_REMOTE, payload)
+	return
+}
+
+/*
+reset maximum demand of meter, authorized with password and operator code
+
+# Returns
+
+err error: error
+*/
+func (c *Client) DemandReset() (err error) {
+	payload := append([]byte{}, c.password[:]...)
+	payload = append(payload, c.operator[:]...)
+	_, err = c.Request(CTRL_DEMAND_RESET, payload)
+	return
+}
+
+/*
+read a value followed by the time it occurred, such as maximum demand
+
+# Params
+
+di uint32: data identifier
+
+size int: number of bytes of the value
+
+decimals int: number of decimal digits
+
+signed bool: if the highest bit is the sign
+
+# Returns
+
+val float64: value
+
+t time.Time: time of occurrence in local time zone, to the minute
+
+err error: error
+*/
+func (c *Client) ReadValueTime(di uint32, size int, decimals int, signed bool) (val float64, t time.Time, err error) {
+	var data []byte
+	data, err = c.Read(di)
+	if err != nil {
+		return
+	}
+	if len(data) < size+5 {
+		err = fmt.Errorf("dlt645 short data of 0x%08x, %d of %d bytes", di, len(data), size+5)
+		return
+	}
+	val, err = DecodeBCD(data[:size], decimals, signed)
+	if err != nil {
+		return
+	}
+	// mmhhDDMMYY, lowest byte first
+	t, err = decodeDateTime(append([]byte{0x00}, data[size:size+5]...))
+	return
+}
+
+/*
+synchronize clock of all DL/T 645 meters on the gateway at once by broadcast time, no response is expected
+
+meters only accept a broadcast time within a few minutes of their own clock and at most once a day, read back each meter to verify
+
+# Params
+
+gw *gateway.MBRTGateway: gateway of the meters
+
+t time.Time: time to set, in local time of the meters
+
+# Returns
+
+err error: error if the frame could not be sent
+*/
+func BroadcastTime(gw *gateway.MBRTGateway, t time.Time) (err error) {
+	if gw == nil {
+		err = errors.New("nil gateway")
+		return
+	}
+	req := append(append([]byte{}, Preamble...), Encode(Frame{Addr: AddrBroadcast, Ctrl: CTRL_BROADCAST_TIME, Data: encodeDateTime(t)})...)
+	_, err = gw.Transact(req, nil)
+	if err != nil {
+		return
+	}
+	time.Sleep(gateway.BROADCAST_TURNAROUND)
+	return
+}
+
+/*
+freeze energy registers of all DL/T 645 meters on the gateway at once by broadcast instant freeze, no response is expected
+
+# Params
+
+gw *gateway.MBRTGateway: gateway of the meters
+
+# Returns
+
+err error: error if the frame could not be sent
+*/
+func BroadcastFreeze(gw *gateway.MBRTGateway) (err error) {
+	if gw == nil {
+		err = errors.New("nil gateway")
+		return
+	}
+	// freeze time of all 99 means freeze at once
+	req := append(append([]byte{}, Preamble...), Encode(Frame{Addr: AddrBroadcast, Ctrl: CTRL_FREEZE, Data: []byte{0x99, 0x99, 0x99, 0x99}})...)
+	_, err = gw.Transact(req, nil)
+	if err != nil {
+		return
+	}
+	time.Sleep(gateway.BROADCAST_TURNAROUND)
+	return
+}
+
+/*
+read clock of meter
+
+# Returns
+
+t time.Time: meter time in local time zone
+
+err error: error
+*/
+func (c *Client) ReadDateTime() (t time.Time, err error) {
+	var date, clock []byte
+	date, err = c.Read(DI_DATE)
+	if err != nil {
+		return
+	}
+	clock, err = c.Read(DI_TIME)
+	if err != nil {
+		return
+	}
+	if len(date) < 4 || len(clock) < 3 {
+		err = fmt.Errorf("dlt645 short date time, %d and %d bytes", len(date), len(clock))
+		return
+	}
+	// date is WWDDMMYY and time is ssmmhh, lowest byte first
+	t, err = decodeDateTime([]byte{clock[0], clock[1], clock[2], date[1], date[2], date[3]})
+	return
+}
+
+/*
+read time of the last instant freeze
+
+# Returns
+
+t time.Time: freeze time in local time zone, to the minute
+
+err error: error
+*/
+func (c *Client) ReadFreezeTime() (t time.Time, err error) {
+	t, err = c.ReadTimestamp(DI_FREEZE_INSTANT_TIME)
+	return
+}
+
+/*
+read a timestamp kept to the minute, such as the time of a freeze record
+
+# Params
+
+di uint32: data identifier of the timestamp, format YYMMDDhhmm
+
+# Returns
+
+t time.Time: time in local time zone
+
+err error: error
+*/
+func (c *Client) ReadTimestamp(di uint32) (t time.Time, err error) {
+	var data []byte
+	data, err = c.Read(di)
+	if err != nil {
+		return
+	}
+	if len(data) < 5 {
+		err = fmt.Errorf("dlt645 short timestamp, %d bytes", len(data))
+		return
+	}
+	// mmhhDDMMYY, lowest byte first
+	t, err = decodeDateTime(append([]byte{0x00}, data[:5]...))
+	return
+}
+
+/*
+read the first monthly settlement day, at which energy totals are settled into history records
+
+# Returns
+
+day int: day of month, 1 - 28
+
+hour int: hour of day, 0 - 23
+
+err error: error
+*/
+func (c *Client) ReadSettlementDay() (day int, hour int, err error) {
+	var data []byte
+	data, err = c.Read(DI_SETTLEMENT_DAY_1)
+	if err != nil {
+		return
+	}
+	if len(data) < 2 {
+		err = fmt.Errorf("dlt645 short settlement day, %d bytes", len(data))
+		return
+	}
+	// DDhh, lowest byte first
+	var v float64
+	v, err = DecodeBCD(data[:2], 0, false)
+	if err != nil {
+		return
+	}
+	day, hour = int(v)/100, int(v)%100
+	if day < 1 || day > 28 || hour > 23 {
+		err = fmt.Errorf("dlt645 invalid settlement day %02d at %02d:00", day, hour)
+	}
+	return
+}
+
+/*
+get data identifier of a history record, such as the n-th last settlement or day freeze
+
+# Params
+
+di uint32: data identifier of record, DI0 is ignored
+
+n uint8: record number, 1 for the last one
+
+# Returns
+
+recordDI uint32: data identifier of the record
+*/
+func RecordDI(di uint32, n uint8) (recordDI uint32) {
+	recordDI = di&^0x000000FF | uint32(n)
+	return
+}
+
+// decode ssmmhhDDMMYY BCD into local time, the reverse of encodeDateTime
+func decodeDateTime(data []byte) (t time.Time, err error) {
+	var fields [6]int
+	for i := range fields {
+		var v float64
+		v, err = DecodeBCD(data[i:i+1], 0, false)
+		if err != nil {
+			return
+		}
+		fields[i] = int(v)
+	}
+	t = time.Date(2000+fields[5], time.Month(fields[4]), fields[3], fields[2], fields[1], fields[0], 0, time.Local)
+	return
+}
+
+/*
+get data identifier of one tariff rate from the one of all rates, such as DI_ENERGY_ACTIVE_POSI
+
+# Params
+
+di uint32: data identifier of all rates, DI1 is 0
+
+rate uint8: tariff rate, 1 - 63
+
+# Returns
+
+rateDI uint32: data identifier of the rate
+*/
+func RateDI(di uint32, rate uint8) (rateDI uint32) {
+	rateDI = di&^0x0000FF00 | uint32(rate)<<8
+	return
+}
+
+// segment of day table, tariff rate from start time until the start of next segment
+type TimeSegment struct {
+	// start hour, 0 - 23
+	Hour uint8
+	// start minute, 0 - 59
+	Minute uint8
+	// tariff rate, 1 - 63
+	Rate uint8
+}
+
+/*
+read day table of time-of-use tariff, trailing segments repeating the previous one are dropped
+
+# Params
+
+set uint8: table set, 1 for current and 2 for standby
+
+table uint8: day table number, 1 - 8
+
+# Returns
+
+segs []TimeSegment: segments ordered by start time
+
+err error: error
+*/
+func (c *Client) ReadDayTable(set uint8, table uint8) (segs []TimeSegment, err error) {
+	var di uint32
+	di, err = dayTableDI(set, table)
+	if err != nil {
+		return
+	}
+	var data []byte
+	data, err = c.Read(di)
+	if err != nil {
+		return
+	}
+	// each segment is hhmmNN, lowest byte first
+	for i := 0; i+3 <= len(data); i += 3 {
+		var v float64
+		v, err = DecodeBCD(data[i:i+3], 0, false)
+		if err != nil {
+			return
+		}
+		seg := TimeSegment{Hour: uint8(int(v) / 10000), Minute: uint8(int(v) / 100 % 100), Rate: uint8(int(v) % 100)}
+		if len(segs) > 0 && segs[len(segs)-1] == seg {
+			continue
+		}
+		segs = append(segs, seg)
+	}
+	return
+}
+
+/*
+write day table of time-of-use tariff, padded to DAY_SEGMENT_MAX by repeating the last segment
+
+# Params
+
+set uint8: table set, 1 for current and 2 for standby
+
+table uint8: day table number, 1 - 8
+
+segs []TimeSegment: 1 - DAY_SEGMENT_MAX segments ordered by start time
+
+# Returns
+
+err error: error
+*/
+func (c *Client) WriteDayTable(set uint8, table uint8, segs []TimeSegment) (err error) {
+	var di uint32
+	di, err = dayTableDI(set, table)
+	if err != nil {
+		return
+	}
+	if len(segs) == 0 || len(segs) > DAY_SEGMENT_MAX {
+		err = fmt.Errorf("invalid day table of %d segments, 1 - %d expected", len(segs), DAY_SEGMENT_MAX)
+		return
+	}
+	var data []byte
+	for i := 0; i < DAY_SEGMENT_MAX; i++ {
+		seg := segs[len(segs)-1]
+		if i < len(segs) {
+			seg = segs[i]
+		}
+		data = append(data, bcdByte(seg.Rate), bcdByte(seg.Minute), bcdByte(seg.Hour))
+	}
+	err = c.Write(di, data)
+	return
+}
+
+// data identifier of day table
+func dayTableDI(set uint8, table uint8) (di uint32, err error) {
+	if table < 1 || table > 8 {
+		err = fmt.Errorf("invalid day table %d, 1 - 8 expected", table)
+		return
+	}
+	switch set {
+	case 1:
+		di = DI_DAY_TABLE_SET1 + uint32(table-1)
+	case 2:
+		di = DI_DAY_TABLE_SET2 + uint32(table-1)
+	default:
+		err = fmt.Errorf("invalid table set %d, 1 or 2 expected", set)
+	}
+	return
+}
+
+// encode 0 - 99 into one BCD byte
+func bcdByte(v uint8) byte {
+	return byte(v/10)<<4 | byte(v%10)
+}
+
+// encode time into ssmmhhDDMMYY BCD, second first
+func encodeDateTime(t time.Time) (data []byte) {
+	fields := []int{t.Second(), t.Minute(), t.Hour(), t.Day(), int(t.Month()), t.Year() % 100}
+	for _, v := range fields {
+		data = append(data, bcdByte(uint8(v)))
+	}
+	return
+}
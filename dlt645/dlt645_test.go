@@ -0,0 +1,179 @@
+package dlt645
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+var addrMeter = [6]byte{0x78, 0x56, 0x34, 0x12, 0x00, 0x00}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		f    Frame
+		buf  []byte
+	}{
+		{
+			name: "read address by wildcard",
+			f:    Frame{Addr: AddrWildcard, Ctrl: CTRL_READ_ADDR},
+			buf:  []byte{0x68, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0x68, 0x13, 0x00, 0xDF, 0x16},
+		},
+		{
+			// data identifier 00 01 00 00 is sent DI0 first and scrambled by +0x33
+			name: "read forward active energy",
+			f:    Frame{Addr: addrMeter, Ctrl: CTRL_READ, Data: encodeDI(DI_ENERGY_ACTIVE_POSI)},
+			buf:  []byte{0x68, 0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x68, 0x11, 0x04, 0x33, 0x33, 0x34, 0x33, 0xC6, 0x16},
+		},
+	}
+	for _, tt := range tests {
+		if buf := Encode(tt.f); !bytes.Equal(buf, tt.buf) {
+			t.Errorf("%s: encoded % X, want % X", tt.name, buf, tt.buf)
+		}
+	}
+}
+
+// response of meter 000012345678 with forward active energy 1234.56 kWh
+var bytesEnergy = []byte{0x68, 0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x68, 0x91, 0x08, 0x33, 0x33, 0x34, 0x33, 0x89, 0x67, 0x45, 0x33, 0xB2, 0x16}
+
+func TestDecode(t *testing.T) {
+	corrupt := func(i int, b byte) []byte {
+		buf := append([]byte{}, bytesEnergy...)
+		buf[i] = b
+		return buf
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+		bad  bool
+	}{
+		{name: "frame", buf: bytesEnergy},
+		{name: "with preamble", buf: append(append([]byte{}, Preamble...), bytesEnergy...)},
+		{name: "bad header", buf: corrupt(7, 0x69), bad: true},
+		{name: "short", buf: bytesEnergy[:11], bad: true},
+		{name: "length beyond frame", buf: corrupt(9, 0x09), bad: true},
+		{name: "bad tail", buf: corrupt(19, 0x17), bad: true},
+		{name: "bad checksum", buf: corrupt(18, 0xB3), bad: true},
+		{name: "bad data", buf: corrupt(14, 0x8A), bad: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Decode(tt.buf)
+			if tt.bad {
+				if err == nil {
+					t.Fatalf("decoded %+v, want error", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := append(encodeDI(DI_ENERGY_ACTIVE_POSI), 0x56, 0x34, 0x12, 0x00)
+			if f.Addr != addrMeter || f.Ctrl != CTRL_READ|CTRL_FLAG_RESPONSE || !bytes.Equal(f.Data, data) {
+				t.Fatalf("decoded %+v, want data % X descrambled", f, data)
+			}
+			val, err := DecodeBCD(f.Data[4:], 2, false)
+			if err != nil || math.Abs(val-1234.56) > 1e-9 {
+				t.Fatalf("value %g, err %v, want 1234.56", val, err)
+			}
+			if buf := Encode(f); !bytes.Equal(buf, bytesEnergy) {
+				t.Fatalf("encoded back % X, want % X", buf, bytesEnergy)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	link := append(append([]byte{0x00}, Preamble...), bytesEnergy...)
+	link = append(link, 0xFE)
+	buf, err := ReadFrame(bytes.NewReader(link))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, bytesEnergy) {
+		t.Fatalf("read % X, want % X", buf, bytesEnergy)
+	}
+	if _, err = ReadFrame(bytes.NewReader(bytesEnergy[:15])); err == nil {
+		t.Fatal("no error reading truncated frame")
+	}
+}
+
+func TestAddr(t *testing.T) {
+	tests := []struct {
+		s    string
+		addr [6]byte
+		bad  bool
+	}{
+		{s: "000012345678", addr: addrMeter},
+		{s: "12345678", addr: addrMeter},
+		{s: " 987654321098 ", addr: [6]byte{0x98, 0x10, 0x32, 0x54, 0x76, 0x98}},
+		{s: "", bad: true},
+		{s: "1234567890123", bad: true},
+		{s: "1234567A", bad: true},
+	}
+	for _, tt := range tests {
+		addr, err := ParseAddr(tt.s)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%q: parsed % X, want error", tt.s, addr)
+			}
+			continue
+		}
+		if err != nil || addr != tt.addr {
+			t.Errorf("%q: parsed % X, err %v, want % X", tt.s, addr, err, tt.addr)
+			continue
+		}
+		if s, _ := ParseAddr(FormatAddr(addr)); s != addr {
+			t.Errorf("%q: formatted %s does not parse back", tt.s, FormatAddr(addr))
+		}
+	}
+}
+
+func TestBCD(t *testing.T) {
+	tests := []struct {
+		name     string
+		val      float64
+		size     int
+		decimals int
+		signed   bool
+		// BCD of val, nil if val cannot be encoded
+		data []byte
+		// data cannot be decoded, it is not encoded from val
+		bad bool
+	}{
+		{name: "energy", val: 1234.56, size: 4, decimals: 2, data: []byte{0x56, 0x34, 0x12, 0x00}},
+		{name: "voltage", val: 220.1, size: 2, decimals: 1, data: []byte{0x01, 0x22}},
+		{name: "negative current", val: -1.234, size: 3, decimals: 3, signed: true, data: []byte{0x34, 0x12, 0x80}},
+		{name: "positive power", val: 12.3456, size: 3, decimals: 4, signed: true, data: []byte{0x56, 0x34, 0x12}},
+		{name: "largest unsigned", val: 999999.99, size: 4, decimals: 2, data: []byte{0x99, 0x99, 0x99, 0x99}},
+		{name: "beyond unsigned", val: 1000000, size: 4, decimals: 2},
+		{name: "beyond signed", val: 80, size: 1, signed: true},
+		{name: "negative unsigned", val: -1, size: 2},
+		{name: "bad nibble", data: []byte{0x0A, 0x00}, bad: true},
+		{name: "bad high nibble", data: []byte{0x00, 0xA0}, bad: true},
+	}
+	for _, tt := range tests {
+		if !tt.bad {
+			data, err := EncodeBCD(tt.val, tt.size, tt.decimals, tt.signed)
+			if tt.data == nil {
+				if err == nil {
+					t.Errorf("%s: encoded % X, want error", tt.name, data)
+				}
+				continue
+			}
+			if err != nil || !bytes.Equal(data, tt.data) {
+				t.Errorf("%s: encoded % X, err %v, want % X", tt.name, data, err, tt.data)
+			}
+		}
+		val, err := DecodeBCD(tt.data, tt.decimals, tt.signed)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("%s: decoded %g, want error", tt.name, val)
+			}
+			continue
+		}
+		if err != nil || math.Abs(val-tt.val) > 1e-9 {
+			t.Errorf("%s: decoded %g, err %v, want %g", tt.name, val, err, tt.val)
+		}
+	}
+}
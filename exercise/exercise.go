@@ -0,0 +1,482 @@
+/*
+ * @filename	exercise.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Scheduled exercise of water valves in a maintenance window, preventing seizure
+ * @comment		only valves found open are exercised, and they are always reopened, valves closed on purpose are left alone
+ */
+
+package exercise
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// water meter whose valve is exercised, such as *watermeter.WaterMeter
+type Meter interface {
+	GetCapabilities() (caps meter.Capabilities)
+	// valve status, true is opened
+	GetValve(turn uint8) (stat bool, err error)
+	// valve command, waiting until it is confirmed
+	SetValve(turn uint8, stat bool) (err error)
+	// valve command, polling valve state with progress
+	ActuateValve(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error)
+	// if the valve tells opening or closing apart from opened and closed
+	ValveReportsTravel(turn uint8) (travel bool)
+}
+
+// exercise modes
+const (
+	// close the valve fully, confirm it closed, then reopen it
+	MODE_FULL uint8 = iota
+	// start closing the valve and reopen it after PartialTime, shortening the time without water,
+	// failed if the valve reports fault by then, or is still opened if it reports its travel,
+	// a valve without travel states reads opened while moving, so a seized one is not told apart
+	MODE_PARTIAL
+)
+
+// outcomes of an exercise
+const (
+	// valve cycled and reopened
+	OUTCOME_OK uint8 = iota
+	// valve not open or its status could not be read, left alone
+	OUTCOME_SKIPPED
+	// valve did not confirm closing, it is open again
+	OUTCOME_CLOSE_FAILED
+	// valve could not be reopened and may be left closed, needs attention on site
+	OUTCOME_STUCK_CLOSED
+)
+
+// daily window of time, may wrap over midnight such as 22:00 - 04:00
+type Window struct {
+	// start as offset from midnight
+	Start time.Duration
+	// end as offset from midnight
+	End time.Duration
+}
+
+// configuration of exercise scheduler
+type Config struct {
+	// maintenance window in which exercises are started, 02:00 - 04:00 if both 0
+	Window Window
+	// time between exercises of one valve, 30 days if 0
+	Interval time.Duration
+	// exercise mode, using macro MODE_*
+	Mode uint8
+	// time the valve is kept closed in MODE_FULL, or travels towards closed in MODE_PARTIAL, 5 s if 0
+	PartialTime time.Duration
+	// attempts to reopen a valve before giving up, 3 if 0
+	ReopenAttempts int
+	// exercises started by one poll, 0 for all due valves
+	MaxPerPoll int
+	// time zone of window, time.Local if nil
+	Location *time.Location
+	// number of results kept, 1000 if 0
+	Retention int
+	// clock of window, due time and results, time.Now if nil
+	Now func() time.Time
+	// called on each result after the scheduler is unlocked, so it may call methods of the scheduler, could be nil
+	OnResult func(r Result)
+}
+
+// result of one valve exercise
+type Result struct {
+	// valve id given to Add
+	ID string
+	// start of exercise
+	Start time.Time
+	// end of exercise
+	End time.Time
+	// outcome, using macro OUTCOME_*
+	Outcome uint8
+	// error of the failed step, nil if OUTCOME_OK
+	Err error
+}
+
+// exercise state of one valve
+type Status struct {
+	// time of the last exercise, zero if never exercised
+	LastRun time.Time
+	// result of the last exercise
+	LastResult Result
+	// time when the next exercise is due
+	Due time.Time
+	// failed exercises in a row
+	Failures int
+}
+
+// valve exercise scheduler of a group of water valves
+type Scheduler struct {
+	cfg Config
+	// guards all but wm and turn of valves, never held across an exercise
+	mtx     sync.Mutex
+	valves  map[string]*valve
+	results []Result
+}
+
+type valve struct {
+	wm     Meter
+	turn   uint8
+	status Status
+	// if an exercise is running
+	busy bool
+}
+
+/*
+initialize scheduler with configuration
+
+# Params
+
+cfg Config: configuration, zero fields take defaults
+
+# Returns
+
+err error: error
+*/
+func (s *Scheduler) Init(cfg Config) (err error) {
+	if cfg.Window.Start == 0 && cfg.Window.End == 0 {
+		cfg.Window = Window{Start: 2 * time.Hour, End: 4 * time.Hour}
+	}
+	if cfg.Window.Start < 0 || cfg.Window.Start >= 24*time.Hour || cfg.Window.End < 0 || cfg.Window.End > 24*time.Hour || cfg.Window.Start == cfg.Window.End {
+		err = fmt.Errorf("invalid maintenance window %s - %s", cfg.Window.Start, cfg.Window.End)
+		return
+	}
+	if cfg.Mode > MODE_PARTIAL {
+		err = fmt.Errorf("invalid exercise mode %d", cfg.Mode)
+		return
+	}
+	if cfg.Interval < 0 || cfg.PartialTime < 0 || cfg.ReopenAttempts < 0 || cfg.MaxPerPoll < 0 || cfg.Retention < 0 {
+		err = errors.New("negative exercise configuration")
+		return
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 30 * 24 * time.Hour
+	}
+	if cfg.PartialTime == 0 {
+		cfg.PartialTime = 5 * time.Second
+	}
+	if cfg.ReopenAttempts == 0 {
+		cfg.ReopenAttempts = 3
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 1000
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.cfg = cfg
+	s.valves = map[string]*valve{}
+	s.results = nil
+	return
+}
+
+/*
+add a valve, it is due at the next maintenance window
+
+# Params
+
+id string: valve id, such as room number
+
+wm Meter: initialized water meter, such as *watermeter.WaterMeter
+
+turn uint8: which valve is exercised, using macro watermeter.VALVE_TURN_*
+
+# Returns
+
+err error: error if the id is taken or the meter has no such valve
+*/
+func (s *Scheduler) Add(id string, wm Meter, turn uint8) (err error) {
+	if wm == nil {
+		err = errors.New("nil water meter")
+		return
+	}
+	caps := wm.GetCapabilities()
+	if int(turn) >= caps.ActuatorAmount {
+		err = fmt.Errorf("invalid valve turn %d of %s", turn, caps.ModelName)
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.valves == nil {
+		err = errors.New("exercise scheduler not initialized")
+		return
+	}
+	if _, ok := s.valves[id]; ok {
+		err = fmt.Errorf("valve %s exists", id)
+		return
+	}
+	s.valves[id] = &valve{wm: wm, turn: turn}
+	return
+}
+
+/*
+remove a valve
+
+# Params
+
+id string: valve id
+*/
+func (s *Scheduler) Remove(id string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.valves, id)
+}
+
+/*
+exercise due valves if in the maintenance window, call it periodically
+
+failed exercises are due again at the next window
+
+# Returns
+
+results []Result: results of exercises run by this poll
+*/
+func (s *Scheduler) Poll() (results []Result) {
+	s.mtx.Lock()
+	cfg := s.cfg
+	if s.valves == nil {
+		s.mtx.Unlock()
+		return
+	}
+	now := cfg.Now()
+	if !inWindow(cfg, now) {
+		s.mtx.Unlock()
+		return
+	}
+	var due []string
+	for id, v := range s.valves {
+		if !v.status.Due.After(now) {
+			due = append(due, id)
+		}
+	}
+	// most overdue first
+	sort.Slice(due, func(i, j int) bool {
+		a, b := s.valves[due[i]].status.Due, s.valves[due[j]].status.Due
+		if a.Equal(b) {
+			return due[i] < due[j]
+		}
+		return a.Before(b)
+	})
+	s.mtx.Unlock()
+	for _, id := range due {
+		if cfg.MaxPerPoll > 0 && len(results) >= cfg.MaxPerPoll {
+			break
+		}
+		// the window may close during a long run
+		if !inWindow(cfg, cfg.Now()) {
+			break
+		}
+		// removed, being run or run by Run meanwhile
+		if r, err := s.run(id, true); err == nil {
+			results = append(results, r)
+		}
+	}
+	return
+}
+
+/*
+exercise a valve now, regardless of window and due time
+
+# Params
+
+id string: valve id
+
+# Returns
+
+r Result: result of exercise
+
+err error: error if the valve is unknown or being exercised
+*/
+func (s *Scheduler) Run(id string) (r Result, err error) {
+	r, err = s.run(id, false)
+	return
+}
+
+/*
+get exercise state of a valve
+
+# Params
+
+id string: valve id
+
+# Returns
+
+st Status: state
+
+err error: error if the valve is unknown
+*/
+func (s *Scheduler) GetStatus(id string) (st Status, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	v, ok := s.valves[id]
+	if !ok {
+		err = fmt.Errorf("unknown valve %s", id)
+		return
+	}
+	st = v.status
+	return
+}
+
+/*
+get recorded results, ordered by time
+
+# Returns
+
+results []Result: results, at most Retention of them
+*/
+func (s *Scheduler) Results() (results []Result) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	results = append(results, s.results...)
+	return
+}
+
+/*
+check if a time is in the maintenance window
+
+# Params
+
+t time.Time: time to check
+
+# Returns
+
+in bool: true in the window
+*/
+func (s *Scheduler) InWindow(t time.Time) (in bool) {
+	s.mtx.Lock()
+	cfg := s.cfg
+	s.mtx.Unlock()
+	return inWindow(cfg, t)
+}
+
+// InWindow with a configuration
+func inWindow(cfg Config, t time.Time) (in bool) {
+	t = t.In(cfg.Location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, cfg.Location)
+	offset := t.Sub(midnight)
+	w := cfg.Window
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	// window over midnight
+	return offset >= w.Start || offset < w.End
+}
+
+// exercise a valve without holding the lock and record the result, only if it is due when onlyDue
+func (s *Scheduler) run(id string, onlyDue bool) (r Result, err error) {
+	s.mtx.Lock()
+	cfg := s.cfg
+	v, ok := s.valves[id]
+	switch {
+	case !ok:
+		err = fmt.Errorf("unknown valve %s", id)
+	case v.busy:
+		err = fmt.Errorf("valve %s is being exercised", id)
+	case onlyDue && v.status.Due.After(cfg.Now()):
+		err = fmt.Errorf("valve %s is not due", id)
+	}
+	if err != nil {
+		s.mtx.Unlock()
+		return
+	}
+	v.busy = true
+	s.mtx.Unlock()
+
+	r = Result{ID: id, Start: cfg.Now()}
+	r.Outcome, r.Err = cycle(v, cfg)
+	r.End = cfg.Now()
+
+	s.mtx.Lock()
+	v.busy = false
+	v.status.LastRun, v.status.LastResult = r.Start, r
+	if r.Outcome == OUTCOME_OK {
+		v.status.Failures = 0
+		v.status.Due = r.Start.Add(cfg.Interval)
+	} else {
+		if r.Outcome != OUTCOME_SKIPPED {
+			v.status.Failures++
+		}
+		v.status.Due = r.Start.Add(24 * time.Hour)
+	}
+	s.results = append(s.results, r)
+	if len(s.results) > s.cfg.Retention {
+		s.results = append([]Result{}, s.results[len(s.results)-s.cfg.Retention:]...)
+	}
+	onResult := s.cfg.OnResult
+	s.mtx.Unlock()
+	if onResult != nil {
+		onResult(r)
+	}
+	return
+}
+
+// close and reopen a valve which is open
+func cycle(v *valve, cfg Config) (outcome uint8, err error) {
+	var open bool
+	open, err = v.wm.GetValve(v.turn)
+	if err != nil {
+		outcome = OUTCOME_SKIPPED
+		err = fmt.Errorf("failed to read valve status: %w", err)
+		return
+	}
+	if !open {
+		// closed on purpose, such as by prepaid or leak shut-off
+		outcome = OUTCOME_SKIPPED
+		err = errors.New("valve is not open")
+		return
+	}
+	var closeErr error
+	if cfg.Mode == MODE_FULL {
+		closeErr = v.wm.SetValve(v.turn, false)
+		time.Sleep(cfg.PartialTime)
+	} else {
+		// stop waiting part way, the valve is reopened while travelling
+		var a meter.Actuation
+		a, closeErr = v.wm.ActuateValve(v.turn, false, func(a meter.Actuation) (stop bool) {
+			return a.Elapsed >= cfg.PartialTime
+		})
+		switch {
+		case closeErr == nil:
+			// closed fully within PartialTime
+		case a.Phase == meter.ACTUATION_STALLED && v.wm.ValveReportsTravel(v.turn):
+			// a seized valve never leaves opened, a healthy one reads closing
+			closeErr = fmt.Errorf("valve still opened after %s: %w", a.Elapsed, meter.ErrActuationStalled)
+		case errors.Is(closeErr, meter.ErrActuationStopped), errors.Is(closeErr, meter.ErrActuationTimeout), errors.Is(closeErr, meter.ErrActuationStalled):
+			// travelling part way is what a partial stroke expects, and a valve without travel states is not judged
+			closeErr = nil
+		}
+	}
+	err = reopen(v, cfg)
+	if err != nil {
+		outcome = OUTCOME_STUCK_CLOSED
+		err = fmt.Errorf("failed to reopen valve: %w", errors.Join(err, closeErr))
+		return
+	}
+	if closeErr != nil {
+		outcome = OUTCOME_CLOSE_FAILED
+		err = fmt.Errorf("failed to close valve: %w", closeErr)
+	}
+	return
+}
+
+// open valve, which is confirmed by status, retrying
+func reopen(v *valve, cfg Config) (err error) {
+	for attempt := 0; attempt < cfg.ReopenAttempts; attempt++ {
+		err = v.wm.SetValve(v.turn, true)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,210 @@
+package exercise
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// water meter with one valve, failing as set by the test
+type fakeMeter struct {
+	open   bool
+	travel bool
+	// valve status cannot be read
+	getErr error
+	// closing with SetValve fails
+	closeErr error
+	// last actuation and its error of ActuateValve, the valve leaves opened unless stalled
+	actuation meter.Actuation
+	actErr    error
+	// SetValve opening fails this many times
+	openFailures int
+	// attempts to open
+	opens int
+}
+
+func (m *fakeMeter) GetCapabilities() (caps meter.Capabilities) {
+	caps = meter.Capabilities{ModelName: "fake", ActuatorAmount: 1}
+	return
+}
+
+func (m *fakeMeter) GetValve(turn uint8) (stat bool, err error) {
+	stat, err = m.open, m.getErr
+	return
+}
+
+func (m *fakeMeter) SetValve(turn uint8, stat bool) (err error) {
+	if !stat {
+		if m.closeErr == nil {
+			m.open = false
+		}
+		return m.closeErr
+	}
+	m.opens++
+	if m.opens <= m.openFailures {
+		return errors.New("valve did not open")
+	}
+	m.open = true
+	return
+}
+
+func (m *fakeMeter) ActuateValve(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error) {
+	a, err = m.actuation, m.actErr
+	if a.Phase != meter.ACTUATION_STALLED {
+		m.open = stat
+	}
+	return
+}
+
+func (m *fakeMeter) ValveReportsTravel(turn uint8) (travel bool) {
+	return m.travel
+}
+
+func TestReopen(t *testing.T) {
+	errRead := errors.New("no response")
+	stalled := meter.Actuation{Phase: meter.ACTUATION_STALLED, Elapsed: time.Millisecond}
+	tests := []struct {
+		name    string
+		mode    uint8
+		meter   fakeMeter
+		outcome uint8
+		// if the valve is commanded at all
+		cycled bool
+	}{
+		{name: "full", meter: fakeMeter{open: true}, outcome: OUTCOME_OK, cycled: true},
+		{name: "closed on purpose", meter: fakeMeter{}, outcome: OUTCOME_SKIPPED},
+		{name: "status unreadable", meter: fakeMeter{open: true, getErr: errRead}, outcome: OUTCOME_SKIPPED},
+		{name: "close failed", meter: fakeMeter{open: true, closeErr: errRead}, outcome: OUTCOME_CLOSE_FAILED, cycled: true},
+		{name: "reopened on last attempt", meter: fakeMeter{open: true, openFailures: 2}, outcome: OUTCOME_OK, cycled: true},
+		{name: "stuck closed", meter: fakeMeter{open: true, openFailures: 3}, outcome: OUTCOME_STUCK_CLOSED, cycled: true},
+		{name: "stuck closed after close failed", meter: fakeMeter{open: true, closeErr: errRead, openFailures: 3}, outcome: OUTCOME_STUCK_CLOSED, cycled: true},
+		{
+			name: "partial closed", mode: MODE_PARTIAL,
+			meter:   fakeMeter{open: true, actuation: meter.Actuation{Phase: meter.ACTUATION_DONE}},
+			outcome: OUTCOME_OK, cycled: true,
+		},
+		{
+			name: "partial moving", mode: MODE_PARTIAL,
+			meter:   fakeMeter{open: true, travel: true, actuation: meter.Actuation{Phase: meter.ACTUATION_MOVING}, actErr: meter.ErrActuationStopped},
+			outcome: OUTCOME_OK, cycled: true,
+		},
+		{
+			name: "partial seized", mode: MODE_PARTIAL,
+			meter:   fakeMeter{open: true, travel: true, actuation: stalled, actErr: meter.ErrActuationStopped},
+			outcome: OUTCOME_CLOSE_FAILED, cycled: true,
+		},
+		{
+			name: "partial without travel states", mode: MODE_PARTIAL,
+			meter:   fakeMeter{open: true, actuation: stalled, actErr: meter.ErrActuationStopped},
+			outcome: OUTCOME_OK, cycled: true,
+		},
+		{
+			name: "partial without travel states timed out", mode: MODE_PARTIAL,
+			meter:   fakeMeter{open: true, actuation: stalled, actErr: meter.ErrActuationStalled},
+			outcome: OUTCOME_OK, cycled: true,
+		},
+		{
+			name: "partial fault", mode: MODE_PARTIAL,
+			meter:   fakeMeter{open: true, actuation: meter.Actuation{Phase: meter.ACTUATION_FAULT}, actErr: meter.ErrActuationFault},
+			outcome: OUTCOME_CLOSE_FAILED, cycled: true,
+		},
+		{
+			name: "partial fault stuck closed", mode: MODE_PARTIAL,
+			meter:   fakeMeter{open: true, actuation: meter.Actuation{Phase: meter.ACTUATION_FAULT}, actErr: meter.ErrActuationFault, openFailures: 3},
+			outcome: OUTCOME_STUCK_CLOSED, cycled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := tt.meter
+			wasOpen := wm.open
+			var s Scheduler
+			if err := s.Init(Config{Mode: tt.mode, PartialTime: time.Millisecond}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Add("101", &wm, 0); err != nil {
+				t.Fatal(err)
+			}
+			r, err := s.Run("101")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Outcome != tt.outcome || (r.Err == nil) != (tt.outcome == OUTCOME_OK) {
+				t.Fatalf("result %+v, want outcome %d", r, tt.outcome)
+			}
+			// a valve found open is open again unless reported stuck
+			if wasOpen && !wm.open && r.Outcome != OUTCOME_STUCK_CLOSED {
+				t.Fatalf("result %+v with valve left closed", r)
+			}
+			if !wasOpen && wm.open {
+				t.Fatal("valve closed on purpose opened")
+			}
+			if (wm.opens > 0) != tt.cycled || (r.Outcome == OUTCOME_STUCK_CLOSED && wm.opens != 3) {
+				t.Fatalf("%d attempts to open, want cycled %v", wm.opens, tt.cycled)
+			}
+		})
+	}
+}
+
+func TestPoll(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	night := time.Date(2026, 1, 5, 2, 0, 0, 0, cst)
+	now := night.Add(-time.Hour)
+	var s Scheduler
+	var reported []string
+	err := s.Init(Config{
+		MaxPerPoll:  2,
+		PartialTime: time.Millisecond,
+		Location:    cst,
+		Now:         func() time.Time { return now },
+		OnResult: func(r Result) {
+			// called without the lock held
+			s.GetStatus(r.ID)
+			reported = append(reported, r.ID)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valves := map[string]*fakeMeter{"a": {open: true}, "b": {open: true}, "c": {open: true, closeErr: errors.New("no response")}}
+	for id, wm := range valves {
+		if err = s.Add(id, wm, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	steps := []struct {
+		name string
+		at   time.Time
+		ids  []string
+	}{
+		{name: "before window", at: now},
+		{name: "window opens", at: night, ids: []string{"a", "b"}},
+		{name: "rest of due valves", at: night.Add(time.Minute), ids: []string{"c"}},
+		{name: "none due", at: night.Add(time.Hour)},
+		{name: "failed valve due next day", at: night.AddDate(0, 0, 1).Add(time.Minute), ids: []string{"c"}},
+		{name: "after window", at: night.AddDate(0, 0, 30).Add(2 * time.Hour)},
+		{name: "most overdue first", at: night.AddDate(0, 0, 31), ids: []string{"c", "a"}},
+	}
+	for _, st := range steps {
+		now = st.at
+		reported = nil
+		var ids []string
+		for _, r := range s.Poll() {
+			ids = append(ids, r.ID)
+		}
+		if len(ids) != len(st.ids) || len(reported) != len(st.ids) {
+			t.Fatalf("%s: exercised %v, reported %v, want %v", st.name, ids, reported, st.ids)
+		}
+		for i := range ids {
+			if ids[i] != st.ids[i] || reported[i] != st.ids[i] {
+				t.Fatalf("%s: exercised %v, reported %v, want %v", st.name, ids, reported, st.ids)
+			}
+		}
+	}
+	st, _ := s.GetStatus("c")
+	if st.Failures != 3 || st.LastResult.Outcome != OUTCOME_CLOSE_FAILED {
+		t.Fatalf("status of c %+v, want 3 failures", st)
+	}
+}
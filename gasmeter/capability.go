@@ -0,0 +1,77 @@
+/*
+ * @filename	capability.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Capability discovery of gas meter instance
+ * @comment		--
+ */
+
+package gasmeter
+
+import (
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+/*
+get capabilities of the meter instance, the instance needs to be initialized in advance
+
+# Returns
+
+caps meter.Capabilities: supported data items and valves
+*/
+func (gm *GasMeter) GetCapabilities() (caps meter.Capabilities) {
+	caps.Kind = meter.METER_KIND_GAS
+	if gm.modelMeta == nil {
+		return
+	}
+	caps.Model = gm.model
+	caps.ModelName = gm.modelMeta.name
+	for id := 0; id < len(gm.regMeta) && id < int(ID_DATA_ITEM_AMOUNT__); id++ {
+		if gm.regMeta[id].length == 0 {
+			continue
+		}
+		caps.Items = append(caps.Items, meter.ItemCapability{
+			ID:       uint8(id),
+			Name:     itemTable[id].Name,
+			Unit:     itemTable[id].Unit,
+			Kind:     itemTable[id].Kind,
+			Scale:    regio.Scale(gm.regMeta[id].override),
+			Signed:   gm.regMeta[id].hasSymbol,
+			Readable: gm.regMeta[id].readable,
+			Writable: gm.regMeta[id].writable,
+			Rollover: meter.Rollover(itemTable[id].Kind, regio.Span(gm.regMeta[id].reg())),
+		})
+	}
+	caps.ActuatorAmount = len(gm.valveMeta)
+	return
+}
+
+/*
+get fingerprints of the Modbus-RTU gas meter models, used to identify meters by gateway.Scan
+
+# Returns
+
+fps []gateway.Fingerprint: fingerprints of all Modbus-RTU models
+*/
+func Fingerprints() (fps []gateway.Fingerprint) {
+	for model, modelMeta := range modelMetas {
+		if modelMeta == nil {
+			continue
+		}
+		fps = append(fps, modelFingerprints(uint8(model), modelMeta)...)
+	}
+	return
+}
+
+// build fingerprints from plausible item ranges of a meter model
+func modelFingerprints(model uint8, modelMeta *ModelMeta) (fps []gateway.Fingerprint) {
+	for _, fm := range modelMeta.fingerprints {
+		if int(fm.id) >= len(modelMeta.regMeta) || modelMeta.regMeta[fm.id].length == 0 {
+			continue
+		}
+		fps = append(fps, regio.Fingerprint(meter.METER_KIND_GAS, model, modelMeta.name, modelMeta.regMeta[fm.id].reg(), fm.min, fm.max))
+	}
+	return
+}
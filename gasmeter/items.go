@@ -0,0 +1,76 @@
+/*
+ * @filename	items.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Names, units and descriptions of gas meter data items
+ * @comment		--
+ */
+
+package gasmeter
+
+import "github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+
+// description of data items ordered by item ID, such as ID_VOLUME_STANDARD
+var itemTable = meter.ItemTable{
+	ID_VOLUME_STANDARD: {Name: "volume_standard", Unit: "m^3", Kind: meter.KIND_VOLUME, Desc: "accumulated volume converted to base conditions"},
+	ID_VOLUME_WORKING:  {Name: "volume_working", Unit: "m^3", Kind: meter.KIND_VOLUME, Desc: "accumulated volume at working conditions"},
+	ID_FLOW_STANDARD:   {Name: "flow_standard", Unit: "m^3/h", Kind: meter.KIND_FLOW, Desc: "flow converted to base conditions"},
+	ID_FLOW_WORKING:    {Name: "flow_working", Unit: "m^3/h", Kind: meter.KIND_FLOW, Desc: "flow at working conditions"},
+	ID_TEMPERATURE:     {Name: "temperature", Unit: "degC", Kind: meter.KIND_TEMPERATURE, Desc: "gas temperature used for compensation"},
+	ID_PRESSURE:        {Name: "pressure", Unit: "kPa", Kind: meter.KIND_PRESSURE, Desc: "gas absolute pressure used for compensation"},
+	ID_BATTERY_VOLTAGE: {Name: "battery_voltage", Unit: "V", Kind: meter.KIND_VOLTAGE, Desc: "battery voltage"},
+	ID_BATTERY_LOW:     {Name: "battery_low", Unit: "", Kind: meter.KIND_STATUS, Desc: "battery low alarm"},
+	ID_TAMPER:          {Name: "tamper", Unit: "", Kind: meter.KIND_STATUS, Desc: "case opening or magnetic attack alarm"},
+}
+
+/*
+get description of data item
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+info meter.ItemInfo: item name, unit, quantity kind and description
+
+err error: error if item id is undefined
+*/
+func GetItemInfo(id uint8) (info meter.ItemInfo, err error) {
+	info, err = itemTable.Info(id)
+	return
+}
+
+/*
+format item id into its stable name, such as "volume_standard"
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+name string: item name, or "item#<id>" if item id is undefined
+*/
+func ItemName(id uint8) (name string) {
+	name = itemTable.Name(id)
+	return
+}
+
+/*
+parse item name into item id, case-insensitive
+
+# Params
+
+name string: item name, such as "volume_standard"
+
+# Returns
+
+id uint8: item id, one of macro ID_*
+
+err error: error if name matches no item
+*/
+func ParseItem(name string) (id uint8, err error) {
+	id, err = itemTable.Parse(name)
+	return
+}
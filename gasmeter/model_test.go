@@ -0,0 +1,103 @@
+package gasmeter
+
+import (
+	"testing"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// model of a made-up register table: standard volume in 2 registers with 3 decimals,
+// status word with battery low and tamper bits, and a motorised valve
+var specTest = ModelSpec{
+	Name:   "test",
+	Source: "register table of the test",
+	Regs: []RegSpec{
+		{ID: ID_VOLUME_STANDARD, Addr: 0x0000, Length: 2, Type: REGTYPE_INPUT, Scale: 0.001, Min: 0, Max: 999999},
+		{ID: ID_BATTERY_LOW, Addr: 0x0010, Length: 1, Type: REGTYPE_INPUT, BitMask: 0x0001},
+		{ID: ID_TAMPER, Addr: 0x0010, Length: 1, Type: REGTYPE_INPUT, BitMask: 0x0002},
+	},
+	Valves: []ValveSpec{{
+		CtlAddr: 0x0020, CtlType: REGTYPE_HOLDING, CloseCmd: 0x0000, OpenCmd: 0x0001,
+		StatusAddr: 0x0021, StatusType: REGTYPE_INPUT, StatusClosed: 0x0000, StatusOpened: 0x0001,
+		StatusStates: map[uint16]uint8{0x0002: VALVE_STAT_OPENING, 0x0003: VALVE_STAT_CLOSING, 0x00FF: VALVE_STAT_FAULT},
+	}},
+}
+
+// restore models registered before the test
+func keepModels(t *testing.T) {
+	saved := modelMetas
+	t.Cleanup(func() { modelMetas = saved })
+}
+
+func TestRegisterModel(t *testing.T) {
+	keepModels(t)
+	model, err := RegisterModel(specTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source, err := ModelSource(model); err != nil || source != specTest.Source {
+		t.Fatalf("source %q, err %v", source, err)
+	}
+	gm := GasMeter{model: model, modelMeta: modelMetas[model], regMeta: modelMetas[model].regMeta, valveMeta: modelMetas[model].valveMeta}
+	caps := gm.GetCapabilities()
+	if caps.ModelName != "test" || len(caps.Items) != 3 || caps.ActuatorAmount != 1 {
+		t.Fatalf("capabilities %+v", caps)
+	}
+	for _, item := range caps.Items {
+		want := 0.0
+		if item.ID == ID_VOLUME_STANDARD {
+			want = 4294967.296
+		}
+		if item.Writable || item.Rollover != want {
+			t.Errorf("%s: writable %v, rollover %g, want %g", item.Name, item.Writable, item.Rollover, want)
+		}
+	}
+	if !gm.ValveReportsTravel(VALVE_TURN_1) {
+		t.Error("motorised valve does not report travel")
+	}
+	if fps := Fingerprints(); len(fps) != 1 || fps[0].Model != model || fps[0].Kind != meter.METER_KIND_GAS {
+		t.Fatalf("fingerprints %+v", fps)
+	}
+	if _, err = ModelSource(model + 1); err == nil {
+		t.Fatal("no error getting source of unregistered model")
+	}
+}
+
+func TestRegisterModelRejects(t *testing.T) {
+	keepModels(t)
+	reg := func(rs RegSpec) ModelSpec {
+		return ModelSpec{Name: "bad", Source: "test", Regs: []RegSpec{rs}}
+	}
+	valve := func(vs ValveSpec) ModelSpec {
+		return ModelSpec{Name: "bad", Source: "test", Valves: []ValveSpec{vs}}
+	}
+	volume := RegSpec{ID: ID_VOLUME_STANDARD, Length: 2, Type: REGTYPE_INPUT}
+	tests := []struct {
+		name string
+		spec ModelSpec
+	}{
+		{name: "no name", spec: ModelSpec{Source: "test", Regs: []RegSpec{volume}}},
+		{name: "no source", spec: ModelSpec{Name: "bad", Regs: []RegSpec{volume}}},
+		{name: "no registers", spec: ModelSpec{Name: "bad", Source: "test"}},
+		{name: "undefined item", spec: reg(RegSpec{ID: ID_DATA_ITEM_AMOUNT__, Length: 1, Type: REGTYPE_INPUT})},
+		{name: "zero length", spec: reg(RegSpec{ID: ID_PRESSURE, Type: REGTYPE_INPUT})},
+		{name: "coil", spec: reg(RegSpec{ID: ID_TAMPER, Length: 1, Type: REGTYPE_COIL})},
+		{name: "writable input", spec: reg(RegSpec{ID: ID_PRESSURE, Length: 1, Type: REGTYPE_INPUT, Writable: true})},
+		{name: "flag of value", spec: reg(RegSpec{ID: ID_PRESSURE, Length: 1, Type: REGTYPE_INPUT, BitMask: 0x0001})},
+		{name: "empty range", spec: reg(RegSpec{ID: ID_PRESSURE, Length: 1, Type: REGTYPE_INPUT, Min: 200, Max: 80})},
+		{name: "item twice", spec: ModelSpec{Name: "bad", Source: "test", Regs: []RegSpec{volume, volume}}},
+		{name: "input control", spec: valve(ValveSpec{CtlType: REGTYPE_INPUT, StatusType: REGTYPE_COIL})},
+		{name: "states of coil", spec: valve(ValveSpec{CtlType: REGTYPE_COIL, StatusType: REGTYPE_COIL, StatusStates: map[uint16]uint8{2: VALVE_STAT_FAULT}})},
+		{name: "same status", spec: valve(ValveSpec{CtlType: REGTYPE_COIL, StatusType: REGTYPE_INPUT})},
+		{name: "state of opened value", spec: valve(ValveSpec{CtlType: REGTYPE_COIL, StatusType: REGTYPE_INPUT, StatusOpened: 1, StatusStates: map[uint16]uint8{1: VALVE_STAT_OPENING}})},
+		{name: "opened as other state", spec: valve(ValveSpec{CtlType: REGTYPE_COIL, StatusType: REGTYPE_INPUT, StatusOpened: 1, StatusStates: map[uint16]uint8{2: VALVE_STAT_OPENED}})},
+	}
+	for _, tt := range tests {
+		if model, err := RegisterModel(tt.spec); err == nil {
+			t.Errorf("%s: registered model %d, want error", tt.name, model)
+		}
+	}
+	if len(modelMetas) != 0 {
+		t.Fatalf("%d models registered", len(modelMetas))
+	}
+}
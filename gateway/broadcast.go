@@ -0,0 +1,117 @@
+/*
+ * @filename	broadcast.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Modbus-RTU broadcast writes to all meters on gateway
+ * @comment		broadcast frames are sent raw, slaves never answer unit 0 and Modbus clients would wait until time-out
+ */
+
+package gateway
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Modbus-RTU function codes of writes which may be broadcast
+const (
+	FUNC_WRITE_SINGLE_COIL        byte = 0x05
+	FUNC_WRITE_SINGLE_REGISTER    byte = 0x06
+	FUNC_WRITE_MULTIPLE_COILS     byte = 0x0F
+	FUNC_WRITE_MULTIPLE_REGISTERS byte = 0x10
+)
+
+// turnaround delay after broadcast, letting all slaves process the request before the bus is used again,
+// the upper end of 100 - 200 ms given by Modbus over serial line
+const BROADCAST_TURNAROUND = 200 * time.Millisecond
+
+/*
+write holding registers of all meters on the gateway at once by Modbus-RTU broadcast, no response is expected
+
+the result is unknown to the master, read back each meter to verify
+
+# Params
+
+addr uint16: register address
+
+values []uint16: register values, 1 - 123 registers
+
+# Returns
+
+err error: error if the frame could not be sent
+*/
+func (gw *MBRTGateway) BroadcastRegisters(addr uint16, values []uint16) (err error) {
+	if len(values) == 0 || len(values) > 123 {
+		err = errors.New("invalid register amount for broadcast, 1 - 123 expected")
+		return
+	}
+	var pdu []byte
+	if len(values) == 1 {
+		pdu = []byte{FUNC_WRITE_SINGLE_REGISTER, byte(addr >> 8), byte(addr), byte(values[0] >> 8), byte(values[0])}
+	} else {
+		pdu = []byte{FUNC_WRITE_MULTIPLE_REGISTERS, byte(addr >> 8), byte(addr), 0, byte(len(values)), byte(len(values) * 2)}
+		for _, v := range values {
+			pdu = append(pdu, byte(v>>8), byte(v))
+		}
+	}
+	err = gw.broadcast(pdu)
+	return
+}
+
+/*
+write a coil of all meters on the gateway at once by Modbus-RTU broadcast, no response is expected
+
+the result is unknown to the master, read back each meter to verify
+
+# Params
+
+addr uint16: coil address
+
+val bool: coil value
+
+# Returns
+
+err error: error if the frame could not be sent
+*/
+func (gw *MBRTGateway) BroadcastCoil(addr uint16, val bool) (err error) {
+	pdu := []byte{FUNC_WRITE_SINGLE_COIL, byte(addr >> 8), byte(addr), 0x00, 0x00}
+	if val {
+		pdu[3] = 0xFF
+	}
+	err = gw.broadcast(pdu)
+	return
+}
+
+// send PDU to unit 0 and wait for slaves to process it
+func (gw *MBRTGateway) broadcast(pdu []byte) (err error) {
+	if strings.HasPrefix(gw.netAddr, SCHEME_MBUS_OVER_TCP) {
+		err = errors.New("gateway carries raw M-Bus frames only, no Modbus-RTU to broadcast")
+		return
+	}
+	frame := append([]byte{0x00}, pdu...)
+	crc := crc16(frame)
+	frame = append(frame, byte(crc), byte(crc>>8))
+	_, err = gw.Transact(frame, nil)
+	if err != nil {
+		return
+	}
+	time.Sleep(BROADCAST_TURNAROUND)
+	return
+}
+
+// Modbus-RTU CRC-16, polynomial 0xA001 reflected, sent low byte first
+func crc16(buf []byte) (crc uint16) {
+	crc = 0xFFFF
+	for _, b := range buf {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return
+}
@@ -3,6 +3,7 @@ package gateway
 import (
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -10,6 +11,9 @@ import (
 	"github.com/kontornl/modbus"
 )
 
+// gateway address scheme of M-Bus level converters, which carry raw frames only and have no Modbus client
+const SCHEME_MBUS_OVER_TCP = "mbusovertcp://"
+
 /*
 initialize gateway instance and establish TCP connection
 
@@ -17,7 +21,7 @@ multiple meters may connected to one gateway and identified by their slave addre
 
 # Params
 
-netAddr string: the gateway to connect to, string format is rtuovertcp://<ip>:<port>
+netAddr string: the gateway to connect to, string format is rtuovertcp://<ip>:<port>, or mbusovertcp://<ip>:<port> for M-Bus level converters which carry raw frames only
 
 baudRate uint: serial baud rate which is already set to gateway
 
@@ -34,6 +38,18 @@ func (gw *MBRTGateway) Reinit() (err error) {
 }
 func (gw *MBRTGateway) Init(netAddr string, baudRate uint, timeout time.Duration) (err error) {
 	var cli *modbus.ModbusClient
+	if strings.HasPrefix(netAddr, SCHEME_MBUS_OVER_TCP) {
+		gw.closeRaw()
+		if gw.cli != nil {
+			gw.cli.Close()
+			gw.cli = nil
+		}
+		gw.netAddr = netAddr
+		gw.BaudRate = baudRate
+		gw.Timeout = timeout
+		err = gw.openRaw()
+		return
+	}
 	if gw.cli != nil {
 		if gw.BaudRate != baudRate || gw.Timeout != timeout {
 			// (23/07/2024 kontornl) may cause memory leak without deleting, need inspection
@@ -43,13 +59,20 @@ func (gw *MBRTGateway) Init(netAddr string, baudRate uint, timeout time.Duration
 			time.Sleep(100 * time.Millisecond)
 		}
 	}
+	// raw link is kept along with Modbus client unless it leads to another gateway
+	if netAddr != gw.netAddr {
+		gw.closeRaw()
+	}
 	gw.netAddr = netAddr
 	gw.BaudRate = baudRate
 	gw.Timeout = timeout
 	cli, err = modbus.NewClient(&modbus.ClientConfiguration{
-		URL:     netAddr,
-		Speed:   baudRate,
-		Timeout: timeout,
+		URL:      netAddr,
+		Speed:    baudRate,
+		DataBits: gw.DataBits,
+		Parity:   gw.Parity,
+		StopBits: gw.StopBits,
+		Timeout:  timeout,
 	})
 	if err != nil {
 		return
@@ -93,6 +116,19 @@ func (gw *MBRTGateway) GetClient() (cli *modbus.ModbusClient) {
 	return
 }
 
+func (gw *MBRTGateway) GetNetAddr() (netAddr string) {
+	netAddr = gw.netAddr
+	return
+}
+
+/*
+get lock of the bus, taken by Transact, fingerprint reads of Scan and register I/O of meters for each transaction,
+so it must not be held while calling them
+
+# Returns
+
+mtx *sync.RWMutex: lock of the bus
+*/
 func (gw *MBRTGateway) GetLock() (mtx *sync.RWMutex) {
 	mtx = &gw.mtx
 	return
@@ -101,10 +137,14 @@ func (gw *MBRTGateway) GetLock() (mtx *sync.RWMutex) {
 type MBRTGateway struct {
 	cli      *modbus.ModbusClient
 	BaudRate uint
+	DataBits uint
+	Parity   uint
+	StopBits uint
 	netAddr  string
 	Timeout  time.Duration
 	mtx      sync.RWMutex
 	LastErr  error
+	raw      net.Conn
 }
 
 type IMBRTGateway interface {
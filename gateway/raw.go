@@ -0,0 +1,81 @@
+/*
+ * @filename	raw.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Raw frame transport over gateway for protocols other than Modbus-RTU
+ * @comment		raw frames take a TCP connection of their own beside the Modbus client, the gateway lock keeps both off the bus at once
+ */
+
+package gateway
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+/*
+send a raw frame through the gateway and read the response, for protocols such as DL/T 645 sharing the bus,
+over a connection of its own beside the Modbus client, holding the gateway lock so that both never talk on the bus at once
+
+# Params
+
+req []byte: request frame to send, including preamble if required
+
+readResp func(r io.Reader) (resp []byte, err error): reads exactly one response frame from the link, nil if no response expected
+
+# Returns
+
+resp []byte: response frame
+
+err error: error, the raw link is closed on any error and reopened on next call
+*/
+func (gw *MBRTGateway) Transact(req []byte, readResp func(r io.Reader) (resp []byte, err error)) (resp []byte, err error) {
+	gw.mtx.Lock()
+	defer gw.mtx.Unlock()
+	if gw.raw == nil {
+		err = gw.openRaw()
+		if err != nil {
+			return
+		}
+	}
+	// drop anything left over from previous transactions or bus noise
+	gw.raw.SetReadDeadline(time.Now().Add(time.Millisecond))
+	io.Copy(io.Discard, gw.raw)
+	gw.raw.SetDeadline(time.Now().Add(gw.Timeout))
+	_, err = gw.raw.Write(req)
+	if err == nil && readResp != nil {
+		resp, err = readResp(gw.raw)
+	}
+	gw.LastErr = err
+	if err != nil {
+		gw.closeRaw()
+	}
+	return
+}
+
+// dial the gateway for raw frames
+func (gw *MBRTGateway) openRaw() (err error) {
+	var u *url.URL
+	u, err = url.Parse(gw.netAddr)
+	if err != nil {
+		return
+	}
+	if u.Host == "" {
+		err = errors.New("invalid gateway address")
+		return
+	}
+	gw.raw, err = net.DialTimeout("tcp", u.Host, gw.Timeout)
+	gw.LastErr = err
+	return
+}
+
+// close raw link if opened
+func (gw *MBRTGateway) closeRaw() {
+	if gw.raw != nil {
+		gw.raw.Close()
+		gw.raw = nil
+	}
+}
@@ -0,0 +1,232 @@
+/*
+ * @filename	scan.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Scan of Modbus-RTU bus to discover meters and identify their models
+ * @comment		--
+ */
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kontornl/modbus"
+)
+
+// fingerprint of meter model, a register which the model answers with a plausible value
+type Fingerprint struct {
+	// meter kind, using macro meter.METER_KIND_*
+	Kind uint8
+	// meter model id, using macro METER_MODEL_* of the meter kind package
+	Model uint8
+	// human readable model name
+	ModelName string
+	// register address
+	RegAddr uint16
+	// number of registers to read
+	Length uint16
+	// if the register is a coil rather than a register
+	Coil bool
+	// register type if not a coil
+	RegType modbus.RegType
+	// check of the register values, coils are given as 0 or 1, nil accepts any answer
+	Match func(regval []uint16) bool
+}
+
+// meter found by scan
+type ScanResult struct {
+	// slave address which answered
+	SlaveAddr uint8
+	// if the meter is identified by any fingerprint
+	Identified bool
+	// meter kind of the most likely model, using macro meter.METER_KIND_*
+	Kind uint8
+	// the most likely meter model, using macro METER_MODEL_* of the meter kind package
+	Model uint8
+	// human readable name of the most likely model
+	ModelName string
+	// number of fingerprints of the most likely model which matched
+	Score int
+	// number of fingerprints of the most likely model which were tried
+	Tried int
+}
+
+// progress of scan, given to callback after each address is probed
+type ScanProgress struct {
+	// address just probed
+	SlaveAddr uint8
+	// number of addresses probed
+	Done int
+	// number of addresses to probe
+	Total int
+	// result of the address, nil if nothing answered
+	Result *ScanResult
+}
+
+/*
+scan slave addresses on the bus, probing fingerprint registers of known models with a short time-out
+
+the gateway is reinitialized with probeTimeout during scan and restored after it
+
+# Params
+
+from uint8: first slave address to probe
+
+to uint8: last slave address to probe
+
+fingerprints []Fingerprint: fingerprints of known models, such as powermeter.Fingerprints()
+
+probeTimeout time.Duration: time-out of each probe, such as 200 ms
+
+progress func(p ScanProgress): called after each address is probed, could be nil
+
+# Returns
+
+results []ScanResult: meters which answered, ordered by slave address
+
+err error: error if the gateway is lost during scan
+*/
+func (gw *MBRTGateway) Scan(from uint8, to uint8, fingerprints []Fingerprint, probeTimeout time.Duration, progress func(p ScanProgress)) (results []ScanResult, err error) {
+	if from == 0 || to < from || to > 247 {
+		err = fmt.Errorf("invalid slave address range %d - %d, 1 - 247 expected", from, to)
+		return
+	}
+	if strings.HasPrefix(gw.netAddr, SCHEME_MBUS_OVER_TCP) {
+		err = errors.New("gateway carries raw M-Bus frames only, no Modbus-RTU to probe")
+		return
+	}
+	if len(fingerprints) == 0 {
+		err = errors.New("no fingerprint to probe")
+		return
+	}
+	timeout := gw.Timeout
+	err = gw.Init(gw.netAddr, gw.BaudRate, probeTimeout)
+	if err != nil {
+		return
+	}
+	defer func() {
+		restoreErr := gw.Init(gw.netAddr, gw.BaudRate, timeout)
+		if err == nil {
+			err = restoreErr
+		}
+	}()
+	total := int(to) - int(from) + 1
+	for addr := int(from); addr <= int(to); addr++ {
+		var res *ScanResult
+		res, err = gw.probe(uint8(addr), fingerprints)
+		if err != nil {
+			return
+		}
+		if res != nil {
+			results = append(results, *res)
+		}
+		if progress != nil {
+			progress(ScanProgress{
+				SlaveAddr: uint8(addr),
+				Done:      addr - int(from) + 1,
+				Total:     total,
+				Result:    res,
+			})
+		}
+	}
+	return
+}
+
+// probe one slave address with all fingerprints, res is nil if nothing answered
+func (gw *MBRTGateway) probe(slaveAddr uint8, fingerprints []Fingerprint) (res *ScanResult, err error) {
+	type modelKey struct {
+		kind  uint8
+		model uint8
+	}
+	scores := map[modelKey]*ScanResult{}
+	var order []modelKey
+	answered := false
+	for i := range fingerprints {
+		fp := &fingerprints[i]
+		var regval []uint16
+		var probeErr error
+		regval, probeErr = gw.readFingerprint(slaveAddr, fp)
+		if probeErr != nil && !IsSlaveAnswer(probeErr) {
+			if !IsTimeout(probeErr) {
+				// link may be broken rather than slave absent, try once to get it back
+				err = gw.Reconnect()
+				if err != nil {
+					return
+				}
+			}
+			continue
+		}
+		answered = true
+		key := modelKey{fp.Kind, fp.Model}
+		sr, ok := scores[key]
+		if !ok {
+			sr = &ScanResult{SlaveAddr: slaveAddr, Identified: true, Kind: fp.Kind, Model: fp.Model, ModelName: fp.ModelName}
+			scores[key] = sr
+			order = append(order, key)
+		}
+		sr.Tried++
+		if probeErr == nil && (fp.Match == nil || fp.Match(regval)) {
+			sr.Score++
+		}
+	}
+	if !answered {
+		return
+	}
+	res = &ScanResult{SlaveAddr: slaveAddr}
+	for _, key := range order {
+		sr := scores[key]
+		if sr.Score == 0 {
+			continue
+		}
+		// prefer more matches, then fewer misses
+		if sr.Score > res.Score || sr.Score == res.Score && sr.Tried < res.Tried {
+			res = sr
+		}
+	}
+	return
+}
+
+// read fingerprint register of slave
+func (gw *MBRTGateway) readFingerprint(slaveAddr uint8, fp *Fingerprint) (regval []uint16, err error) {
+	gw.mtx.Lock()
+	defer gw.mtx.Unlock()
+	if gw.cli == nil {
+		err = gw.Reinit()
+		if err != nil {
+			return
+		}
+	}
+	gw.cli.SetUnitId(slaveAddr)
+	if fp.Coil {
+		var coils []bool
+		coils, err = gw.cli.ReadCoils(fp.RegAddr, fp.Length)
+		for _, c := range coils {
+			if c {
+				regval = append(regval, 1)
+			} else {
+				regval = append(regval, 0)
+			}
+		}
+		return
+	}
+	regval, err = gw.cli.ReadRegisters(fp.RegAddr, fp.Length, fp.RegType)
+	return
+}
+
+// if error is a Modbus exception response, which proves that a slave is present at the address
+func IsSlaveAnswer(err error) bool {
+	return errors.Is(err, modbus.ErrIllegalFunction) ||
+		errors.Is(err, modbus.ErrIllegalDataAddress) ||
+		errors.Is(err, modbus.ErrIllegalDataValue) ||
+		errors.Is(err, modbus.ErrServerDeviceFailure) ||
+		errors.Is(err, modbus.ErrServerDeviceBusy)
+}
+
+// if error is a time-out, which means that no slave answered
+func IsTimeout(err error) bool {
+	return errors.Is(err, modbus.ErrRequestTimedOut)
+}
@@ -0,0 +1,236 @@
+/*
+ * @filename	serial.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Detection of serial parameters of Modbus-RTU bus
+ * @comment		--
+ */
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kontornl/modbus"
+)
+
+// serial line parameters of gateway
+type SerialParams struct {
+	// baud rate, such as 9600
+	BaudRate uint
+	// data bits, 0 for default of 8
+	DataBits uint
+	// parity, using macro modbus.PARITY_*
+	Parity uint
+	// stop bits, 0 for default depending on parity
+	StopBits uint
+}
+
+// gateway address scheme of local serial ports, such as rtu:///dev/ttyUSB0, whose settings are applied by the client
+const SCHEME_RTU = "rtu://"
+
+// baud rates tried by DetectSerial when no candidate is given, Modbus over serial line requires 9600 and 19200, DL/T 645 defaults to 2400
+var DefaultBaudRates = []uint{9600, 2400, 4800, 19200, 1200, 38400, 57600, 115200}
+
+// parities tried by DetectSerial when no candidate is given, even first as the Modbus over serial line default
+var DefaultParities = []uint{modbus.PARITY_EVEN, modbus.PARITY_NONE, modbus.PARITY_ODD}
+
+/*
+apply serial parameters to the serial port of the gateway, such as by its vendor configuration API
+
+transparent TCP gateways keep their serial settings to themselves, so candidates change nothing on the wire without it
+*/
+type SerialConfigurer func(params SerialParams) (err error)
+
+// result of DetectSerial
+type SerialDetection struct {
+	// serial parameters which the slave answered with
+	Params SerialParams
+	// fingerprint which matched
+	Fingerprint Fingerprint
+	// if the serial port of gateway is reconfigured by the configurer, false for local serial ports set by the client
+	Reconfigured bool
+	// number of candidates tried
+	Tried int
+}
+
+// get serial parameters currently set to gateway
+func (gw *MBRTGateway) GetSerialParams() (params SerialParams) {
+	params = SerialParams{
+		BaudRate: gw.BaudRate,
+		DataBits: gw.DataBits,
+		Parity:   gw.Parity,
+		StopBits: gw.StopBits,
+	}
+	return
+}
+
+/*
+set serial parameters and reinitialize gateway
+
+# Params
+
+params SerialParams: serial parameters
+
+# Returns
+
+err error: error
+*/
+func (gw *MBRTGateway) SetSerialParams(params SerialParams) (err error) {
+	if gw.cli != nil {
+		// Init only closes the client when baud rate or timeout changes, but always opens a new one
+		gw.cli.Close()
+		gw.cli = nil
+	}
+	gw.DataBits = params.DataBits
+	gw.Parity = params.Parity
+	gw.StopBits = params.StopBits
+	err = gw.Init(gw.netAddr, params.BaudRate, gw.Timeout)
+	return
+}
+
+/*
+build candidates of serial parameters from baud rates and parities, 8 data bits and default stop bits
+
+# Params
+
+baudRates []uint: baud rates, DefaultBaudRates if nil
+
+parities []uint: parities using macro modbus.PARITY_*, DefaultParities if nil
+
+# Returns
+
+candidates []SerialParams: all combinations, ordered by baud rate first
+*/
+func SerialCandidates(baudRates []uint, parities []uint) (candidates []SerialParams) {
+	if baudRates == nil {
+		baudRates = DefaultBaudRates
+	}
+	if parities == nil {
+		parities = DefaultParities
+	}
+	for _, baudRate := range baudRates {
+		for _, parity := range parities {
+			candidates = append(candidates, SerialParams{BaudRate: baudRate, DataBits: 8, Parity: parity})
+		}
+	}
+	return
+}
+
+/*
+detect serial parameters by trying candidates against a known slave, until it answers a fingerprint register
+
+a plausible value is preferred, otherwise any well-formed answer including exception response proves the line
+
+the settings found are kept on the gateway, or the original settings are restored if nothing answered
+
+# Params
+
+slaveAddr uint8: slave address of a meter known to be on the bus
+
+fingerprints []Fingerprint: readable registers from model metadata, such as powermeter.Fingerprints()
+
+candidates []SerialParams: serial parameters to try in order, SerialCandidates(nil, nil) if nil
+
+configure SerialConfigurer: applies each candidate to the serial port of gateway, could be nil for local serial ports only
+
+probeTimeout time.Duration: time-out of each probe, such as 300 ms, slow baud rates need longer
+
+# Returns
+
+det SerialDetection: working serial parameters
+
+err error: error if nothing answered, the gateway is lost, or a gateway over TCP has no configurer
+*/
+func (gw *MBRTGateway) DetectSerial(slaveAddr uint8, fingerprints []Fingerprint, candidates []SerialParams, configure SerialConfigurer, probeTimeout time.Duration) (det SerialDetection, err error) {
+	if slaveAddr == 0 || slaveAddr > 247 {
+		err = fmt.Errorf("invalid slave address %d for detection, 1 - 247 expected", slaveAddr)
+		return
+	}
+	if strings.HasPrefix(gw.netAddr, SCHEME_MBUS_OVER_TCP) {
+		err = errors.New("gateway carries raw M-Bus frames only, no Modbus-RTU to probe")
+		return
+	}
+	if len(fingerprints) == 0 {
+		err = errors.New("no fingerprint to probe")
+		return
+	}
+	if configure == nil && !strings.HasPrefix(gw.netAddr, SCHEME_RTU) {
+		// whichever candidate answers, it is the setting of the gateway which did
+		err = errors.New("serial parameters of gateway over TCP can not be detected without a configurer")
+		return
+	}
+	if candidates == nil {
+		candidates = SerialCandidates(nil, nil)
+	}
+	orig := gw.GetSerialParams()
+	timeout := gw.Timeout
+	found := false
+	defer func() {
+		if found {
+			return
+		}
+		// keep the gateway as it was
+		if configure != nil {
+			configure(orig)
+		}
+		gw.Timeout = timeout
+		gw.SetSerialParams(orig)
+	}()
+	gw.Timeout = probeTimeout
+	for _, params := range candidates {
+		det.Tried++
+		if configure != nil {
+			err = configure(params)
+			if err != nil {
+				err = fmt.Errorf("failed to configure gateway to %d baud: %w", params.BaudRate, err)
+				return
+			}
+		}
+		err = gw.SetSerialParams(params)
+		if err != nil {
+			return
+		}
+		// any well-formed answer proves the line, a plausible value proves it best
+		answered := -1
+		matched := -1
+		for i := range fingerprints {
+			fp := &fingerprints[i]
+			regval, probeErr := gw.readFingerprint(slaveAddr, fp)
+			if probeErr != nil && !IsSlaveAnswer(probeErr) {
+				if !IsTimeout(probeErr) {
+					// garbled frame from wrong parameters may leave the link in a bad state
+					err = gw.Reconnect()
+					if err != nil {
+						return
+					}
+				}
+				continue
+			}
+			if answered < 0 {
+				answered = i
+			}
+			if probeErr == nil && (fp.Match == nil || fp.Match(regval)) {
+				matched = i
+				break
+			}
+		}
+		if matched < 0 {
+			matched = answered
+		}
+		if matched < 0 {
+			continue
+		}
+		found = true
+		det.Params = params
+		det.Fingerprint = fingerprints[matched]
+		det.Reconfigured = configure != nil
+		err = gw.Init(gw.netAddr, gw.BaudRate, timeout)
+		return
+	}
+	err = fmt.Errorf("slave %d did not answer with any of %d serial parameter candidates", slaveAddr, len(candidates))
+	return
+}
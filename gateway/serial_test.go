@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectSerialRejects(t *testing.T) {
+	fps := []Fingerprint{{RegAddr: 0, Length: 1}}
+	configure := func(params SerialParams) (err error) { return }
+	tests := []struct {
+		name      string
+		netAddr   string
+		slaveAddr uint8
+		fps       []Fingerprint
+		configure SerialConfigurer
+	}{
+		{name: "broadcast address", netAddr: "rtu:///dev/ttyUSB0", slaveAddr: 0, fps: fps},
+		{name: "M-Bus converter", netAddr: SCHEME_MBUS_OVER_TCP + "192.168.1.12:8802", slaveAddr: 1, fps: fps, configure: configure},
+		{name: "no fingerprint", netAddr: "rtu:///dev/ttyUSB0", slaveAddr: 1},
+		// candidates would change nothing on the wire, the gateway answers with its own settings
+		{name: "TCP without configurer", netAddr: "rtuovertcp://192.168.1.12:8802", slaveAddr: 1, fps: fps},
+	}
+	for _, tt := range tests {
+		gw := MBRTGateway{netAddr: tt.netAddr, BaudRate: 9600, Timeout: time.Second}
+		det, err := gw.DetectSerial(tt.slaveAddr, tt.fps, nil, tt.configure, 300*time.Millisecond)
+		if err == nil || det.Tried != 0 {
+			t.Errorf("%s: detected %+v, want error before probing", tt.name, det)
+		}
+		if gw.GetSerialParams().BaudRate != 9600 || gw.Timeout != time.Second {
+			t.Errorf("%s: gateway changed to %+v", tt.name, gw.GetSerialParams())
+		}
+	}
+}
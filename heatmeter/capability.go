@@ -0,0 +1,99 @@
+/*
+ * @filename	capability.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Capability discovery of heat meter instance
+ * @comment		--
+ */
+
+package heatmeter
+
+import (
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/cjt188"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+/*
+get capabilities of the meter instance, the instance needs to be initialized in advance
+
+# Returns
+
+caps meter.Capabilities: supported data items and valves
+*/
+func (hm *HeatMeter) GetCapabilities() (caps meter.Capabilities) {
+	caps.Kind = meter.METER_KIND_HEAT
+	if hm.modelMeta == nil {
+		return
+	}
+	caps.Model = hm.model
+	caps.ModelName = hm.modelMeta.name
+	caps.Protocol = hm.modelMeta.protocol
+	if hm.isCJT188() {
+		for id := 0; id < int(ID_DATA_ITEM_AMOUNT__); id++ {
+			scale, ok := cjtItemScale[uint8(id)]
+			if !ok {
+				continue
+			}
+			caps.Items = append(caps.Items, meter.ItemCapability{
+				ID:       uint8(id),
+				Name:     itemTable[id].Name,
+				Unit:     itemTable[id].Unit,
+				Kind:     itemTable[id].Kind,
+				Scale:    scale,
+				Signed:   id == int(ID_TEMP_DIFF),
+				Rollover: meter.Rollover(itemTable[id].Kind, cjt188.COUNTER_SPAN),
+				Readable: true,
+			})
+		}
+		caps.ActuatorAmount = hm.modelMeta.cjtValveAmount
+		return
+	}
+	for id := 0; id < len(hm.regMeta) && id < int(ID_DATA_ITEM_AMOUNT__); id++ {
+		if hm.regMeta[id].length == 0 {
+			continue
+		}
+		caps.Items = append(caps.Items, meter.ItemCapability{
+			ID:       uint8(id),
+			Name:     itemTable[id].Name,
+			Unit:     itemTable[id].Unit,
+			Kind:     itemTable[id].Kind,
+			Scale:    regio.Scale(hm.regMeta[id].override),
+			Signed:   hm.regMeta[id].hasSymbol,
+			Readable: hm.regMeta[id].readable,
+			Writable: hm.regMeta[id].writable,
+			Rollover: meter.Rollover(itemTable[id].Kind, regio.Span(hm.regMeta[id].reg())),
+		})
+	}
+	caps.ActuatorAmount = len(hm.valveMeta)
+	return
+}
+
+/*
+get fingerprints of the Modbus-RTU heat meter models, used to identify meters by gateway.Scan
+
+# Returns
+
+fps []gateway.Fingerprint: fingerprints of all Modbus-RTU models
+*/
+func Fingerprints() (fps []gateway.Fingerprint) {
+	for model, modelMeta := range modelMetas {
+		if modelMeta == nil || modelMeta.protocol != meter.PROTOCOL_MODBUS_RTU {
+			continue
+		}
+		fps = append(fps, modelFingerprints(uint8(model), modelMeta)...)
+	}
+	return
+}
+
+// build fingerprints from plausible item ranges of a meter model
+func modelFingerprints(model uint8, modelMeta *ModelMeta) (fps []gateway.Fingerprint) {
+	for _, fm := range modelMeta.fingerprints {
+		if int(fm.id) >= len(modelMeta.regMeta) || modelMeta.regMeta[fm.id].length == 0 {
+			continue
+		}
+		fps = append(fps, regio.Fingerprint(meter.METER_KIND_HEAT, model, modelMeta.name, modelMeta.regMeta[fm.id].reg(), fm.min, fm.max))
+	}
+	return
+}
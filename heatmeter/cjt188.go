@@ -0,0 +1,98 @@
+package heatmeter
+
+import (
+	"fmt"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/cjt188"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+var modelMetaCJT188 = ModelMeta{
+	name:           "CJ/T 188",
+	source:         "CJ/T 188",
+	protocol:       meter.PROTOCOL_CJT188,
+	cjtValveAmount: 1,
+}
+
+// items available in CJ/T 188 metering data, with resolution of the value
+var cjtItemScale = map[uint8]float64{
+	ID_ENERGY_HEAT:     0.01,
+	ID_POWER:           0.01,
+	ID_FLOW:            0.0001,
+	ID_VOLUME:          0.01,
+	ID_TEMP_SUPPLY:     0.01,
+	ID_TEMP_RETURN:     0.01,
+	ID_TEMP_DIFF:       0.01,
+	ID_OPERATING_HOURS: 1,
+}
+
+// if meter speaks CJ/T 188 rather than Modbus-RTU
+func (hm *HeatMeter) isCJT188() bool {
+	return hm.modelMeta != nil && hm.modelMeta.protocol == meter.PROTOCOL_CJT188
+}
+
+// GetVal of CJ/T 188 meters, items are picked from metering data
+func (hm *HeatMeter) getValCJT188(id uint8) (ret float64, err error) {
+	if _, ok := cjtItemScale[id]; !ok {
+		err = fmt.Errorf("undefined data item %s of CJ/T 188 meter", ItemName(id))
+		return
+	}
+	var hd cjt188.HeatData
+	hd, err = hm.cjt.ReadHeatData()
+	if err != nil {
+		return
+	}
+	switch id {
+	case ID_ENERGY_HEAT:
+		ret = hd.Energy
+	case ID_POWER:
+		ret = hd.Power
+	case ID_FLOW:
+		ret = hd.Flow
+	case ID_VOLUME:
+		ret = hd.Volume
+	case ID_TEMP_SUPPLY:
+		ret = hd.TempSupply
+	case ID_TEMP_RETURN:
+		ret = hd.TempReturn
+	case ID_TEMP_DIFF:
+		ret = hd.TempSupply - hd.TempReturn
+	case ID_OPERATING_HOURS:
+		ret = hd.OperatingHours
+	}
+	return
+}
+
+// GetValveState of CJ/T 188 meters, by valve bits of status word
+func (hm *HeatMeter) getValveStateCJT188(turn uint8) (state uint8, err error) {
+	if int(turn) >= hm.modelMeta.cjtValveAmount {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	}
+	var hd cjt188.HeatData
+	hd, err = hm.cjt.ReadHeatData()
+	if err != nil {
+		return
+	}
+	switch uint8(hd.Status & cjt188.STATUS_VALVE_MASK) {
+	case cjt188.VALVE_STAT_OPENED:
+		state = VALVE_STAT_OPENED
+	case cjt188.VALVE_STAT_CLOSED:
+		state = VALVE_STAT_CLOSED
+	case cjt188.VALVE_STAT_ABNORMAL:
+		state = VALVE_STAT_FAULT
+	default:
+		err = fmt.Errorf("abnormal valve status 0x%04x", hd.Status)
+	}
+	return
+}
+
+// valve command of CJ/T 188 meters
+func (hm *HeatMeter) setValveCJT188(turn uint8, stat bool) (err error) {
+	if int(turn) >= hm.modelMeta.cjtValveAmount {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	}
+	err = hm.cjt.SetValve(stat)
+	return
+}
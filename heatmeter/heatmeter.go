@@ -0,0 +1,647 @@
+/*
+ * @filename	heatmeter.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Constants and methods of Modbus-RTU heat meter
+ * @comment		--
+ */
+
+package heatmeter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/cjt188"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// data item identifiers in serial form
+const (
+	// accumulated heat energy, in kWh
+	ID_ENERGY_HEAT uint8 = iota
+	// accumulated cooling energy, in kWh
+	ID_ENERGY_COLD
+	// thermal power, in kW
+	ID_POWER
+	// instantaneous flow, in m^3/h
+	ID_FLOW
+	// accumulated volume, in m^3
+	ID_VOLUME
+	// supply (inlet) water temperature, in degC
+	ID_TEMP_SUPPLY
+	// return (outlet) water temperature, in degC
+	ID_TEMP_RETURN
+	// temperature difference between supply and return, in K
+	ID_TEMP_DIFF
+	// accumulated operating time, in h
+	ID_OPERATING_HOURS
+
+	// (reserved) ID amount counter, must be at the end
+	ID_DATA_ITEM_AMOUNT__
+)
+
+// kWh in 1 GJ, for meters which keep energy in GJ or for display
+const KWH_PER_GJ = 1e6 / 3600
+
+const (
+	VALVE_TURN_1 uint8 = iota
+	VALVE_TURN_2
+)
+
+// valve states reported by GetValveState
+const (
+	VALVE_STAT_CLOSED uint8 = iota
+	VALVE_STAT_OPENED
+	// valve travelling towards opened
+	VALVE_STAT_OPENING
+	// valve travelling towards closed
+	VALVE_STAT_CLOSING
+	// valve stuck, motor fault or abnormal status
+	VALVE_STAT_FAULT
+
+	// (reserved) valve state amount counter, must be at the end
+	VALVE_STAT_AMOUNT__
+)
+
+var valveStateNames = [VALVE_STAT_AMOUNT__]string{
+	VALVE_STAT_CLOSED:  "closed",
+	VALVE_STAT_OPENED:  "opened",
+	VALVE_STAT_OPENING: "opening",
+	VALVE_STAT_CLOSING: "closing",
+	VALVE_STAT_FAULT:   "fault",
+}
+
+// meter model identifiers
+const (
+	// generic heat meter speaking CJ/T 188
+	METER_MODEL_CJT188 uint8 = iota
+)
+
+// metadata of meter models indexed by model id, Modbus-RTU models are added by RegisterModel along with the vendor register table they follow
+var modelMetas = []*ModelMeta{
+	METER_MODEL_CJT188: &modelMetaCJT188,
+}
+
+// Modbus-RTU register type identifiers
+const (
+	REGTYPE_COIL     = regio.REGTYPE_COIL
+	REGTYPE_INPUT    = regio.REGTYPE_INPUT
+	REGTYPE_HOLDING  = regio.REGTYPE_HOLDING
+	REGTYPE_DISCRETE = regio.REGTYPE_DISCRETE
+)
+
+// retry policy of heat meters, battery powered ones answer slowly
+var retryPolicy = regio.RetryPolicy{
+	Retries:     3,
+	Delay:       50 * time.Millisecond,
+	SettleDelay: 200 * time.Millisecond,
+}
+
+// number of retries of valve commands
+const valveCmdRetries = 30
+
+// default policy of waiting for valves, motorised valves take 10 - 30 s to travel
+var valvePolicy = meter.ActuationPolicy{
+	Timeout:  30 * time.Second,
+	Interval: 500 * time.Millisecond,
+}
+
+/*
+initialize heat meter instance
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, and needs to be initialized in advance
+
+meterModel uint8: meter model id, using macro METER_MODEL_* or returned by RegisterModel
+
+slaveAddr uint8: Modbus-RTU address of the meter, or CJ/T 188 address in decimal for CJ/T 188 meters
+
+# Returns
+
+err error: error
+*/
+func (hm *HeatMeter) Init(gw *gateway.MBRTGateway, meterModel uint8, slaveAddr uint8) (err error) {
+	err = hm.InitWithAddr(gw, meterModel, strconv.Itoa(int(slaveAddr)))
+	return
+}
+
+/*
+initialize heat meter instance with address in protocol format
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, and needs to be initialized in advance
+
+meterModel uint8: meter model id, using macro METER_MODEL_* or returned by RegisterModel
+
+addr string: Modbus-RTU address in decimal such as "21", or CJ/T 188 address up to 14 digits such as "00000012345678"
+
+# Returns
+
+err error: error
+*/
+func (hm *HeatMeter) InitWithAddr(gw *gateway.MBRTGateway, meterModel uint8, addr string) (err error) {
+	if int(meterModel) >= len(modelMetas) || modelMetas[meterModel] == nil {
+		// invalid meter model
+		err = errors.New("invalid meter type")
+		return
+	}
+	modelMeta := modelMetas[meterModel]
+	var slaveAddr uint8
+	if modelMeta.protocol == meter.PROTOCOL_CJT188 {
+		var cjtAddr [7]byte
+		cjtAddr, err = cjt188.ParseAddr(addr)
+		if err != nil {
+			return
+		}
+		err = hm.cjt.Init(gw, cjt188.TYPE_HEAT, cjtAddr)
+		if err != nil {
+			return
+		}
+		addr = cjt188.FormatAddr(cjtAddr)
+	} else {
+		slaveAddr, err = meter.ParseSlaveAddr(addr, modelMeta.maxSlaveAddr)
+		if err != nil {
+			return
+		}
+		err = hm.engine.Init(gw, slaveAddr, retryPolicy)
+		if err != nil {
+			return
+		}
+	}
+	hm.model = meterModel
+	hm.modelMeta = modelMeta
+	hm.regMeta = modelMeta.regMeta
+	hm.valveMeta = modelMeta.valveMeta
+	hm.gateway = gw
+	hm.slaveAddr = slaveAddr
+	hm.addr = addr
+	return
+}
+
+/*
+get values such as heat energy, power, flow and temperatures
+
+# Params
+
+id uint8: item id, specifies which value should be fetched, using macro ID_*
+
+# Returns
+
+ret float64: value in float64, the unit might be one of the following: kWh, kW, m^3/h, m^3, degC, K, h
+
+err error: error
+*/
+func (hm *HeatMeter) GetVal(id uint8) (ret float64, err error) {
+	if hm.isCJT188() {
+		ret, err = hm.getValCJT188(id)
+		return
+	}
+	if int(id) >= len(hm.regMeta) || hm.regMeta[id].length == 0 {
+		err = fmt.Errorf("undefined register metadata of item %s", ItemName(id))
+		return
+	}
+	if !hm.regMeta[id].readable {
+		err = fmt.Errorf("unreadable register of item %s", ItemName(id))
+		return
+	}
+	ret, err = hm.engine.Read(hm.regMeta[id].reg())
+	return
+}
+
+/*
+fetch valve status
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+# Returns
+
+stat bool: valve status, true is opened (turned on), false is closed (turned off)
+
+err error: error, also if the valve is travelling or in fault, see GetValveState
+*/
+func (hm *HeatMeter) GetValve(turn uint8) (stat bool, err error) {
+	var state uint8
+	state, err = hm.GetValveState(turn)
+	if err != nil {
+		return
+	}
+	switch state {
+	case VALVE_STAT_OPENED:
+		stat = true
+	case VALVE_STAT_CLOSED:
+		stat = false
+	default:
+		err = fmt.Errorf("valve is %s", ValveStateName(state))
+	}
+	return
+}
+
+/*
+fetch valve state, telling travelling and fault states apart from opened and closed
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+# Returns
+
+state uint8: valve state, using macro VALVE_STAT_*
+
+err error: error
+*/
+func (hm *HeatMeter) GetValveState(turn uint8) (state uint8, err error) {
+	if hm.isCJT188() {
+		state, err = hm.getValveStateCJT188(turn)
+		return
+	}
+	if int(turn) >= len(hm.valveMeta) {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	}
+	vm := &hm.valveMeta[turn]
+	switch vm.statusRegType {
+	case REGTYPE_COIL, REGTYPE_DISCRETE:
+		var on bool
+		on, err = hm.engine.ReadBool(vm.statusAddr, vm.statusRegType)
+		if err != nil {
+			return
+		}
+		state = VALVE_STAT_CLOSED
+		if on {
+			state = VALVE_STAT_OPENED
+		}
+	case REGTYPE_HOLDING, REGTYPE_INPUT:
+		var regval []uint16
+		regval, err = hm.engine.ReadRaw(vm.statusAddr, 1, vm.statusRegType)
+		if err != nil {
+			return
+		}
+		state, err = vm.state(regval[0])
+	default:
+		err = errors.New("invalid register type")
+	}
+	return
+}
+
+/*
+check if a valve reports its travel, so that a valve still reading its former state while actuated is seized rather than moving
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+# Returns
+
+travel bool: true if the status register tells opening or closing apart from opened and closed
+*/
+func (hm *HeatMeter) ValveReportsTravel(turn uint8) (travel bool) {
+	if hm.isCJT188() || int(turn) >= len(hm.valveMeta) {
+		return false
+	}
+	return hm.valveMeta[turn].reportsTravel()
+}
+
+/*
+valve action (open or close) command, waiting until the valve reaches the state, see ActuateValve
+
+# Params
+
+turn uint8: which valve should be operated, give operand using macro such as VALVE_TURN_1
+
+stat bool: valve status, true is opened (turned on), false is closed (turned off)
+
+# Returns
+
+err error: error
+*/
+func (hm *HeatMeter) SetValve(turn uint8, stat bool) (err error) {
+	_, err = hm.ActuateValve(turn, stat, nil)
+	return
+}
+
+/*
+valve action (open or close) command, polling valve state until it reaches the target, reports fault, or times out
+
+# Params
+
+turn uint8: which valve should be operated, give operand using macro such as VALVE_TURN_1
+
+stat bool: valve status, true is opened (turned on), false is closed (turned off)
+
+progress func(a meter.Actuation) (stop bool): called after each poll with intermediate state, return true to stop waiting, could be nil
+
+# Returns
+
+a meter.Actuation: last valve state with macro VALVE_STAT_*, phase and elapsed actuation time
+
+err error: error sending the command, or meter.ErrActuation* if the valve did not complete
+*/
+func (hm *HeatMeter) ActuateValve(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error) {
+	if hm.isCJT188() {
+		err = hm.setValveCJT188(turn, stat)
+	} else if int(turn) >= len(hm.valveMeta) {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	} else if hm.valveMeta[turn].ctlRegType == REGTYPE_COIL {
+		err = hm.engine.WriteBool(hm.valveMeta[turn].ctlAddr, stat, valveCmdRetries)
+	} else if hm.valveMeta[turn].ctlRegType == REGTYPE_HOLDING {
+		cmd := hm.valveMeta[turn].ctlCloseCmd
+		if stat {
+			cmd = hm.valveMeta[turn].ctlOpenCmd
+		}
+		err = hm.engine.WriteRaw(hm.valveMeta[turn].ctlAddr, []uint16{cmd}, false, valveCmdRetries)
+	} else {
+		err = errors.New("invalid register type")
+	}
+	if err != nil {
+		return
+	}
+	a, err = meter.WaitActuation(stat, hm.GetActuationPolicy(), func() (phase uint8, state uint8, err error) {
+		state, err = hm.GetValveState(turn)
+		phase = valvePhase(state, stat)
+		return
+	}, progress)
+	return
+}
+
+/*
+get policy of waiting for valve actions
+
+# Returns
+
+policy meter.ActuationPolicy: policy set by SetActuationPolicy, or the default of 30 s timeout polling every 500 ms
+*/
+func (hm *HeatMeter) GetActuationPolicy() (policy meter.ActuationPolicy) {
+	policy = hm.actuationPolicy
+	if policy == (meter.ActuationPolicy{}) {
+		policy = valvePolicy
+	}
+	return
+}
+
+/*
+set policy of waiting for valve actions, such as a longer timeout for slow valves
+
+# Params
+
+policy meter.ActuationPolicy: timeout and poll interval, zero value for default
+*/
+func (hm *HeatMeter) SetActuationPolicy(policy meter.ActuationPolicy) {
+	hm.actuationPolicy = policy
+}
+
+// judge valve state against target state
+func valvePhase(state uint8, target bool) (phase uint8) {
+	switch state {
+	case VALVE_STAT_OPENED, VALVE_STAT_CLOSED:
+		if (state == VALVE_STAT_OPENED) == target {
+			phase = meter.ACTUATION_DONE
+		} else {
+			phase = meter.ACTUATION_STALLED
+		}
+	case VALVE_STAT_OPENING, VALVE_STAT_CLOSING:
+		phase = meter.ACTUATION_MOVING
+	default:
+		phase = meter.ACTUATION_FAULT
+	}
+	return
+}
+
+/*
+format valve state into its name, such as "opening"
+
+# Params
+
+state uint8: valve state, using macro VALVE_STAT_*
+
+# Returns
+
+name string: state name, or "state#<state>" if state is undefined
+*/
+func ValveStateName(state uint8) (name string) {
+	if state >= VALVE_STAT_AMOUNT__ {
+		name = fmt.Sprintf("state#%d", state)
+		return
+	}
+	name = valveStateNames[state]
+	return
+}
+
+/*
+get identity of the meter instance
+
+# Returns
+
+ident meter.Identity: meter kind, model, gateway address and slave address
+*/
+func (hm *HeatMeter) GetIdentity() (ident meter.Identity) {
+	ident.Kind = meter.METER_KIND_HEAT
+	ident.Model = hm.model
+	if hm.modelMeta != nil {
+		ident.ModelName = hm.modelMeta.name
+		ident.Protocol = hm.modelMeta.protocol
+	}
+	if hm.gateway != nil {
+		ident.NetAddr = hm.gateway.GetNetAddr()
+	}
+	ident.SlaveAddr = hm.slaveAddr
+	ident.Addr = hm.addr
+	return
+}
+
+/*
+get description of data item, same as GetItemInfo of the package
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+info meter.ItemInfo: item name, unit, quantity kind and description
+
+err error: error if item id is undefined
+*/
+func (hm *HeatMeter) GetItemInfo(id uint8) (info meter.ItemInfo, err error) {
+	info, err = GetItemInfo(id)
+	return
+}
+
+/*
+fetch actuator status, implementing meter.Meter, same as GetValve
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+# Returns
+
+on bool: true is opened (turned on), false is closed (turned off)
+
+err error: error
+*/
+func (hm *HeatMeter) GetActuator(turn uint8) (on bool, err error) {
+	on, err = hm.GetValve(turn)
+	return
+}
+
+/*
+actuator command, implementing meter.Meter, same as SetValve
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+on bool: true to open (turn on), false to close (turn off)
+
+# Returns
+
+err error: error
+*/
+func (hm *HeatMeter) SetActuator(turn uint8, on bool) (err error) {
+	err = hm.SetValve(turn, on)
+	return
+}
+
+// metadata of registers in meter, including reg addr, length, read/writability and so on
+type RegMeta struct {
+	// register address
+	regAddr uint16
+	// number of successive registers used to hold one value
+	length uint16
+	// register type (holding or input)
+	regType uint8
+	// if register readable
+	readable bool
+	// if register writable
+	writable bool
+	// if the value can be no less than 0
+	hasSymbol bool
+	// a value multiplied onto the original value from the register
+	override float32
+}
+
+// register description used by the register access engine
+func (rm *RegMeta) reg() (reg regio.Reg) {
+	reg = regio.Reg{
+		Addr:   rm.regAddr,
+		Length: rm.length,
+		Type:   rm.regType,
+		Signed: rm.hasSymbol,
+		Scale:  regio.Scale(rm.override),
+	}
+	return
+}
+
+// metadata of valve
+type ValveMeta struct {
+	// valve controlling register address
+	ctlAddr uint16
+	// valve controlling register type (coil or holding), independent of status register type
+	ctlRegType uint8
+	// valve close command to write to register
+	ctlCloseCmd uint16
+	// valve open command to write to register
+	ctlOpenCmd uint16
+	// valve status register address
+	statusAddr uint16
+	// valve status register type (coil, discrete input, input or holding)
+	statusRegType uint8
+	// value indicates that valve is closed
+	statusCloseVal uint16
+	// value indicates that valve is opened
+	statusOpenVal uint16
+	// other status values and the states they indicate, such as opening, closing or fault, input or holding only
+	statusStates map[uint16]uint8
+}
+
+// valve state indicated by value of status register, input or holding only
+func (vm *ValveMeta) state(regval uint16) (state uint8, err error) {
+	if regval == vm.statusCloseVal {
+		state = VALVE_STAT_CLOSED
+	} else if regval == vm.statusOpenVal {
+		state = VALVE_STAT_OPENED
+	} else if s, ok := vm.statusStates[regval]; ok {
+		state = s
+	} else {
+		err = fmt.Errorf("bad register value 0x%04x", regval)
+	}
+	return
+}
+
+// if status register has travel states, input or holding only
+func (vm *ValveMeta) reportsTravel() (travel bool) {
+	for _, s := range vm.statusStates {
+		if s == VALVE_STAT_OPENING || s == VALVE_STAT_CLOSING {
+			return true
+		}
+	}
+	return false
+}
+
+// plausible range of an item, used as fingerprint of meter model when scanning the bus
+type fingerprintMeta struct {
+	// item id, using macro ID_*
+	id uint8
+	// lowest plausible value
+	min float64
+	// highest plausible value
+	max float64
+}
+
+// metadata of meter model, collecting register and valve descriptions
+type ModelMeta struct {
+	// human readable model name
+	name string
+	// document the register table or protocol is taken from
+	source string
+	// communication protocol, using macro meter.PROTOCOL_*
+	protocol uint8
+	// register metadata ordered by item ID, Modbus-RTU only
+	regMeta []RegMeta
+	// valve metadata ordered by turn, Modbus-RTU only
+	valveMeta []ValveMeta
+	// number of valves, CJ/T 188 only
+	cjtValveAmount int
+	// highest slave address the model accepts, 0 for protocol limit of 247, Modbus-RTU only
+	maxSlaveAddr uint8
+	// items with plausible ranges identifying the model on bus scan, Modbus-RTU only
+	fingerprints []fingerprintMeta
+}
+
+type HeatMeter struct {
+	gateway   *gateway.MBRTGateway
+	engine    regio.Engine
+	cjt       cjt188.Client
+	slaveAddr uint8
+	addr      string
+	model     uint8
+	modelMeta *ModelMeta
+	regMeta   []RegMeta
+	valveMeta []ValveMeta
+	// policy of waiting for valve actions, zero value for valvePolicy
+	actuationPolicy meter.ActuationPolicy
+}
+
+type IHeatMeter interface {
+	Init(gw *gateway.MBRTGateway, meterModel uint8, slaveAddr uint8) (err error)
+	InitWithAddr(gw *gateway.MBRTGateway, meterModel uint8, addr string) (err error)
+	GetVal(id uint8) (ret float64, err error)
+	GetValve(turn uint8) (stat bool, err error)
+	GetValveState(turn uint8) (state uint8, err error)
+	SetValve(turn uint8, stat bool) (err error)
+	ActuateValve(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error)
+	SetActuationPolicy(policy meter.ActuationPolicy)
+	GetCapabilities() (caps meter.Capabilities)
+}
+
+var _ meter.Meter = (*HeatMeter)(nil)
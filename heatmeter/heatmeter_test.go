@@ -0,0 +1,66 @@
+package heatmeter
+
+import (
+	"testing"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// motorised valve reporting its travel in a holding register
+var valveMetaMotorised = ValveMeta{
+	statusRegType:  REGTYPE_HOLDING,
+	statusCloseVal: 0x0000,
+	statusOpenVal:  0x0001,
+	statusStates: map[uint16]uint8{
+		0x0002: VALVE_STAT_OPENING,
+		0x0003: VALVE_STAT_CLOSING,
+		0x00FF: VALVE_STAT_FAULT,
+	},
+}
+
+func TestValveState(t *testing.T) {
+	tests := []struct {
+		regval uint16
+		state  uint8
+		bad    bool
+		// phases when opening and closing the valve
+		openPhase  uint8
+		closePhase uint8
+	}{
+		{regval: 0x0000, state: VALVE_STAT_CLOSED, openPhase: meter.ACTUATION_STALLED, closePhase: meter.ACTUATION_DONE},
+		{regval: 0x0001, state: VALVE_STAT_OPENED, openPhase: meter.ACTUATION_DONE, closePhase: meter.ACTUATION_STALLED},
+		{regval: 0x0002, state: VALVE_STAT_OPENING, openPhase: meter.ACTUATION_MOVING, closePhase: meter.ACTUATION_MOVING},
+		{regval: 0x0003, state: VALVE_STAT_CLOSING, openPhase: meter.ACTUATION_MOVING, closePhase: meter.ACTUATION_MOVING},
+		{regval: 0x00FF, state: VALVE_STAT_FAULT, openPhase: meter.ACTUATION_FAULT, closePhase: meter.ACTUATION_FAULT},
+		{regval: 0x0004, bad: true},
+	}
+	for _, tt := range tests {
+		state, err := valveMetaMotorised.state(tt.regval)
+		if tt.bad {
+			if err == nil {
+				t.Errorf("0x%04x: state %s, want error", tt.regval, ValveStateName(state))
+			}
+			continue
+		}
+		if err != nil || state != tt.state {
+			t.Errorf("0x%04x: state %s, err %v, want %s", tt.regval, ValveStateName(state), err, ValveStateName(tt.state))
+			continue
+		}
+		if phase := valvePhase(state, true); phase != tt.openPhase {
+			t.Errorf("0x%04x opening: phase %s, want %s", tt.regval, meter.ActuationPhaseName(phase), meter.ActuationPhaseName(tt.openPhase))
+		}
+		if phase := valvePhase(state, false); phase != tt.closePhase {
+			t.Errorf("0x%04x closing: phase %s, want %s", tt.regval, meter.ActuationPhaseName(phase), meter.ActuationPhaseName(tt.closePhase))
+		}
+	}
+}
+
+func TestValveReportsTravel(t *testing.T) {
+	if !valveMetaMotorised.reportsTravel() {
+		t.Error("motorised valve does not report travel")
+	}
+	faultOnly := ValveMeta{statusRegType: REGTYPE_INPUT, statusOpenVal: 0x0001, statusStates: map[uint16]uint8{0x00FF: VALVE_STAT_FAULT}}
+	if faultOnly.reportsTravel() {
+		t.Error("valve reporting fault only reports travel")
+	}
+}
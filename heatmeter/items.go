@@ -0,0 +1,76 @@
+/*
+ * @filename	items.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Names, units and descriptions of heat meter data items
+ * @comment		--
+ */
+
+package heatmeter
+
+import "github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+
+// description of data items ordered by item ID, such as ID_ENERGY_HEAT
+var itemTable = meter.ItemTable{
+	ID_ENERGY_HEAT:     {Name: "energy_heat", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "accumulated heat energy"},
+	ID_ENERGY_COLD:     {Name: "energy_cold", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "accumulated cooling energy"},
+	ID_POWER:           {Name: "power", Unit: "kW", Kind: meter.KIND_POWER, Desc: "thermal power"},
+	ID_FLOW:            {Name: "flow", Unit: "m^3/h", Kind: meter.KIND_FLOW, Desc: "instantaneous flow"},
+	ID_VOLUME:          {Name: "volume", Unit: "m^3", Kind: meter.KIND_VOLUME, Desc: "accumulated volume"},
+	ID_TEMP_SUPPLY:     {Name: "temp_supply", Unit: "degC", Kind: meter.KIND_TEMPERATURE, Desc: "supply water temperature"},
+	ID_TEMP_RETURN:     {Name: "temp_return", Unit: "degC", Kind: meter.KIND_TEMPERATURE, Desc: "return water temperature"},
+	ID_TEMP_DIFF:       {Name: "temp_diff", Unit: "K", Kind: meter.KIND_TEMPERATURE, Desc: "temperature difference between supply and return"},
+	ID_OPERATING_HOURS: {Name: "operating_hours", Unit: "h", Kind: meter.KIND_DURATION, Desc: "accumulated operating time"},
+}
+
+/*
+get description of data item
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+info meter.ItemInfo: item name, unit, quantity kind and description
+
+err error: error if item id is undefined
+*/
+func GetItemInfo(id uint8) (info meter.ItemInfo, err error) {
+	info, err = itemTable.Info(id)
+	return
+}
+
+/*
+format item id into its stable name, such as "energy_heat"
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+name string: item name, or "item#<id>" if item id is undefined
+*/
+func ItemName(id uint8) (name string) {
+	name = itemTable.Name(id)
+	return
+}
+
+/*
+parse item name into item id, case-insensitive
+
+# Params
+
+name string: item name, such as "energy_heat"
+
+# Returns
+
+id uint8: item id, one of macro ID_*
+
+err error: error if name matches no item
+*/
+func ParseItem(name string) (id uint8, err error) {
+	id, err = itemTable.Parse(name)
+	return
+}
@@ -0,0 +1,236 @@
+/*
+ * @filename	model.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Registration of Modbus-RTU heat meter models from vendor register tables
+ * @comment		register tables differ between vendors and firmware, so a model is registered with the document it follows
+ */
+
+package heatmeter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// register holding a data item, a row of the vendor register table
+type RegSpec struct {
+	// item id, using macro ID_*
+	ID uint8
+	// register address
+	Addr uint16
+	// number of successive registers holding the value, 1 - 4, highest register first
+	Length uint16
+	// register type, REGTYPE_HOLDING or REGTYPE_INPUT
+	Type uint8
+	// if the highest bit of the value is the sign
+	Signed bool
+	// a value multiplied onto the original value from the register, such as 0.001, 0 for 1
+	Scale float32
+	// if the register is writable
+	Writable bool
+	// plausible range of the value identifying the model on bus scan, not used as fingerprint if both are 0
+	Min float64
+	Max float64
+}
+
+// valve, a row of the vendor register table
+type ValveSpec struct {
+	// controlling register address
+	CtlAddr uint16
+	// controlling register type, REGTYPE_COIL or REGTYPE_HOLDING
+	CtlType uint8
+	// close command to write to holding register
+	CloseCmd uint16
+	// open command to write to holding register
+	OpenCmd uint16
+	// status register address
+	StatusAddr uint16
+	// status register type, REGTYPE_COIL, REGTYPE_DISCRETE, REGTYPE_INPUT or REGTYPE_HOLDING
+	StatusType uint8
+	// status value of closed valve, input or holding only
+	StatusClosed uint16
+	// status value of opened valve, input or holding only
+	StatusOpened uint16
+	// other status values and the states they indicate, using macro VALVE_STAT_OPENING, VALVE_STAT_CLOSING or
+	// VALVE_STAT_FAULT, input or holding only
+	StatusStates map[uint16]uint8
+}
+
+// meter model described by its vendor register table
+type ModelSpec struct {
+	// human readable model name, such as "DN20 ultrasonic"
+	Name string
+	// document the register table is taken from, such as vendor manual title and revision
+	Source string
+	// registers of data items
+	Regs []RegSpec
+	// valves ordered by turn
+	Valves []ValveSpec
+	// highest slave address the model accepts, 0 for protocol limit of 247
+	MaxSlaveAddr uint8
+}
+
+/*
+register a Modbus-RTU heat meter model from its vendor register table,
+models are registered before initializing any meter, not safe for concurrent use
+
+# Params
+
+spec ModelSpec: model name, source document, registers and valves
+
+# Returns
+
+model uint8: model id to initialize meters with
+
+err error: error if spec is incomplete or invalid
+*/
+func RegisterModel(spec ModelSpec) (model uint8, err error) {
+	if spec.Name == "" {
+		err = errors.New("empty model name")
+		return
+	}
+	if spec.Source == "" {
+		err = fmt.Errorf("model %s without source of register table", spec.Name)
+		return
+	}
+	if len(spec.Regs) == 0 && len(spec.Valves) == 0 {
+		err = fmt.Errorf("model %s without registers", spec.Name)
+		return
+	}
+	if len(modelMetas) > 0xFF {
+		err = errors.New("too many meter models")
+		return
+	}
+	modelMeta := &ModelMeta{
+		name:         spec.Name,
+		protocol:     meter.PROTOCOL_MODBUS_RTU,
+		source:       spec.Source,
+		regMeta:      make([]RegMeta, ID_DATA_ITEM_AMOUNT__),
+		maxSlaveAddr: spec.MaxSlaveAddr,
+	}
+	for _, rs := range spec.Regs {
+		err = checkRegSpec(rs)
+		if err == nil && modelMeta.regMeta[rs.ID].length != 0 {
+			err = errors.New("item given twice")
+		}
+		if err != nil {
+			err = fmt.Errorf("model %s item %s: %w", spec.Name, ItemName(rs.ID), err)
+			return
+		}
+		override := rs.Scale
+		if override == 0 {
+			override = 1
+		}
+		modelMeta.regMeta[rs.ID] = RegMeta{
+			regAddr:   rs.Addr,
+			length:    rs.Length,
+			regType:   rs.Type,
+			readable:  true,
+			writable:  rs.Writable,
+			hasSymbol: rs.Signed,
+			override:  override,
+		}
+		if rs.Min != 0 || rs.Max != 0 {
+			modelMeta.fingerprints = append(modelMeta.fingerprints, fingerprintMeta{id: rs.ID, min: rs.Min, max: rs.Max})
+		}
+	}
+	for turn, vs := range spec.Valves {
+		err = checkValveSpec(vs)
+		if err != nil {
+			err = fmt.Errorf("model %s valve %d: %w", spec.Name, turn, err)
+			return
+		}
+		modelMeta.valveMeta = append(modelMeta.valveMeta, ValveMeta{
+			ctlAddr:        vs.CtlAddr,
+			ctlRegType:     vs.CtlType,
+			ctlCloseCmd:    vs.CloseCmd,
+			ctlOpenCmd:     vs.OpenCmd,
+			statusAddr:     vs.StatusAddr,
+			statusRegType:  vs.StatusType,
+			statusCloseVal: vs.StatusClosed,
+			statusOpenVal:  vs.StatusOpened,
+			statusStates:   vs.StatusStates,
+		})
+	}
+	model = uint8(len(modelMetas))
+	modelMetas = append(modelMetas, modelMeta)
+	return
+}
+
+/*
+get source document of the register table or protocol a model follows
+
+# Params
+
+model uint8: model id, using macro METER_MODEL_* or returned by RegisterModel
+
+# Returns
+
+source string: source given on registration
+
+err error: error if model is not registered
+*/
+func ModelSource(model uint8) (source string, err error) {
+	if int(model) >= len(modelMetas) || modelMetas[model] == nil {
+		err = errors.New("invalid meter type")
+		return
+	}
+	source = modelMetas[model].source
+	return
+}
+
+// check register of a data item
+func checkRegSpec(rs RegSpec) (err error) {
+	switch {
+	case rs.ID >= ID_DATA_ITEM_AMOUNT__:
+		err = errors.New("undefined item")
+	case rs.Length == 0 || rs.Length > 4:
+		err = fmt.Errorf("unsupported register length %d", rs.Length)
+	case rs.Type != REGTYPE_HOLDING && rs.Type != REGTYPE_INPUT:
+		err = errors.New("invalid register type")
+	case rs.Scale < 0:
+		err = fmt.Errorf("negative scale %g", rs.Scale)
+	case rs.Writable && rs.Type != REGTYPE_HOLDING:
+		err = errors.New("writable register is not holding")
+	case rs.Min > rs.Max:
+		err = fmt.Errorf("plausible range %g - %g is empty", rs.Min, rs.Max)
+	}
+	return
+}
+
+// check valve
+func checkValveSpec(vs ValveSpec) (err error) {
+	if vs.CtlType != REGTYPE_COIL && vs.CtlType != REGTYPE_HOLDING {
+		err = errors.New("invalid controlling register type")
+		return
+	}
+	switch vs.StatusType {
+	case REGTYPE_COIL, REGTYPE_DISCRETE:
+		if len(vs.StatusStates) > 0 {
+			err = errors.New("status states of bit register")
+		}
+		return
+	case REGTYPE_INPUT, REGTYPE_HOLDING:
+	default:
+		err = errors.New("invalid status register type")
+		return
+	}
+	if vs.StatusClosed == vs.StatusOpened {
+		err = fmt.Errorf("same status value 0x%04x of closed and opened", vs.StatusClosed)
+		return
+	}
+	for regval, state := range vs.StatusStates {
+		if regval == vs.StatusClosed || regval == vs.StatusOpened {
+			err = fmt.Errorf("status value 0x%04x given twice", regval)
+			return
+		}
+		if state != VALVE_STAT_OPENING && state != VALVE_STAT_CLOSING && state != VALVE_STAT_FAULT {
+			err = fmt.Errorf("status value 0x%04x of state %s", regval, ValveStateName(state))
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,486 @@
+/*
+ * @filename	regio.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Register access engine shared by Modbus-RTU meter drivers
+ * @comment		reads and writes with retry, reconnection, decoding and verification
+ */
+
+package regio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+
+	"github.com/kontornl/modbus"
+)
+
+// Modbus-RTU register type identifiers
+const (
+	REGTYPE_COIL uint8 = iota
+	REGTYPE_INPUT
+	REGTYPE_HOLDING
+	REGTYPE_DISCRETE
+)
+
+// how an operation is retried when it fails
+type RetryPolicy struct {
+	// number of retries after the first failed attempt, the gateway is reconnected before each retry
+	Retries int
+	// delay before each operation, giving the bus a rest between requests
+	Delay time.Duration
+	// delay between a write and its verification read-back
+	SettleDelay time.Duration
+}
+
+// default retry policy of reads and writes
+var DefaultPolicy = RetryPolicy{
+	Retries:     3,
+	Delay:       5 * time.Millisecond,
+	SettleDelay: 200 * time.Millisecond,
+}
+
+// description of a value held by successive registers
+type Reg struct {
+	// register address
+	Addr uint16
+	// number of successive registers used to hold one value
+	Length uint16
+	// register type, using macro REGTYPE_*
+	Type uint8
+	// if the value can be less than 0, the highest bit of first register is the sign
+	Signed bool
+	// a value multiplied onto the original value from the register
+	Scale float64
+	// if not 0, the value is a flag which is 1 when any of these bits of the last register is set
+	BitMask uint16
+}
+
+// register access engine bound to one slave on a gateway
+type Engine struct {
+	gateway   *gateway.MBRTGateway
+	slaveAddr uint8
+	policy    RetryPolicy
+}
+
+/*
+initialize engine instance
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that meter actually connect to
+
+slaveAddr uint8: Modbus-RTU address of the meter
+
+policy RetryPolicy: retry policy of operations, could be DefaultPolicy
+
+# Returns
+
+err error: error
+*/
+func (e *Engine) Init(gw *gateway.MBRTGateway, slaveAddr uint8, policy RetryPolicy) (err error) {
+	if gw == nil {
+		err = errors.New("nil gateway")
+		return
+	}
+	e.gateway = gw
+	e.slaveAddr = slaveAddr
+	e.policy = policy
+	return
+}
+
+// get retry policy of the engine
+func (e *Engine) GetPolicy() (policy RetryPolicy) {
+	policy = e.policy
+	return
+}
+
+// set retry policy of the engine
+func (e *Engine) SetPolicy(policy RetryPolicy) {
+	e.policy = policy
+}
+
+/*
+run operation on the slave holding the gateway lock, reconnecting gateway and retrying according to the policy
+
+# Params
+
+retries int: number of retries, negative to use the policy
+
+op func(cli *modbus.ModbusClient) error: the operation
+
+# Returns
+
+err error: error of the last attempt, or error of reconnection
+*/
+func (e *Engine) Do(retries int, op func(cli *modbus.ModbusClient) error) (err error) {
+	if e.gateway == nil {
+		err = errors.New("register engine not initialized")
+		return
+	}
+	if retries < 0 {
+		retries = e.policy.Retries
+	}
+	// one transaction on the bus at a time, also against raw frames of other protocols
+	e.gateway.GetLock().Lock()
+	defer e.gateway.GetLock().Unlock()
+	time.Sleep(e.policy.Delay)
+	if e.gateway.GetClient() == nil {
+		err = e.gateway.Reinit()
+		if err != nil {
+			return
+		}
+		if e.gateway.GetClient() == nil {
+			err = errors.New("gateway has no Modbus client")
+			return
+		}
+	}
+	e.gateway.GetClient().SetUnitId(e.slaveAddr)
+	for retry := retries; ; retry-- {
+		err = op(e.gateway.GetClient())
+		if err == nil {
+			break
+		}
+		if retry > 0 {
+			err = e.gateway.Reconnect()
+		}
+		if err != nil {
+			return
+		}
+		e.gateway.GetClient().SetUnitId(e.slaveAddr)
+	}
+	return
+}
+
+/*
+read raw values of successive registers
+
+# Params
+
+addr uint16: register address
+
+length uint16: number of registers
+
+regType uint8: REGTYPE_HOLDING or REGTYPE_INPUT, coils and discrete inputs are read with ReadBool
+
+# Returns
+
+regval []uint16: register values
+
+err error: error
+*/
+func (e *Engine) ReadRaw(addr uint16, length uint16, regType uint8) (regval []uint16, err error) {
+	var mbRegType modbus.RegType
+	switch regType {
+	case REGTYPE_HOLDING:
+		mbRegType = modbus.HOLDING_REGISTER
+	case REGTYPE_INPUT:
+		mbRegType = modbus.INPUT_REGISTER
+	default:
+		err = errors.New("invalid register type")
+		return
+	}
+	err = e.Do(-1, func(cli *modbus.ModbusClient) (err error) {
+		regval, err = cli.ReadRegisters(addr, length, mbRegType)
+		return
+	})
+	if err == nil && len(regval) != int(length) {
+		err = fmt.Errorf("short register read, %d of %d registers", len(regval), length)
+	}
+	return
+}
+
+/*
+read a coil or discrete input
+
+# Params
+
+addr uint16: coil or discrete input address
+
+regType uint8: REGTYPE_COIL or REGTYPE_DISCRETE
+
+# Returns
+
+val bool: coil or discrete input value
+
+err error: error
+*/
+func (e *Engine) ReadBool(addr uint16, regType uint8) (val bool, err error) {
+	switch regType {
+	case REGTYPE_COIL:
+		err = e.Do(-1, func(cli *modbus.ModbusClient) (err error) {
+			val, err = cli.ReadCoil(addr)
+			return
+		})
+	case REGTYPE_DISCRETE:
+		err = e.Do(-1, func(cli *modbus.ModbusClient) (err error) {
+			val, err = cli.ReadDiscreteInput(addr)
+			return
+		})
+	default:
+		err = errors.New("invalid register type")
+	}
+	return
+}
+
+/*
+read and decode a value
+
+# Params
+
+reg Reg: register description
+
+# Returns
+
+ret float64: decoded value
+
+err error: error
+*/
+func (e *Engine) Read(reg Reg) (ret float64, err error) {
+	var regval []uint16
+	regval, err = e.ReadRaw(reg.Addr, reg.Length, reg.Type)
+	if err != nil {
+		return
+	}
+	ret = Decode(regval, reg)
+	return
+}
+
+/*
+write raw values into successive registers, and read them back to verify if required
+
+# Params
+
+addr uint16: register address
+
+values []uint16: values to write
+
+verify bool: read back after SettleDelay and compare
+
+retries int: number of retries, negative to use the policy
+
+# Returns
+
+err error: error
+*/
+func (e *Engine) WriteRaw(addr uint16, values []uint16, verify bool, retries int) (err error) {
+	err = e.Do(retries, func(cli *modbus.ModbusClient) (err error) {
+		err = cli.WriteRegisters(addr, values)
+		return
+	})
+	if err != nil || !verify {
+		return
+	}
+	time.Sleep(e.policy.SettleDelay)
+	var regval []uint16
+	regval, err = e.ReadRaw(addr, uint16(len(values)), REGTYPE_HOLDING)
+	if err != nil {
+		return
+	}
+	for i := range values {
+		if regval[i] != values[i] {
+			err = fmt.Errorf("register 0x%04x verification failed, wrote 0x%04x but read 0x%04x", addr+uint16(i), values[i], regval[i])
+			return
+		}
+	}
+	return
+}
+
+/*
+write a coil
+
+# Params
+
+addr uint16: coil address
+
+val bool: coil value
+
+retries int: number of retries, negative to use the policy
+
+# Returns
+
+err error: error
+*/
+func (e *Engine) WriteBool(addr uint16, val bool, retries int) (err error) {
+	err = e.Do(retries, func(cli *modbus.ModbusClient) (err error) {
+		err = cli.WriteCoil(addr, val)
+		return
+	})
+	return
+}
+
+/*
+encode and write a value, holding registers only
+
+# Params
+
+reg Reg: register description
+
+val float64: value to write
+
+verify bool: read back after SettleDelay and compare
+
+# Returns
+
+err error: error
+*/
+func (e *Engine) Write(reg Reg, val float64, verify bool) (err error) {
+	if reg.Type != REGTYPE_HOLDING {
+		err = errors.New("invalid register type")
+		return
+	}
+	var values []uint16
+	values, err = Encode(val, reg)
+	if err != nil {
+		return
+	}
+	err = e.WriteRaw(reg.Addr, values, verify, -1)
+	return
+}
+
+// decode register values, highest register first, into value
+func Decode(regval []uint16, reg Reg) (ret float64) {
+	if len(regval) == 0 {
+		return
+	}
+	if reg.BitMask != 0 {
+		if regval[len(regval)-1]&reg.BitMask != 0 {
+			ret = 1
+		}
+		return
+	}
+	negative := false
+	for i := 0; i < len(regval); i++ {
+		v := regval[i]
+		if i == 0 && reg.Signed && v&0x8000 != 0 {
+			negative = true
+			v &= 0x7FFF
+		}
+		ret *= 65536
+		ret += float64(v)
+	}
+	ret *= reg.Scale
+	if negative {
+		ret *= -1
+	}
+	return
+}
+
+// encode value into register values, highest register first, the reverse of Decode
+func Encode(val float64, reg Reg) (regval []uint16, err error) {
+	if reg.Length == 0 || reg.Length > 4 {
+		err = fmt.Errorf("unsupported register length %d", reg.Length)
+		return
+	}
+	if reg.BitMask != 0 {
+		err = errors.New("flag register is not writable")
+		return
+	}
+	if reg.Scale == 0 {
+		err = errors.New("zero scale")
+		return
+	}
+	negative := val < 0
+	if negative && !reg.Signed {
+		err = fmt.Errorf("negative value %g for unsigned register", val)
+		return
+	}
+	raw := math.Round(math.Abs(val) / reg.Scale)
+	limit := math.Pow(65536, float64(reg.Length))
+	if reg.Signed {
+		limit /= 2
+	}
+	if raw >= limit {
+		err = fmt.Errorf("value %g out of register range", val)
+		return
+	}
+	regval = make([]uint16, reg.Length)
+	n := uint64(raw)
+	for i := int(reg.Length) - 1; i >= 0; i-- {
+		regval[i] = uint16(n & 0xFFFF)
+		n >>= 16
+	}
+	if negative {
+		regval[0] |= 0x8000
+	}
+	return
+}
+
+// convert override in float32 into float64 without binary noise, e.g. 0.1 rather than 0.10000000149
+func Scale(override float32) (scale float64) {
+	scale, _ = strconv.ParseFloat(strconv.FormatFloat(float64(override), 'g', -1, 32), 64)
+	return
+}
+
+/*
+get span of a register value, the value at which a cumulative counter wraps back to 0
+
+# Params
+
+reg Reg: register description
+
+# Returns
+
+span float64: 2^(16 * Length) times Scale, or 2^(16 * Length - 1) times Scale for sign-magnitude values
+whose highest bit is the sign, 0 for bit flags which do not wrap as counters
+*/
+func Span(reg Reg) (span float64) {
+	if reg.BitMask != 0 || reg.Length == 0 {
+		return
+	}
+	bits := 16 * int(reg.Length)
+	if reg.Signed {
+		bits--
+	}
+	span = math.Ldexp(reg.Scale, bits)
+	return
+}
+
+/*
+build scan fingerprint of a register whose decoded value should fall in a plausible range
+
+# Params
+
+kind uint8: meter kind, using macro meter.METER_KIND_*
+
+model uint8: meter model id
+
+modelName string: human readable model name
+
+reg Reg: register description
+
+min float64: lowest plausible value
+
+max float64: highest plausible value
+
+# Returns
+
+fp gateway.Fingerprint: fingerprint for gateway.Scan
+*/
+func Fingerprint(kind uint8, model uint8, modelName string, reg Reg, min float64, max float64) (fp gateway.Fingerprint) {
+	fp = gateway.Fingerprint{
+		Kind:      kind,
+		Model:     model,
+		ModelName: modelName,
+		RegAddr:   reg.Addr,
+		Length:    reg.Length,
+		Coil:      reg.Type == REGTYPE_COIL,
+		RegType:   modbus.HOLDING_REGISTER,
+		Match: func(regval []uint16) bool {
+			if len(regval) != int(reg.Length) {
+				return false
+			}
+			val := Decode(regval, reg)
+			return val >= min && val <= max
+		},
+	}
+	if reg.Type == REGTYPE_INPUT {
+		fp.RegType = modbus.INPUT_REGISTER
+	}
+	return
+}
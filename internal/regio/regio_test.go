@@ -0,0 +1,93 @@
+package regio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpan(t *testing.T) {
+	cases := []struct {
+		name string
+		reg  Reg
+		want float64
+	}{
+		{"unsigned 1 register", Reg{Length: 1, Scale: 1}, 65536},
+		{"unsigned 2 registers", Reg{Length: 2, Scale: 0.01}, math.Ldexp(0.01, 32)},
+		{"sign-magnitude 2 registers", Reg{Length: 2, Scale: 0.01, Signed: true}, math.Ldexp(0.01, 31)},
+		{"bit flag", Reg{Length: 1, Scale: 1, BitMask: 0x0001}, 0},
+		{"no register", Reg{Scale: 1}, 0},
+	}
+	for _, c := range cases {
+		if got := Span(c.reg); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: span %g, want %g", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		name   string
+		regval []uint16
+		reg    Reg
+		want   float64
+	}{
+		{"unsigned 1 register", []uint16{0x1234}, Reg{Length: 1, Scale: 1}, 0x1234},
+		{"unsigned 2 registers high first", []uint16{0x0001, 0x0002}, Reg{Length: 2, Scale: 0.01}, 655.38},
+		{"unsigned highest bit", []uint16{0x8000}, Reg{Length: 1, Scale: 1}, 32768},
+		{"sign-magnitude positive", []uint16{0x0000, 0x3039}, Reg{Length: 2, Scale: 0.1, Signed: true}, 1234.5},
+		{"sign-magnitude negative", []uint16{0x8000, 0x3039}, Reg{Length: 2, Scale: 0.1, Signed: true}, -1234.5},
+		{"flag set", []uint16{0x0000, 0x0006}, Reg{Length: 2, Scale: 1, BitMask: 0x0004}, 1},
+		{"flag clear", []uint16{0x0004, 0x0002}, Reg{Length: 2, Scale: 1, BitMask: 0x0004}, 0},
+		{"no register", nil, Reg{Length: 1, Scale: 1}, 0},
+	}
+	for _, c := range cases {
+		if got := Decode(c.regval, c.reg); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: decoded %g, want %g", c.name, got, c.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		name string
+		val  float64
+		reg  Reg
+		want []uint16
+		bad  bool
+	}{
+		{"unsigned 1 register", 0x1234, Reg{Length: 1, Scale: 1}, []uint16{0x1234}, false},
+		{"unsigned 2 registers high first", 655.38, Reg{Length: 2, Scale: 0.01}, []uint16{0x0001, 0x0002}, false},
+		{"rounded to scale", 12.346, Reg{Length: 1, Scale: 0.01}, []uint16{1235}, false},
+		{"sign-magnitude negative", -1234.5, Reg{Length: 2, Scale: 0.1, Signed: true}, []uint16{0x8000, 0x3039}, false},
+		{"largest unsigned", 65535, Reg{Length: 1, Scale: 1}, []uint16{0xFFFF}, false},
+		{"beyond unsigned", 65536, Reg{Length: 1, Scale: 1}, nil, true},
+		{"beyond sign-magnitude", 32768, Reg{Length: 1, Scale: 1, Signed: true}, nil, true},
+		{"negative unsigned", -1, Reg{Length: 1, Scale: 1}, nil, true},
+		{"flag", 1, Reg{Length: 1, Scale: 1, BitMask: 0x0001}, nil, true},
+		{"zero scale", 1, Reg{Length: 1}, nil, true},
+		{"no register", 1, Reg{Scale: 1}, nil, true},
+		{"too many registers", 1, Reg{Length: 5, Scale: 1}, nil, true},
+	}
+	for _, c := range cases {
+		got, err := Encode(c.val, c.reg)
+		if c.bad {
+			if err == nil {
+				t.Errorf("%s: encoded %04x, want error", c.name, got)
+			}
+			continue
+		}
+		if err != nil || len(got) != len(c.want) {
+			t.Errorf("%s: encoded %04x, err %v, want %04x", c.name, got, err, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: encoded %04x, want %04x", c.name, got, c.want)
+				break
+			}
+		}
+		if back := Decode(got, c.reg); math.Abs(back-c.val) > c.reg.Scale/2 {
+			t.Errorf("%s: decoded back %g, want %g", c.name, back, c.val)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+/*
+ * @filename	leak.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Water leak detection from volume readings, with automatic valve shut-off
+ * @comment		flow is derived from volume deltas, so its resolution is the poll interval and the volume scale
+ */
+
+package leak
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/consumption"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/watermeter"
+)
+
+// water meter watched by detector, such as *watermeter.WaterMeter
+type Meter interface {
+	GetCapabilities() (caps meter.Capabilities)
+	// read data item, watermeter.ID_VOLUME only
+	GetVal(id uint8) (ret float64, err error)
+	// close valve, waiting until it is confirmed
+	SetValve(turn uint8, stat bool) (err error)
+}
+
+// alert kinds
+const (
+	// flow never stopped during the night window, such as a running toilet or dripping pipe
+	ALERT_NIGHT_FLOW uint8 = iota
+	// flow above SustainedFlow for SustainedDuration, such as a burst pipe
+	ALERT_SUSTAINED_FLOW
+	// water used while the unit is vacant
+	ALERT_VACANT_FLOW
+
+	// (reserved) alert kind amount counter, must be at the end
+	ALERT_KIND_AMOUNT__
+)
+
+// configuration of leak detector
+type Config struct {
+	// start of night window as offset from midnight, may be later than NightEnd for windows over midnight, 02:00 if both 0
+	NightStart time.Duration
+	// end of night window as offset from midnight, 05:00 if both 0
+	NightEnd time.Duration
+	// lowest flow in m^3/h taken as flowing during the night window, 0 to disable night flow detection
+	MinNightFlow float64
+	// flow in m^3/h regarded as sustained flow, 0 to disable sustained flow detection
+	SustainedFlow float64
+	// time flow must stay above SustainedFlow, 1 h if 0
+	SustainedDuration time.Duration
+	// volume in m^3 tolerated while vacant, such as meter creep
+	VacantTolerance float64
+	// if valve is closed on alert
+	ShutOff bool
+	// valve closed on alert, using macro watermeter.VALVE_TURN_*
+	Turn uint8
+	// time zone of night window, time.Local if nil
+	Location *time.Location
+	// clock of readings, time.Now if nil
+	Now func() time.Time
+	// called on each alert after the detector is unlocked, so it may call methods of the detector, could be nil
+	OnAlert func(a Alert)
+}
+
+// leak alert with evidence
+type Alert struct {
+	// time of alert
+	Time time.Time
+	// alert kind, using macro ALERT_*
+	Kind uint8
+	// description of what was seen
+	Message string
+	// volume readings leading to the alert, ordered by time
+	Evidence []consumption.Reading
+	// if the valve is closed by the detector, an alert whose shut-off failed is retried on later polls and reported again once it is closed
+	ShutOff bool
+	// error of valve shut-off
+	Err error
+}
+
+// leak detector of one water meter
+type Detector struct {
+	wm       Meter
+	cfg      Config
+	mtx      sync.Mutex
+	counter  consumption.Counter
+	readings []consumption.Reading
+	// start of flow above SustainedFlow, zero if not flowing
+	flowSince time.Time
+	vacant    bool
+	// time marked vacant
+	vacantSince time.Time
+	// volume used since marked vacant
+	vacantUsage float64
+	// end of the last night window checked
+	nightChecked time.Time
+	active       [ALERT_KIND_AMOUNT__]bool
+	alerts       []Alert
+	// alert whose shut-off is not confirmed yet, nil if none
+	pending *Alert
+}
+
+/*
+initialize leak detector of a water meter
+
+# Params
+
+wm Meter: initialized water meter, such as *watermeter.WaterMeter
+
+cfg Config: configuration
+
+# Returns
+
+err error: error
+*/
+func (d *Detector) Init(wm Meter, cfg Config) (err error) {
+	if wm == nil {
+		err = errors.New("nil water meter")
+		return
+	}
+	if cfg.NightStart == 0 && cfg.NightEnd == 0 {
+		cfg.NightStart, cfg.NightEnd = 2*time.Hour, 5*time.Hour
+	}
+	if cfg.NightStart < 0 || cfg.NightStart >= 24*time.Hour || cfg.NightEnd < 0 || cfg.NightEnd >= 24*time.Hour || cfg.NightStart == cfg.NightEnd {
+		err = fmt.Errorf("invalid night window %s - %s", cfg.NightStart, cfg.NightEnd)
+		return
+	}
+	if cfg.MinNightFlow < 0 || cfg.SustainedFlow < 0 || cfg.SustainedDuration < 0 || cfg.VacantTolerance < 0 {
+		err = errors.New("negative leak detector configuration")
+		return
+	}
+	if cfg.SustainedDuration == 0 {
+		cfg.SustainedDuration = time.Hour
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	var ccfg consumption.Config
+	ccfg, err = consumption.ConfigOf(wm.GetCapabilities(), watermeter.ID_VOLUME)
+	if err != nil {
+		return
+	}
+	var counter consumption.Counter
+	err = counter.Init(ccfg)
+	if err != nil {
+		return
+	}
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	// fields are reset one by one, assigning the whole detector would overwrite the held mutex
+	d.wm, d.cfg, d.counter = wm, cfg, counter
+	d.readings, d.flowSince = nil, time.Time{}
+	d.vacant, d.vacantSince, d.vacantUsage = false, time.Time{}, 0
+	d.nightChecked = time.Time{}
+	d.active = [ALERT_KIND_AMOUNT__]bool{}
+	d.alerts, d.pending = nil, nil
+	return
+}
+
+/*
+mark the unit vacant or occupied, any use beyond VacantTolerance while vacant raises an alert
+
+# Params
+
+vacant bool: true if nobody should be using water
+*/
+func (d *Detector) SetVacant(vacant bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if vacant && !d.vacant {
+		d.vacantSince, d.vacantUsage = d.now(), 0
+	}
+	d.vacant = vacant
+	if !vacant {
+		d.active[ALERT_VACANT_FLOW] = false
+	}
+}
+
+/*
+read volume and check for leaks
+
+# Returns
+
+alerts []Alert: alerts raised by this poll, each kind is raised once until the condition clears or Reset, and an alert whose shut-off failed is reported again once a later poll closes the valve
+
+err error: error reading the meter
+*/
+func (d *Detector) Poll() (alerts []Alert, err error) {
+	var onAlert func(a Alert)
+	alerts, onAlert, err = d.poll()
+	if onAlert != nil {
+		for _, a := range alerts {
+			onAlert(a)
+		}
+	}
+	return
+}
+
+// Poll under lock, alerts are reported by the caller
+func (d *Detector) poll() (alerts []Alert, onAlert func(a Alert), err error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	onAlert = d.cfg.OnAlert
+	if d.wm == nil {
+		err = errors.New("leak detector not initialized")
+		return
+	}
+	r := consumption.Reading{Time: d.cfg.Now()}
+	if d.pending != nil && d.wm.SetValve(d.cfg.Turn, false) == nil {
+		a := *d.pending
+		a.Time, a.ShutOff, a.Err = r.Time, true, nil
+		d.pending = nil
+		d.alerts = append(d.alerts, a)
+		alerts = append(alerts, a)
+	}
+	confirmed := len(alerts)
+	r.Value, err = d.wm.GetVal(watermeter.ID_VOLUME)
+	if err != nil {
+		return
+	}
+	var u consumption.Usage
+	var ok bool
+	u, ok, err = d.counter.Add(r)
+	if err != nil {
+		return
+	}
+	d.keep(r)
+	if !ok || u.Implausible {
+		return
+	}
+	if a, raised := d.checkSustained(u); raised {
+		alerts = append(alerts, a)
+	}
+	if a, raised := d.checkVacant(u); raised {
+		alerts = append(alerts, a)
+	}
+	if a, raised := d.checkNight(r.Time); raised {
+		alerts = append(alerts, a)
+	}
+	for i := confirmed; i < len(alerts); i++ {
+		d.raise(&alerts[i])
+	}
+	return
+}
+
+/*
+clear active alerts after repair, so that they can be raised again, a pending shut-off is given up
+
+valve is not reopened, use SetValve of the water meter
+*/
+func (d *Detector) Reset() {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.active = [ALERT_KIND_AMOUNT__]bool{}
+	d.pending = nil
+	d.flowSince = time.Time{}
+	d.vacantSince, d.vacantUsage = d.now(), 0
+}
+
+/*
+get alerts raised, ordered by time
+
+# Returns
+
+alerts []Alert: alerts
+*/
+func (d *Detector) Alerts() (alerts []Alert) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	alerts = append(alerts, d.alerts...)
+	return
+}
+
+// current time of detector clock, also before Init
+func (d *Detector) now() time.Time {
+	if d.cfg.Now == nil {
+		return time.Now()
+	}
+	return d.cfg.Now()
+}
+
+// keep readings long enough to give evidence of any alert
+func (d *Detector) keep(r consumption.Reading) {
+	d.readings = append(d.readings, r)
+	keep := 24 * time.Hour
+	if d.cfg.SustainedDuration > keep {
+		keep = d.cfg.SustainedDuration
+	}
+	// a little more than needed, so that the reading before a window is kept
+	oldest := r.Time.Add(-keep - time.Hour)
+	for len(d.readings) > 1 && d.readings[0].Time.Before(oldest) {
+		d.readings = d.readings[1:]
+	}
+}
+
+// flow above SustainedFlow for SustainedDuration
+func (d *Detector) checkSustained(u consumption.Usage) (a Alert, raised bool) {
+	if d.cfg.SustainedFlow == 0 {
+		return
+	}
+	if flow(u) < d.cfg.SustainedFlow {
+		d.flowSince = time.Time{}
+		d.active[ALERT_SUSTAINED_FLOW] = false
+		return
+	}
+	if d.flowSince.IsZero() {
+		d.flowSince = u.Start
+	}
+	if d.active[ALERT_SUSTAINED_FLOW] || u.End.Sub(d.flowSince) < d.cfg.SustainedDuration {
+		return
+	}
+	a = Alert{
+		Time:     u.End,
+		Kind:     ALERT_SUSTAINED_FLOW,
+		Message:  fmt.Sprintf("flow above %g m^3/h since %s, %g m^3 used", d.cfg.SustainedFlow, d.flowSince.Format(time.DateTime), d.used(d.flowSince, u.End)),
+		Evidence: d.evidence(d.flowSince, u.End),
+	}
+	raised = true
+	return
+}
+
+// use while vacant
+func (d *Detector) checkVacant(u consumption.Usage) (a Alert, raised bool) {
+	if !d.vacant {
+		return
+	}
+	d.vacantUsage += u.Value
+	if d.active[ALERT_VACANT_FLOW] || d.vacantUsage <= d.cfg.VacantTolerance {
+		return
+	}
+	a = Alert{
+		Time:     u.End,
+		Kind:     ALERT_VACANT_FLOW,
+		Message:  fmt.Sprintf("%g m^3 used while vacant", d.vacantUsage),
+		Evidence: d.evidence(d.vacantSince, u.End),
+	}
+	raised = true
+	return
+}
+
+// flow through the whole night window, checked once after the window ends
+func (d *Detector) checkNight(now time.Time) (a Alert, raised bool) {
+	if d.cfg.MinNightFlow == 0 {
+		return
+	}
+	start, end := d.lastNight(now)
+	if !end.After(d.nightChecked) {
+		return
+	}
+	d.nightChecked = end
+	covered := time.Duration(0)
+	lowest := -1.0
+	for _, u := range d.counter.Usages() {
+		if u.Start.Before(start) || u.End.After(end) || u.Implausible {
+			continue
+		}
+		covered += u.End.Sub(u.Start)
+		if f := flow(u); lowest < 0 || f < lowest {
+			lowest = f
+		}
+	}
+	// not judged without readings over most of the window
+	if covered < end.Sub(start)/2 || lowest < d.cfg.MinNightFlow {
+		d.active[ALERT_NIGHT_FLOW] = false
+		return
+	}
+	if d.active[ALERT_NIGHT_FLOW] {
+		return
+	}
+	a = Alert{
+		Time:     now,
+		Kind:     ALERT_NIGHT_FLOW,
+		Message:  fmt.Sprintf("flow never below %g m^3/h from %s to %s, lowest %g m^3/h", d.cfg.MinNightFlow, start.Format(time.DateTime), end.Format(time.DateTime), lowest),
+		Evidence: d.evidence(start, end),
+	}
+	raised = true
+	return
+}
+
+// the latest night window ending by now
+func (d *Detector) lastNight(now time.Time) (start time.Time, end time.Time) {
+	now = now.In(d.cfg.Location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, d.cfg.Location)
+	end = midnight.Add(d.cfg.NightEnd)
+	if end.After(now) {
+		end = end.AddDate(0, 0, -1)
+	}
+	start = end.Add(d.cfg.NightStart - d.cfg.NightEnd)
+	if d.cfg.NightStart > d.cfg.NightEnd {
+		// window over midnight
+		start = start.AddDate(0, 0, -1)
+	}
+	return
+}
+
+// readings from the last one before from up to to
+func (d *Detector) evidence(from time.Time, to time.Time) (readings []consumption.Reading) {
+	for i, r := range d.readings {
+		if r.Time.After(to) {
+			break
+		}
+		if r.Time.Before(from) && i+1 < len(d.readings) && !d.readings[i+1].Time.After(from) {
+			continue
+		}
+		readings = append(readings, r)
+	}
+	return
+}
+
+// volume used from from to to
+func (d *Detector) used(from time.Time, to time.Time) (volume float64) {
+	volume = d.counter.Sum(from, to).Value
+	return
+}
+
+// record alert and close valve if configured, a failed shut-off is kept pending to be retried
+func (d *Detector) raise(a *Alert) {
+	d.active[a.Kind] = true
+	if d.cfg.ShutOff {
+		a.Err = d.wm.SetValve(d.cfg.Turn, false)
+		a.ShutOff = a.Err == nil
+		if a.ShutOff {
+			d.pending = nil
+		} else if d.pending == nil {
+			pending := *a
+			d.pending = &pending
+		}
+	}
+	d.alerts = append(d.alerts, *a)
+}
+
+// average flow of usage, in m^3/h
+func flow(u consumption.Usage) float64 {
+	hours := u.End.Sub(u.Start).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return u.Value / hours
+}
@@ -0,0 +1,224 @@
+package leak
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/watermeter"
+)
+
+// water meter with one valve, counting volume set by the test
+type fakeMeter struct {
+	volume   float64
+	open     bool
+	valveErr error
+}
+
+func (m *fakeMeter) GetCapabilities() (caps meter.Capabilities) {
+	caps = meter.Capabilities{
+		ModelName:      "fake",
+		Items:          []meter.ItemCapability{{ID: watermeter.ID_VOLUME, Name: "volume", Kind: meter.KIND_VOLUME}},
+		ActuatorAmount: 1,
+	}
+	return
+}
+
+func (m *fakeMeter) GetVal(id uint8) (ret float64, err error) {
+	if id != watermeter.ID_VOLUME {
+		err = errors.New("unsupported item")
+		return
+	}
+	ret = m.volume
+	return
+}
+
+func (m *fakeMeter) SetValve(turn uint8, stat bool) (err error) {
+	if m.valveErr != nil {
+		return m.valveErr
+	}
+	m.open = stat
+	return
+}
+
+// night window defaults to 02:00 - 05:00, judged in cst
+var cst = time.FixedZone("CST", 8*3600)
+
+var midnight = time.Date(2026, 1, 5, 0, 0, 0, 0, cst)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		vacant bool
+		start  time.Time
+		step   time.Duration
+		// volume used before each poll, the first poll only reads the baseline
+		usage []float64
+		// poll raising the alert, -1 for none
+		poll int
+		kind uint8
+	}{
+		{
+			name:  "sustained flow",
+			cfg:   Config{SustainedFlow: 1, SustainedDuration: time.Hour},
+			start: midnight.Add(12 * time.Hour), step: 15 * time.Minute,
+			usage: []float64{0, 0.5, 0.5, 0.5, 0.5, 0.5},
+			poll:  4, kind: ALERT_SUSTAINED_FLOW,
+		},
+		{
+			name:  "sustained flow interrupted",
+			cfg:   Config{SustainedFlow: 1, SustainedDuration: time.Hour},
+			start: midnight.Add(12 * time.Hour), step: 15 * time.Minute,
+			usage: []float64{0, 0.5, 0.5, 0, 0.5, 0.5, 0.5},
+			poll:  -1,
+		},
+		{
+			name:   "use while vacant",
+			cfg:    Config{VacantTolerance: 0.1},
+			vacant: true,
+			start:  midnight.Add(12 * time.Hour), step: time.Hour,
+			usage: []float64{0, 0.05, 0.05, 0.05, 0.05},
+			poll:  3, kind: ALERT_VACANT_FLOW,
+		},
+		{
+			name:   "creep while vacant",
+			cfg:    Config{VacantTolerance: 0.1},
+			vacant: true,
+			start:  midnight.Add(12 * time.Hour), step: time.Hour,
+			usage: []float64{0, 0.01, 0.01, 0.01},
+			poll:  -1,
+		},
+		{
+			name:  "night flow",
+			cfg:   Config{MinNightFlow: 0.01, Location: cst},
+			start: midnight.Add(time.Hour), step: 30 * time.Minute,
+			usage: []float64{0, 0.05, 0.05, 0.05, 0.05, 0.05, 0.05, 0.05, 0.05, 0.05},
+			poll:  8, kind: ALERT_NIGHT_FLOW,
+		},
+		{
+			name:  "night flow stopping once",
+			cfg:   Config{MinNightFlow: 0.01, Location: cst},
+			start: midnight.Add(time.Hour), step: 30 * time.Minute,
+			usage: []float64{0, 0.05, 0.05, 0.05, 0.05, 0, 0.05, 0.05, 0.05, 0.05},
+			poll:  -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm := &fakeMeter{open: true}
+			now := tt.start
+			cfg := tt.cfg
+			cfg.ShutOff = true
+			cfg.Now = func() time.Time { return now }
+			var reported []Alert
+			var d Detector
+			cfg.OnAlert = func(a Alert) {
+				// called without the lock held
+				d.Alerts()
+				reported = append(reported, a)
+			}
+			if err := d.Init(wm, cfg); err != nil {
+				t.Fatal(err)
+			}
+			d.SetVacant(tt.vacant)
+			for i, v := range tt.usage {
+				now = tt.start.Add(time.Duration(i) * tt.step)
+				wm.volume += v
+				alerts, err := d.Poll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if i != tt.poll {
+					if len(alerts) != 0 {
+						t.Fatalf("poll %d: alerts %+v, want none", i, alerts)
+					}
+					continue
+				}
+				if len(alerts) != 1 || alerts[0].Kind != tt.kind || !alerts[0].Time.Equal(now) {
+					t.Fatalf("poll %d: alerts %+v, want kind %d", i, alerts, tt.kind)
+				}
+				if !alerts[0].ShutOff || alerts[0].Err != nil || wm.open {
+					t.Fatalf("poll %d: alert %+v, want valve shut off", i, alerts[0])
+				}
+				if len(alerts[0].Evidence) < 2 {
+					t.Fatalf("poll %d: evidence %+v, want readings", i, alerts[0].Evidence)
+				}
+			}
+			if want := min(tt.poll+1, 1); len(reported) != want || len(d.Alerts()) != want {
+				t.Fatalf("reported %+v, recorded %+v, want %d", reported, d.Alerts(), want)
+			}
+			if tt.poll < 0 && !wm.open {
+				t.Fatal("valve shut off without alert")
+			}
+		})
+	}
+}
+
+func TestShutOffRetry(t *testing.T) {
+	errValve := errors.New("no response")
+	wm := &fakeMeter{open: true, valveErr: errValve}
+	now := midnight
+	var d Detector
+	if err := d.Init(wm, Config{VacantTolerance: 0.1, ShutOff: true, Now: func() time.Time { return now }}); err != nil {
+		t.Fatal(err)
+	}
+	d.SetVacant(true)
+	poll := func(v float64) (alerts []Alert) {
+		now = now.Add(time.Hour)
+		wm.volume += v
+		alerts, err := d.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	poll(0)
+	alerts := poll(1)
+	if len(alerts) != 1 || alerts[0].ShutOff || !errors.Is(alerts[0].Err, errValve) {
+		t.Fatalf("alerts %+v, want failed shut-off", alerts)
+	}
+	if alerts = poll(1); len(alerts) != 0 {
+		t.Fatalf("alerts %+v while valve still fails, want none", alerts)
+	}
+	wm.valveErr = nil
+	alerts = poll(0)
+	if len(alerts) != 1 || alerts[0].Kind != ALERT_VACANT_FLOW || !alerts[0].ShutOff || alerts[0].Err != nil || wm.open {
+		t.Fatalf("alerts %+v, want shut-off confirmed", alerts)
+	}
+	if !alerts[0].Time.Equal(now) {
+		t.Fatalf("confirmed at %s, want %s", alerts[0].Time, now)
+	}
+	wm.open = true
+	if alerts = poll(0); len(alerts) != 0 || !wm.open {
+		t.Fatalf("alerts %+v after confirmation, want none and valve left alone", alerts)
+	}
+}
+
+func TestInit(t *testing.T) {
+	var d Detector
+	if _, err := d.Poll(); err == nil {
+		t.Fatal("no error polling before Init")
+	}
+	if err := d.Init(nil, Config{}); err == nil {
+		t.Fatal("no error with nil meter")
+	}
+	if err := d.Init(&fakeMeter{}, Config{NightStart: 25 * time.Hour}); err == nil {
+		t.Fatal("no error with night window beyond a day")
+	}
+	// a detector in use is initialized again
+	wm := &fakeMeter{}
+	if err := d.Init(wm, Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Init(wm, Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Poll(); err != nil {
+		t.Fatal(err)
+	}
+}
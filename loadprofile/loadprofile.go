@@ -0,0 +1,388 @@
+/*
+ * @filename	loadprofile.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Software-computed demand and load profile from polled power meter readings
+ * @comment		for meters without demand registers, such as DDS4921
+ */
+
+package loadprofile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/powermeter"
+)
+
+// one polled reading of a power meter
+type Sample struct {
+	// time of reading
+	Time time.Time
+	// active power, in W
+	Power float64
+	// if Power is read
+	PowerValid bool
+	// indicating value of active energy of all rates, in kWh
+	Energy float64
+	// if Energy is read
+	EnergyValid bool
+}
+
+// energy of one slide interval of the load curve
+type Interval struct {
+	// start of interval, aligned to slide
+	Start time.Time
+	// end of interval
+	End time.Time
+	// energy consumed in interval, in kWh
+	Energy float64
+	// average active power of interval, in W
+	Power float64
+	// part of interval covered by readings, 0 - 1, less than 1 after a gap longer than MaxGap
+	Coverage float64
+	// if some energy is interpolated over missed polls, integrated from power, or lost to a counter reset
+	Estimated bool
+}
+
+// demand over one demand period
+type Demand struct {
+	// end of demand period
+	End time.Time
+	// average active power over demand period, in W
+	Power float64
+	// if any interval of the period is estimated or not fully covered
+	Estimated bool
+}
+
+// highest demand of a day
+type DailyPeak struct {
+	// start of day in Location of config
+	Day time.Time
+	// highest demand of the day
+	Demand Demand
+}
+
+// configuration of load profile
+type Config struct {
+	// demand period, 15 min if 0
+	Period time.Duration
+	// sliding window step, dividing Period, equal to Period for block demand, Period if 0
+	Slide time.Duration
+	// longest gap between readings to interpolate over, longer gaps are left uncovered, 1 h if 0
+	MaxGap time.Duration
+	// number of intervals kept in load curve, 7 days if 0
+	Retention int
+	// time zone of interval alignment and days, time.Local if nil
+	Location *time.Location
+}
+
+// demand and load curve computed from polled readings of one meter
+type Profile struct {
+	cfg       Config
+	last      Sample
+	started   bool
+	resets    int
+	open      []Interval
+	intervals []Interval
+	demand    Demand
+	hasDemand bool
+	peaks     []DailyPeak
+}
+
+/*
+initialize load profile with configuration
+
+# Params
+
+cfg Config: configuration, zero fields take defaults
+
+# Returns
+
+err error: error if slide does not divide period
+*/
+func (p *Profile) Init(cfg Config) (err error) {
+	if cfg.Period == 0 {
+		cfg.Period = 15 * time.Minute
+	}
+	if cfg.Slide == 0 {
+		cfg.Slide = cfg.Period
+	}
+	if cfg.MaxGap == 0 {
+		cfg.MaxGap = time.Hour
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	if cfg.Period < 0 || cfg.Slide < 0 || cfg.MaxGap < 0 || cfg.Retention < 0 {
+		err = errors.New("negative load profile configuration")
+		return
+	}
+	if cfg.Period%cfg.Slide != 0 {
+		err = fmt.Errorf("slide %s does not divide demand period %s", cfg.Slide, cfg.Period)
+		return
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = int(7 * 24 * time.Hour / cfg.Slide)
+	}
+	*p = Profile{cfg: cfg}
+	return
+}
+
+/*
+poll active power and energy of a power meter and add them as one sample
+
+# Params
+
+pm *powermeter.PowerMeter: initialized power meter
+
+# Returns
+
+err error: error if neither value could be read, or the sample is rejected
+*/
+func (p *Profile) Poll(pm *powermeter.PowerMeter) (err error) {
+	s := Sample{Time: time.Now()}
+	var powerErr, energyErr error
+	s.Power, powerErr = pm.GetVal(powermeter.ID_POWER_ACTIVE)
+	s.PowerValid = powerErr == nil
+	s.Energy, energyErr = pm.GetVal(powermeter.ID_ENERGY_ACTIVE_CURR_ALL)
+	s.EnergyValid = energyErr == nil
+	if !s.PowerValid && !s.EnergyValid {
+		err = fmt.Errorf("failed to poll power meter: %w", errors.Join(powerErr, energyErr))
+		return
+	}
+	err = p.Add(s)
+	return
+}
+
+/*
+add a sample, distributing energy since the previous one over the intervals it covers
+
+energy comes from the difference of counters, or from power when the counter is missing or went backwards
+
+# Params
+
+s Sample: reading, later than the previous one
+
+# Returns
+
+err error: error if the sample is not later than the previous one or has no value
+*/
+func (p *Profile) Add(s Sample) (err error) {
+	if p.cfg.Slide == 0 {
+		err = errors.New("load profile not initialized")
+		return
+	}
+	if !s.PowerValid && !s.EnergyValid {
+		err = errors.New("sample without power and energy")
+		return
+	}
+	if !p.started {
+		p.started = true
+		p.last = s
+		return
+	}
+	if !s.Time.After(p.last.Time) {
+		err = fmt.Errorf("sample at %s is not later than the previous one at %s", s.Time.Format(time.DateTime), p.last.Time.Format(time.DateTime))
+		return
+	}
+	from, to := p.last.Time, s.Time
+	if to.Sub(from) <= p.cfg.MaxGap {
+		energy, estimated, ok := p.segmentEnergy(s)
+		if ok {
+			if to.Sub(from) > p.cfg.Slide {
+				// missed polls, energy is spread evenly over them
+				estimated = true
+			}
+			p.distribute(from, to, energy, estimated)
+		}
+	}
+	p.last = s
+	p.close(to)
+	return
+}
+
+// energy between the previous sample and s, in kWh
+func (p *Profile) segmentEnergy(s Sample) (energy float64, estimated bool, ok bool) {
+	if s.EnergyValid && p.last.EnergyValid {
+		if s.Energy >= p.last.Energy {
+			energy, ok = s.Energy-p.last.Energy, true
+			return
+		}
+		// counter reset or meter swap, the energy across it is lost
+		p.resets++
+		estimated = true
+	}
+	if s.PowerValid && p.last.PowerValid {
+		hours := s.Time.Sub(p.last.Time).Hours()
+		energy, ok = (s.Power+p.last.Power)/2*hours/1000, true
+		estimated = true
+		return
+	}
+	if estimated {
+		// reset with no power to fall back on, cover the time with nothing
+		ok = true
+	}
+	return
+}
+
+// spread energy of [from, to] over open intervals by overlap
+func (p *Profile) distribute(from time.Time, to time.Time, energy float64, estimated bool) {
+	total := to.Sub(from)
+	for start := p.align(from); start.Before(to); start = start.Add(p.cfg.Slide) {
+		end := start.Add(p.cfg.Slide)
+		lo, hi := maxTime(start, from), minTime(end, to)
+		overlap := hi.Sub(lo)
+		if overlap <= 0 {
+			continue
+		}
+		iv := p.openInterval(start)
+		iv.Energy += energy * float64(overlap) / float64(total)
+		iv.Coverage += float64(overlap) / float64(p.cfg.Slide)
+		iv.Estimated = iv.Estimated || estimated
+	}
+}
+
+// get open interval starting at start, creating it if absent
+func (p *Profile) openInterval(start time.Time) (iv *Interval) {
+	for i := range p.open {
+		if p.open[i].Start.Equal(start) {
+			iv = &p.open[i]
+			return
+		}
+	}
+	p.open = append(p.open, Interval{Start: start, End: start.Add(p.cfg.Slide)})
+	iv = &p.open[len(p.open)-1]
+	return
+}
+
+// close intervals ending by t, appending them to load curve and updating demand
+func (p *Profile) close(t time.Time) {
+	var still []Interval
+	for _, iv := range p.open {
+		if iv.End.After(t) {
+			still = append(still, iv)
+			continue
+		}
+		iv.Power = iv.Energy * 1000 / p.cfg.Slide.Hours()
+		if iv.Coverage > 1 {
+			iv.Coverage = 1
+		}
+		p.intervals = append(p.intervals, iv)
+		p.updateDemand(iv.End)
+	}
+	p.open = still
+	if len(p.intervals) > p.cfg.Retention {
+		p.intervals = append([]Interval{}, p.intervals[len(p.intervals)-p.cfg.Retention:]...)
+	}
+	days := int(time.Duration(p.cfg.Retention)*p.cfg.Slide/(24*time.Hour)) + 1
+	if len(p.peaks) > days {
+		p.peaks = append([]DailyPeak{}, p.peaks[len(p.peaks)-days:]...)
+	}
+}
+
+// compute demand of the period ending at end from closed intervals
+func (p *Profile) updateDemand(end time.Time) {
+	start := end.Add(-p.cfg.Period)
+	d := Demand{End: end}
+	covered := time.Duration(0)
+	for i := len(p.intervals) - 1; i >= 0 && !p.intervals[i].Start.Before(start); i-- {
+		iv := &p.intervals[i]
+		d.Power += iv.Energy
+		d.Estimated = d.Estimated || iv.Estimated || iv.Coverage < 1
+		covered += p.cfg.Slide
+	}
+	if covered < p.cfg.Period {
+		// not enough history for a full period yet
+		return
+	}
+	d.Power = d.Power * 1000 / p.cfg.Period.Hours()
+	p.demand, p.hasDemand = d, true
+	// a period ending at midnight belongs to the day before
+	day := p.day(end.Add(-time.Nanosecond))
+	if n := len(p.peaks); n > 0 && p.peaks[n-1].Day.Equal(day) {
+		if d.Power > p.peaks[n-1].Demand.Power {
+			p.peaks[n-1].Demand = d
+		}
+		return
+	}
+	p.peaks = append(p.peaks, DailyPeak{Day: day, Demand: d})
+}
+
+/*
+get the load curve of closed intervals, ordered by time
+
+# Returns
+
+intervals []Interval: intervals of slide length, at most Retention of them
+*/
+func (p *Profile) Intervals() (intervals []Interval) {
+	intervals = append(intervals, p.intervals...)
+	return
+}
+
+/*
+get demand of the latest full demand period
+
+# Returns
+
+d Demand: latest demand
+
+ok bool: false if less than one demand period is closed
+*/
+func (p *Profile) Demand() (d Demand, ok bool) {
+	d, ok = p.demand, p.hasDemand
+	return
+}
+
+/*
+get the highest demand of each day, ordered by day
+
+# Returns
+
+peaks []DailyPeak: peak of each day within retention
+*/
+func (p *Profile) DailyPeaks() (peaks []DailyPeak) {
+	peaks = append(peaks, p.peaks...)
+	return
+}
+
+/*
+get number of energy counter resets seen, such as after meter swap or counter wrap
+
+# Returns
+
+resets int: number of resets
+*/
+func (p *Profile) Resets() (resets int) {
+	resets = p.resets
+	return
+}
+
+// start of slide interval containing t
+func (p *Profile) align(t time.Time) time.Time {
+	t = t.In(p.cfg.Location)
+	day := p.day(t)
+	return day.Add(t.Sub(day) / p.cfg.Slide * p.cfg.Slide)
+}
+
+// start of day containing t
+func (p *Profile) day(t time.Time) time.Time {
+	t = t.In(p.cfg.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.cfg.Location)
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,185 @@
+package loadprofile
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// intervals and days are aligned in the time zone of config, not UTC
+var cst = time.FixedZone("CST", 8*3600)
+
+// midnight in cst that minutes of samples count from
+var midnight = time.Date(2026, 1, 5, 0, 0, 0, 0, cst)
+
+// sample at minute m after midnight with energy, or with power too if power is not 0, a negative energy is left unread
+func sample(m int, energy float64, power float64) Sample {
+	return Sample{
+		Time:        midnight.Add(time.Duration(m) * time.Minute),
+		Energy:      energy,
+		EnergyValid: energy >= 0,
+		Power:       power,
+		PowerValid:  power != 0,
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	type interval struct {
+		start     int
+		energy    float64
+		coverage  float64
+		estimated bool
+	}
+	tests := []struct {
+		name      string
+		samples   []Sample
+		intervals []interval
+		resets    int
+	}{
+		{
+			name:      "aligned polls",
+			samples:   []Sample{sample(0, 0, 0), sample(15, 1, 0), sample(30, 3, 0)},
+			intervals: []interval{{0, 1, 1, false}, {15, 2, 1, false}},
+		},
+		{
+			name:      "poll across interval boundary",
+			samples:   []Sample{sample(10, 0, 0), sample(20, 1, 0), sample(30, 1.5, 0)},
+			intervals: []interval{{0, 0.5, 1.0 / 3, false}, {15, 1, 1, false}},
+		},
+		{
+			name:      "missed polls spread evenly",
+			samples:   []Sample{sample(0, 0, 0), sample(45, 3, 0)},
+			intervals: []interval{{0, 1, 1, true}, {15, 1, 1, true}, {30, 1, 1, true}},
+		},
+		{
+			name:      "gap beyond MaxGap left uncovered",
+			samples:   []Sample{sample(0, 0, 0), sample(90, 5, 0), sample(105, 6, 0)},
+			intervals: []interval{{90, 1, 1, false}},
+		},
+		{
+			name:      "power only",
+			samples:   []Sample{sample(0, -1, 4000), sample(15, -1, 4000)},
+			intervals: []interval{{0, 1, 1, true}},
+		},
+		{
+			name:      "counter reset integrated from power",
+			samples:   []Sample{sample(0, 10, 2000), sample(15, 0, 2000)},
+			intervals: []interval{{0, 0.5, 1, true}},
+			resets:    1,
+		},
+		{
+			name:      "counter reset without power",
+			samples:   []Sample{sample(0, 10, 0), sample(15, 0, 0)},
+			intervals: []interval{{0, 0, 1, true}},
+			resets:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Profile
+			if err := p.Init(Config{Location: cst}); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.samples {
+				if err := p.Add(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := p.Intervals()
+			if len(got) != len(tt.intervals) {
+				t.Fatalf("intervals %+v, want %d of them", got, len(tt.intervals))
+			}
+			for i, want := range tt.intervals {
+				iv := got[i]
+				start := midnight.Add(time.Duration(want.start) * time.Minute)
+				if !iv.Start.Equal(start) || !iv.End.Equal(start.Add(15*time.Minute)) ||
+					math.Abs(iv.Energy-want.energy) > 1e-9 || math.Abs(iv.Coverage-want.coverage) > 1e-9 || iv.Estimated != want.estimated {
+					t.Errorf("interval %d %+v, want %+v", i, iv, want)
+				}
+				if math.Abs(iv.Power-iv.Energy*4000) > 1e-6 {
+					t.Errorf("interval %d power %g, want %g", i, iv.Power, iv.Energy*4000)
+				}
+			}
+			if p.Resets() != tt.resets {
+				t.Errorf("resets %d, want %d", p.Resets(), tt.resets)
+			}
+		})
+	}
+}
+
+func TestSlidingDemand(t *testing.T) {
+	var p Profile
+	if err := p.Init(Config{Period: 30 * time.Minute, Slide: 15 * time.Minute, Location: cst}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		sample Sample
+		ok     bool
+		power  float64
+	}{
+		{sample: sample(0, 0, 0)},
+		{sample: sample(15, 1, 0)},
+		{sample: sample(30, 2, 0), ok: true, power: 4000},
+		{sample: sample(45, 4, 0), ok: true, power: 6000},
+		{sample: sample(60, 4.5, 0), ok: true, power: 5000},
+	}
+	for i, st := range steps {
+		if err := p.Add(st.sample); err != nil {
+			t.Fatal(err)
+		}
+		d, ok := p.Demand()
+		if ok != st.ok || math.Abs(d.Power-st.power) > 1e-6 {
+			t.Fatalf("step %d: demand %+v ok %v, want %g ok %v", i, d, ok, st.power, st.ok)
+		}
+	}
+	peaks := p.DailyPeaks()
+	if len(peaks) != 1 || !peaks[0].Day.Equal(midnight) || math.Abs(peaks[0].Demand.Power-6000) > 1e-6 || !peaks[0].Demand.End.Equal(midnight.Add(45*time.Minute)) {
+		t.Fatalf("peaks %+v, want 6000 W ending 00:45", peaks)
+	}
+}
+
+func TestAddRejects(t *testing.T) {
+	var p Profile
+	if err := p.Add(sample(0, 0, 0)); err == nil {
+		t.Fatal("no error before Init")
+	}
+	if err := p.Init(Config{Period: 15 * time.Minute, Slide: 10 * time.Minute}); err == nil {
+		t.Fatal("no error with slide not dividing period")
+	}
+	p.Init(Config{Location: cst})
+	if err := p.Add(sample(0, -1, 0)); err == nil {
+		t.Fatal("no error adding sample without values")
+	}
+	p.Add(sample(15, 0, 0))
+	if err := p.Add(sample(15, 1, 0)); err == nil {
+		t.Fatal("no error adding sample not later than the previous one")
+	}
+}
+
+func TestDailyPeaksAtMidnight(t *testing.T) {
+	var p Profile
+	p.Init(Config{Location: cst})
+	// the period ending at midnight belongs to the day before, the one after it to the new day
+	for _, s := range []Sample{sample(-30, 0, 0), sample(-15, 1, 0), sample(0, 3, 0), sample(15, 3.5, 0)} {
+		if err := p.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	peaks := p.DailyPeaks()
+	if len(peaks) != 2 {
+		t.Fatalf("peaks %+v, want 2 days", peaks)
+	}
+	if !peaks[0].Day.Equal(midnight.AddDate(0, 0, -1)) || math.Abs(peaks[0].Demand.Power-8000) > 1e-6 || !peaks[0].Demand.End.Equal(midnight) {
+		t.Errorf("peak of day before %+v, want 8000 W ending at midnight", peaks[0])
+	}
+	if !peaks[1].Day.Equal(midnight) || math.Abs(peaks[1].Demand.Power-2000) > 1e-6 {
+		t.Errorf("peak of day %+v, want 2000 W", peaks[1])
+	}
+	// midnight of UTC falls at 08:00 in cst and starts no day
+	for _, s := range []Sample{sample(465, 4, 0), sample(480, 4.25, 0), sample(495, 4.5, 0)} {
+		p.Add(s)
+	}
+	if peaks = p.DailyPeaks(); len(peaks) != 2 {
+		t.Fatalf("peaks %+v, want 2 days", peaks)
+	}
+}
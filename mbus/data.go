@@ -0,0 +1,466 @@
+/*
+ * @filename	data.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Decoder of M-Bus variable data structure, DIF/VIF records with units
+ * @comment		--
+ */
+
+package mbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// data record functions, bits 4 - 5 of DIF
+const (
+	FUNC_INSTANT byte = iota
+	FUNC_MAX
+	FUNC_MIN
+	FUNC_ERROR
+)
+
+// physical quantities of records, decoded from VIF
+const (
+	QUANTITY_UNKNOWN uint8 = iota
+	QUANTITY_ENERGY
+	QUANTITY_VOLUME
+	QUANTITY_MASS
+	QUANTITY_ON_TIME
+	QUANTITY_OPERATING_TIME
+	QUANTITY_POWER
+	QUANTITY_VOLUME_FLOW
+	QUANTITY_MASS_FLOW
+	QUANTITY_TEMP_FLOW
+	QUANTITY_TEMP_RETURN
+	QUANTITY_TEMP_DIFF
+	QUANTITY_TEMP_EXTERNAL
+	QUANTITY_PRESSURE
+	QUANTITY_TIME_POINT
+	QUANTITY_FABRICATION_NO
+	QUANTITY_BUS_ADDRESS
+	QUANTITY_ERROR_FLAGS
+	QUANTITY_MANUFACTURER
+)
+
+// accumulation of records, decoded from combinable VIFE 0x3B and 0x3C
+const (
+	// accumulated regardless of flow direction, or no VIFE given
+	ACCUM_ALL uint8 = iota
+	// accumulated only on positive contributions, forward flow
+	ACCUM_FORWARD
+	// accumulated only on negative contributions, backward flow
+	ACCUM_BACKWARD
+)
+
+// header of variable data structure
+type Header struct {
+	// identification number, 8 digits
+	ID string
+	// manufacturer code, such as "KAM"
+	Manufacturer string
+	// version of the slave
+	Version byte
+	// medium, such as 0x07 for water
+	Medium byte
+	// access number, incremented by slave on each response
+	AccessNo byte
+	// status byte of the slave
+	Status byte
+}
+
+// data record of variable data structure
+type Record struct {
+	// function, using macro FUNC_*
+	Function byte
+	// storage number, 0 is the current value, others are history values
+	Storage uint64
+	// tariff number
+	Tariff uint32
+	// subunit number
+	Subunit uint32
+	// physical quantity, using macro QUANTITY_*
+	Quantity uint8
+	// unit of Value in SI form, such as "Wh", "m^3", "m^3/h", "degC"
+	Unit string
+	// value with VIF multiplier applied, 0 for non-numeric records
+	Value float64
+	// raw data bytes
+	Raw []byte
+	// accumulation of Value, using macro ACCUM_*
+	Accumulation uint8
+	// VIF extensions, only accumulation interpreted
+	VIFE []byte
+}
+
+// decoded variable data structure
+type VariableData struct {
+	// header, only set on CI_RSP_VARIABLE
+	Header Header
+	// data records
+	Records []Record
+}
+
+// unit description of VIF, multiplier is 10^(vif&mask + exp)
+type vifUnit struct {
+	quantity uint8
+	unit     string
+	mask     byte
+	exp      int
+}
+
+// primary VIF table, indexed by VIF without extension bit
+func lookupVIF(vif byte) (vu vifUnit, ok bool) {
+	ok = true
+	switch {
+	case vif <= 0x07:
+		vu = vifUnit{QUANTITY_ENERGY, "Wh", 0x07, -3}
+	case vif <= 0x0F:
+		vu = vifUnit{QUANTITY_ENERGY, "J", 0x07, 0}
+	case vif <= 0x17:
+		vu = vifUnit{QUANTITY_VOLUME, "m^3", 0x07, -6}
+	case vif <= 0x1F:
+		vu = vifUnit{QUANTITY_MASS, "kg", 0x07, -3}
+	case vif <= 0x23:
+		vu = vifUnit{QUANTITY_ON_TIME, durationUnits[vif&0x03], 0x00, 0}
+	case vif <= 0x27:
+		vu = vifUnit{QUANTITY_OPERATING_TIME, durationUnits[vif&0x03], 0x00, 0}
+	case vif <= 0x2F:
+		vu = vifUnit{QUANTITY_POWER, "W", 0x07, -3}
+	case vif <= 0x37:
+		vu = vifUnit{QUANTITY_POWER, "J/h", 0x07, 0}
+	case vif <= 0x3F:
+		vu = vifUnit{QUANTITY_VOLUME_FLOW, "m^3/h", 0x07, -6}
+	case vif <= 0x47:
+		vu = vifUnit{QUANTITY_VOLUME_FLOW, "m^3/min", 0x07, -7}
+	case vif <= 0x4F:
+		vu = vifUnit{QUANTITY_VOLUME_FLOW, "m^3/s", 0x07, -9}
+	case vif <= 0x57:
+		vu = vifUnit{QUANTITY_MASS_FLOW, "kg/h", 0x07, -3}
+	case vif <= 0x5B:
+		vu = vifUnit{QUANTITY_TEMP_FLOW, "degC", 0x03, -3}
+	case vif <= 0x5F:
+		vu = vifUnit{QUANTITY_TEMP_RETURN, "degC", 0x03, -3}
+	case vif <= 0x63:
+		vu = vifUnit{QUANTITY_TEMP_DIFF, "K", 0x03, -3}
+	case vif <= 0x67:
+		vu = vifUnit{QUANTITY_TEMP_EXTERNAL, "degC", 0x03, -3}
+	case vif <= 0x6B:
+		vu = vifUnit{QUANTITY_PRESSURE, "bar", 0x03, -3}
+	case vif <= 0x6D:
+		vu = vifUnit{QUANTITY_TIME_POINT, "", 0x00, 0}
+	case vif == 0x78:
+		vu = vifUnit{QUANTITY_FABRICATION_NO, "", 0x00, 0}
+	case vif == 0x7A:
+		vu = vifUnit{QUANTITY_BUS_ADDRESS, "", 0x00, 0}
+	default:
+		ok = false
+	}
+	return
+}
+
+var durationUnits = [4]string{"s", "min", "h", "d"}
+
+/*
+decode user data of RSP_UD into header and records
+
+# Params
+
+ci byte: control information field, CI_RSP_VARIABLE or CI_RSP_VARIABLE_S
+
+data []byte: user data following CI
+
+# Returns
+
+vd VariableData: decoded header and records
+
+err error: error, records decoded before a malformed one are kept
+*/
+func DecodeVariableData(ci byte, data []byte) (vd VariableData, err error) {
+	switch ci {
+	case CI_RSP_VARIABLE:
+		if len(data) < 12 {
+			err = errors.New("mbus short variable data header")
+			return
+		}
+		vd.Header.ID = fmt.Sprintf("%02x%02x%02x%02x", data[3], data[2], data[1], data[0])
+		vd.Header.Manufacturer = decodeManufacturer(binary.LittleEndian.Uint16(data[4:6]))
+		vd.Header.Version = data[6]
+		vd.Header.Medium = data[7]
+		vd.Header.AccessNo = data[8]
+		vd.Header.Status = data[9]
+		data = data[12:]
+	case CI_RSP_VARIABLE_S:
+		if len(data) < 4 {
+			err = errors.New("mbus short variable data header")
+			return
+		}
+		vd.Header.AccessNo = data[0]
+		vd.Header.Status = data[1]
+		data = data[4:]
+	default:
+		err = fmt.Errorf("mbus unsupported control information 0x%02x", ci)
+		return
+	}
+	for len(data) > 0 {
+		var rec Record
+		var n int
+		if data[0] == 0x2F {
+			// idle filler
+			data = data[1:]
+			continue
+		}
+		if data[0] == 0x0F || data[0] == 0x1F {
+			// manufacturer specific data up to the end
+			break
+		}
+		rec, n, err = decodeRecord(data)
+		if err != nil {
+			return
+		}
+		vd.Records = append(vd.Records, rec)
+		data = data[n:]
+	}
+	return
+}
+
+// decode one record, returning number of bytes consumed
+func decodeRecord(data []byte) (rec Record, n int, err error) {
+	dif := data[0]
+	n = 1
+	rec.Function = (dif >> 4) & 0x03
+	rec.Storage = uint64(dif>>6) & 0x01
+	ext := dif&0x80 != 0
+	for i := 0; ext; i++ {
+		if n >= len(data) || i >= 10 {
+			err = errors.New("mbus truncated DIFE")
+			return
+		}
+		dife := data[n]
+		n++
+		rec.Storage |= uint64(dife&0x0F) << (1 + 4*i)
+		rec.Tariff |= uint32(dife>>4&0x03) << (2 * i)
+		rec.Subunit |= uint32(dife>>6&0x01) << i
+		ext = dife&0x80 != 0
+	}
+	if n >= len(data) {
+		err = errors.New("mbus truncated VIF")
+		return
+	}
+	vif := data[n]
+	n++
+	ext = vif&0x80 != 0
+	for ext {
+		if n >= len(data) {
+			err = errors.New("mbus truncated VIFE")
+			return
+		}
+		rec.VIFE = append(rec.VIFE, data[n])
+		ext = data[n]&0x80 != 0
+		n++
+	}
+	var plainUnit string
+	if vif&0x7F == 0x7C {
+		// plain text VIF, unit given in reversed ASCII after all VIFEs
+		if n >= len(data) || n+1+int(data[n]) > len(data) {
+			err = errors.New("mbus truncated plain text VIF")
+			return
+		}
+		l := int(data[n])
+		for i := n + l; i > n; i-- {
+			plainUnit += string(data[i])
+		}
+		n += 1 + l
+	}
+	var size int
+	size, err = dataSize(dif&0x0F, data[n:])
+	if err != nil {
+		return
+	}
+	if dif&0x0F == 0x0D {
+		// variable length, skip the length byte
+		n++
+	}
+	if n+size > len(data) {
+		err = errors.New("mbus truncated record data")
+		return
+	}
+	rec.Raw = append([]byte{}, data[n:n+size]...)
+	n += size
+
+	var raw float64
+	var numeric bool
+	raw, numeric, err = decodeValue(dif&0x0F, rec.Raw)
+	if err != nil {
+		return
+	}
+	switch {
+	case plainUnit != "":
+		rec.Unit = plainUnit
+		rec.Value = raw
+	case vif == 0xFD && len(rec.VIFE) > 0 && rec.VIFE[0]&0x7F == 0x17:
+		rec.Quantity = QUANTITY_ERROR_FLAGS
+		rec.Value = raw
+	case vif&0x7F == 0x7F || vif == 0xFB || vif == 0xFD:
+		rec.Quantity = QUANTITY_MANUFACTURER
+		rec.Value = raw
+	default:
+		vu, ok := lookupVIF(vif & 0x7F)
+		if !ok {
+			break
+		}
+		rec.Quantity = vu.quantity
+		rec.Unit = vu.unit
+		rec.Accumulation = accumulation(rec.VIFE)
+		if numeric {
+			rec.Value = raw * math.Pow10(int(vif&vu.mask)+vu.exp)
+		}
+	}
+	return
+}
+
+// accumulation given by combinable VIFEs of primary VIF
+func accumulation(vife []byte) (accum uint8) {
+	for _, e := range vife {
+		switch e & 0x7F {
+		case 0x3B:
+			accum = ACCUM_FORWARD
+		case 0x3C:
+			accum = ACCUM_BACKWARD
+		}
+	}
+	return
+}
+
+// size of data field of DIF coding
+func dataSize(coding byte, data []byte) (size int, err error) {
+	sizes := [16]int{0, 1, 2, 3, 4, 4, 6, 8, 0, 1, 2, 3, 4, -1, 6, 0}
+	size = sizes[coding]
+	if size >= 0 {
+		return
+	}
+	if len(data) == 0 {
+		err = errors.New("mbus truncated variable length data")
+		return
+	}
+	// LVAR of EN 13757-3
+	lvar := data[0]
+	switch {
+	case lvar <= 0xBF:
+		// text of LVAR characters
+		size = int(lvar)
+	case lvar <= 0xCF:
+		// positive BCD of (LVAR - C0h) * 2 digits
+		size = int(lvar - 0xC0)
+	case lvar <= 0xDF:
+		// negative BCD of (LVAR - D0h) * 2 digits
+		size = int(lvar - 0xD0)
+	case lvar <= 0xEF:
+		// binary number of LVAR - E0h bytes
+		size = int(lvar - 0xE0)
+	case lvar <= 0xFA:
+		// binary number of 4 * (LVAR - ECh) bytes
+		size = 4 * int(lvar-0xEC)
+	default:
+		err = fmt.Errorf("mbus reserved LVAR 0x%02x", lvar)
+	}
+	return
+}
+
+// decode data field of DIF coding, integers are little-endian two's complement, BCD with leading 0xF is negative
+func decodeValue(coding byte, raw []byte) (val float64, numeric bool, err error) {
+	switch coding {
+	case 0x01, 0x02, 0x03, 0x04, 0x06, 0x07:
+		var u uint64
+		for i := len(raw) - 1; i >= 0; i-- {
+			u = u<<8 | uint64(raw[i])
+		}
+		bits := uint(len(raw) * 8)
+		if bits < 64 && u&(1<<(bits-1)) != 0 {
+			u |= ^uint64(0) << bits
+		}
+		val = float64(int64(u))
+		numeric = true
+	case 0x05:
+		val = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw)))
+		numeric = true
+	case 0x09, 0x0A, 0x0B, 0x0C, 0x0E:
+		negative := false
+		for i := len(raw) - 1; i >= 0; i-- {
+			hi, lo := raw[i]>>4, raw[i]&0x0F
+			if i == len(raw)-1 && hi == 0x0F {
+				negative = true
+				hi = 0
+			}
+			if hi > 9 || lo > 9 {
+				err = fmt.Errorf("mbus bad bcd byte 0x%02x", raw[i])
+				return
+			}
+			val = val*100 + float64(hi)*10 + float64(lo)
+		}
+		if negative {
+			val *= -1
+		}
+		numeric = true
+	}
+	return
+}
+
+// decode manufacturer id into 3 letters
+func decodeManufacturer(m uint16) (s string) {
+	s = string([]byte{byte(m>>10&0x1F) + 64, byte(m>>5&0x1F) + 64, byte(m&0x1F) + 64})
+	return
+}
+
+/*
+find the first record of quantity with current value, instantaneous function, storage 0, tariff 0 and subunit 0,
+records accumulated only on backward flow are skipped
+
+# Params
+
+quantity uint8: physical quantity, using macro QUANTITY_*
+
+# Returns
+
+rec Record: the record
+
+ok bool: if the record is found
+*/
+func (vd *VariableData) Find(quantity uint8) (rec Record, ok bool) {
+	rec, ok = vd.find(quantity, func(accum uint8) bool { return accum != ACCUM_BACKWARD })
+	return
+}
+
+/*
+find the first record of quantity accumulated only on backward flow, such as reverse volume,
+with current value, instantaneous function, storage 0, tariff 0 and subunit 0
+
+# Params
+
+quantity uint8: physical quantity, using macro QUANTITY_*
+
+# Returns
+
+rec Record: the record
+
+ok bool: if the record is found
+*/
+func (vd *VariableData) FindBackward(quantity uint8) (rec Record, ok bool) {
+	rec, ok = vd.find(quantity, func(accum uint8) bool { return accum == ACCUM_BACKWARD })
+	return
+}
+
+// first current record of quantity with accumulation matched
+func (vd *VariableData) find(quantity uint8, match func(accum uint8) bool) (rec Record, ok bool) {
+	for _, r := range vd.Records {
+		if r.Quantity == quantity && r.Function == FUNC_INSTANT && r.Storage == 0 && r.Tariff == 0 && r.Subunit == 0 && match(r.Accumulation) {
+			rec = r
+			ok = true
+			return
+		}
+	}
+	return
+}
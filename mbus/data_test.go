@@ -0,0 +1,132 @@
+package mbus
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDataSizeLVAR(t *testing.T) {
+	cases := []struct {
+		lvar byte
+		want int
+		bad  bool
+	}{
+		{lvar: 0x05, want: 5},
+		{lvar: 0x45, want: 0x45},
+		{lvar: 0xC3, want: 3},
+		{lvar: 0xD2, want: 2},
+		{lvar: 0xE4, want: 4},
+		{lvar: 0xF0, want: 16},
+		{lvar: 0xFA, want: 56},
+		{lvar: 0xFB, bad: true},
+	}
+	for _, c := range cases {
+		size, err := dataSize(0x0D, []byte{c.lvar})
+		if c.bad {
+			if err == nil {
+				t.Errorf("LVAR 0x%02x: want error", c.lvar)
+			}
+			continue
+		}
+		if err != nil || size != c.want {
+			t.Errorf("LVAR 0x%02x: size %d, err %v, want %d", c.lvar, size, err, c.want)
+		}
+	}
+}
+
+func TestDecodeVariableData(t *testing.T) {
+	data := []byte{
+		// short header: access number, status, signature
+		0x01, 0x00, 0x00, 0x00,
+		// variable length positive BCD of 4 digits, volume in l
+		0x0D, 0x13, 0xC2, 0x34, 0x12,
+		// plain text unit "l/h" after one VIFE, 8 bit integer
+		0x01, 0xFC, 0x74, 0x03, 'h', '/', 'l', 0x05,
+		// 32 bit integer volume in l
+		0x04, 0x13, 0x64, 0x00, 0x00, 0x00,
+		// 8 digit BCD volume in l
+		0x0C, 0x13, 0x78, 0x56, 0x34, 0x12,
+	}
+	vd, err := DecodeVariableData(CI_RSP_VARIABLE_S, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vd.Records) != 4 {
+		t.Fatalf("%d records, want 4", len(vd.Records))
+	}
+	if r := vd.Records[0]; len(r.Raw) != 2 || r.Raw[0] != 0x34 {
+		t.Errorf("variable length record raw % x", r.Raw)
+	}
+	if r := vd.Records[1]; r.Unit != "l/h" || r.Value != 5 || len(r.VIFE) != 1 || r.VIFE[0] != 0x74 {
+		t.Errorf("plain text record %+v", r)
+	}
+	if r := vd.Records[2]; r.Quantity != QUANTITY_VOLUME || math.Abs(r.Value-0.1) > 1e-9 {
+		t.Errorf("integer record %+v", r)
+	}
+	if r := vd.Records[3]; math.Abs(r.Value-12345.678) > 1e-6 {
+		t.Errorf("bcd record %+v", r)
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	cases := []struct {
+		name    string
+		coding  byte
+		raw     []byte
+		want    float64
+		numeric bool
+		bad     bool
+	}{
+		{name: "int8", coding: 0x01, raw: []byte{0x7F}, want: 127, numeric: true},
+		{name: "int8 negative", coding: 0x01, raw: []byte{0xFF}, want: -1, numeric: true},
+		{name: "int16", coding: 0x02, raw: []byte{0x34, 0x12}, want: 0x1234, numeric: true},
+		{name: "int24 negative", coding: 0x03, raw: []byte{0x00, 0x00, 0x80}, want: -0x800000, numeric: true},
+		{name: "int32", coding: 0x04, raw: []byte{0x78, 0x56, 0x34, 0x12}, want: 0x12345678, numeric: true},
+		{name: "int64 negative", coding: 0x07, raw: []byte{0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, want: -2, numeric: true},
+		{name: "real32", coding: 0x05, raw: []byte{0x00, 0x00, 0xC0, 0x3F}, want: 1.5, numeric: true},
+		{name: "bcd2", coding: 0x09, raw: []byte{0x42}, want: 42, numeric: true},
+		{name: "bcd8", coding: 0x0C, raw: []byte{0x78, 0x56, 0x34, 0x12}, want: 12345678, numeric: true},
+		{name: "bcd negative", coding: 0x0B, raw: []byte{0x45, 0x23, 0xF1}, want: -12345, numeric: true},
+		{name: "bcd bad digit", coding: 0x0A, raw: []byte{0x1A, 0x00}, bad: true},
+		{name: "no data", coding: 0x00, raw: nil},
+		{name: "variable length", coding: 0x0D, raw: []byte{0x41}},
+	}
+	for _, c := range cases {
+		val, numeric, err := decodeValue(c.coding, c.raw)
+		if c.bad {
+			if err == nil {
+				t.Errorf("%s: decoded %g, want error", c.name, val)
+			}
+			continue
+		}
+		if err != nil || numeric != c.numeric || math.Abs(val-c.want) > 1e-9 {
+			t.Errorf("%s: decoded %g numeric %v, err %v, want %g numeric %v", c.name, val, numeric, err, c.want, c.numeric)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	data := []byte{
+		// short header: access number, status, signature
+		0x01, 0x00, 0x00, 0x00,
+		// 32 bit integer volume in l accumulated on backward flow only, listed first
+		0x04, 0x93, 0x3C, 0x0A, 0x00, 0x00, 0x00,
+		// 32 bit integer volume in l of storage 1
+		0x44, 0x13, 0xC8, 0x00, 0x00, 0x00,
+		// 32 bit integer volume in l
+		0x04, 0x13, 0xE8, 0x03, 0x00, 0x00,
+	}
+	vd, err := DecodeVariableData(CI_RSP_VARIABLE_S, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := vd.Find(QUANTITY_VOLUME); !ok || r.Accumulation != ACCUM_ALL || math.Abs(r.Value-1) > 1e-9 {
+		t.Errorf("found %+v, want volume 1 m^3", r)
+	}
+	if r, ok := vd.FindBackward(QUANTITY_VOLUME); !ok || r.Accumulation != ACCUM_BACKWARD || math.Abs(r.Value-0.01) > 1e-9 {
+		t.Errorf("found %+v, want backward volume 0.01 m^3", r)
+	}
+	if r, ok := vd.FindBackward(QUANTITY_ENERGY); ok {
+		t.Errorf("found %+v, want no backward energy", r)
+	}
+}
@@ -0,0 +1,380 @@
+/*
+ * @filename	mbus.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Framing and client of wired M-Bus (EN 13757-2/3) meters
+ * @comment		frames are carried through MBRTGateway raw link, usually a level converter at mbusovertcp://<ip>:<port>
+ */
+
+package mbus
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+)
+
+// control field values of link layer
+const (
+	C_SND_NKE byte = 0x40
+	C_SND_UD  byte = 0x53
+	C_REQ_UD2 byte = 0x5B
+	C_RSP_UD  byte = 0x08
+	// frame count bit, toggled between successive REQ_UD2 and SND_UD to one slave
+	C_FLAG_FCB byte = 0x20
+)
+
+// control information field values
+const (
+	CI_SELECT         byte = 0x52
+	CI_RSP_VARIABLE   byte = 0x72
+	CI_RSP_VARIABLE_S byte = 0x7A
+)
+
+// special primary addresses
+const (
+	// highest primary address of slaves
+	ADDR_PRIMARY_MAX byte = 250
+	// slave selected by secondary address
+	ADDR_NETWORK_LAYER byte = 0xFD
+	// broadcast, only one slave may be on the bus as all of them answer
+	ADDR_BROADCAST_REPLY byte = 0xFE
+	// broadcast without reply
+	ADDR_BROADCAST byte = 0xFF
+)
+
+// single character acknowledge
+const ACK byte = 0xE5
+
+// frame of M-Bus link layer, a single character acknowledge has Ack set and others zero
+type Frame struct {
+	// single character acknowledge
+	Ack bool
+	// control field
+	C byte
+	// primary address
+	A byte
+	// control information field, long frames only
+	CI byte
+	// user data following CI, long frames only
+	Data []byte
+	// if the frame is a long frame
+	Long bool
+}
+
+/*
+encode frame into bytes, short frame is used when not Long
+
+# Params
+
+f Frame: frame to encode
+
+# Returns
+
+buf []byte: encoded frame
+*/
+func Encode(f Frame) (buf []byte) {
+	if f.Ack {
+		buf = []byte{ACK}
+		return
+	}
+	if !f.Long {
+		buf = []byte{0x10, f.C, f.A, f.C + f.A, 0x16}
+		return
+	}
+	l := byte(3 + len(f.Data))
+	buf = []byte{0x68, l, l, 0x68, f.C, f.A, f.CI}
+	buf = append(buf, f.Data...)
+	buf = append(buf, checksum(buf[4:]), 0x16)
+	return
+}
+
+/*
+decode bytes into frame
+
+# Params
+
+buf []byte: frame bytes
+
+# Returns
+
+f Frame: decoded frame
+
+err error: error if the frame is malformed
+*/
+func Decode(buf []byte) (f Frame, err error) {
+	if len(buf) == 0 {
+		err = errors.New("mbus empty frame")
+		return
+	}
+	switch buf[0] {
+	case ACK:
+		f.Ack = true
+	case 0x10:
+		if len(buf) < 5 || buf[4] != 0x16 {
+			err = errors.New("mbus bad short frame")
+			return
+		}
+		if buf[1]+buf[2] != buf[3] {
+			err = errors.New("mbus bad checksum")
+			return
+		}
+		f.C, f.A = buf[1], buf[2]
+	case 0x68:
+		if len(buf) < 9 || buf[1] != buf[2] || buf[3] != 0x68 || buf[1] < 3 {
+			err = errors.New("mbus bad long frame header")
+			return
+		}
+		l := int(buf[1])
+		if len(buf) < 6+l || buf[5+l] != 0x16 {
+			err = errors.New("mbus bad long frame tail")
+			return
+		}
+		if checksum(buf[4:4+l]) != buf[4+l] {
+			err = errors.New("mbus bad checksum")
+			return
+		}
+		f.Long = true
+		f.C, f.A, f.CI = buf[4], buf[5], buf[6]
+		f.Data = append([]byte{}, buf[7:4+l]...)
+	default:
+		err = fmt.Errorf("mbus bad frame start 0x%02x", buf[0])
+	}
+	return
+}
+
+/*
+read exactly one frame from link, for gateway.Transact
+
+# Params
+
+r io.Reader: link to read from
+
+# Returns
+
+buf []byte: frame bytes
+
+err error: error
+*/
+func ReadFrame(r io.Reader) (buf []byte, err error) {
+	br := bufio.NewReader(r)
+	var b byte
+	for {
+		b, err = br.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == ACK || b == 0x10 || b == 0x68 {
+			break
+		}
+	}
+	switch b {
+	case ACK:
+		buf = []byte{ACK}
+	case 0x10:
+		buf = make([]byte, 5)
+		buf[0] = b
+		_, err = io.ReadFull(br, buf[1:])
+	case 0x68:
+		head := make([]byte, 4)
+		head[0] = b
+		_, err = io.ReadFull(br, head[1:])
+		if err != nil {
+			return
+		}
+		rest := make([]byte, int(head[1])+2)
+		_, err = io.ReadFull(br, rest)
+		buf = append(head, rest...)
+	}
+	return
+}
+
+func checksum(buf []byte) (cs byte) {
+	for _, b := range buf {
+		cs += b
+	}
+	return
+}
+
+// secondary address of slave, fields set to wildcard match any slave
+type SecondaryAddr struct {
+	// identification number, 8 digits, 'F' digits are wildcard
+	ID string
+	// manufacturer code, 0xFFFF is wildcard
+	Manufacturer uint16
+	// version, 0xFF is wildcard
+	Version byte
+	// medium, 0xFF is wildcard
+	Medium byte
+}
+
+// encode secondary address into selection data
+func (sa SecondaryAddr) encode() (data []byte, err error) {
+	id := strings.ToUpper(strings.TrimSpace(sa.ID))
+	if len(id) == 0 || len(id) > 8 {
+		err = fmt.Errorf("invalid mbus secondary address %q", sa.ID)
+		return
+	}
+	id = strings.Repeat("0", 8-len(id)) + id
+	data = make([]byte, 0, 8)
+	for i := 0; i < 4; i++ {
+		var n uint64
+		n, err = strconv.ParseUint(id[6-2*i:8-2*i], 16, 8)
+		if err != nil {
+			err = fmt.Errorf("invalid mbus secondary address %q", sa.ID)
+			return
+		}
+		data = append(data, byte(n))
+	}
+	data = append(data, byte(sa.Manufacturer), byte(sa.Manufacturer>>8), sa.Version, sa.Medium)
+	return
+}
+
+// client of one M-Bus slave behind a gateway
+type Client struct {
+	gateway *gateway.MBRTGateway
+	// primary address, ADDR_NETWORK_LAYER when secondary address is used
+	primary byte
+	// secondary address, nil when primary address is used
+	secondary *SecondaryAddr
+	// frame count bit of next request
+	fcb     bool
+	retries int
+}
+
+/*
+initialize client instance
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that slave actually connect to, and needs to be initialized in advance
+
+addr string: primary address ranged 0 - 250 such as "5", or 8 digit secondary address such as "12345678"
+
+# Returns
+
+err error: error
+*/
+func (c *Client) Init(gw *gateway.MBRTGateway, addr string) (err error) {
+	if gw == nil {
+		err = errors.New("nil gateway")
+		return
+	}
+	addr = strings.TrimSpace(addr)
+	if len(addr) == 8 {
+		sa := &SecondaryAddr{ID: addr, Manufacturer: 0xFFFF, Version: 0xFF, Medium: 0xFF}
+		if _, err = sa.encode(); err != nil {
+			return
+		}
+		c.primary = ADDR_NETWORK_LAYER
+		c.secondary = sa
+	} else {
+		var n uint64
+		n, err = strconv.ParseUint(addr, 10, 8)
+		if err != nil || n > uint64(ADDR_PRIMARY_MAX) {
+			err = fmt.Errorf("invalid mbus primary address %q", addr)
+			return
+		}
+		c.primary = byte(n)
+		c.secondary = nil
+	}
+	c.gateway = gw
+	c.fcb = true
+	c.retries = 3
+	return
+}
+
+// send frame and read response, with retries
+func (c *Client) transact(req Frame, expectResp bool) (resp Frame, err error) {
+	if c.gateway == nil {
+		err = errors.New("mbus client not initialized")
+		return
+	}
+	readResp := ReadFrame
+	if !expectResp {
+		readResp = nil
+	}
+	var buf []byte
+	for retry := c.retries; ; retry-- {
+		buf, err = c.gateway.Transact(Encode(req), readResp)
+		if err == nil && expectResp {
+			resp, err = Decode(buf)
+		}
+		if err == nil {
+			break
+		}
+		if retry <= 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return
+}
+
+/*
+initialize slave by SND_NKE, resetting its frame count bit, and select it if secondary address is used
+
+# Returns
+
+err error: error
+*/
+func (c *Client) Reset() (err error) {
+	var resp Frame
+	if c.secondary != nil {
+		var data []byte
+		data, err = c.secondary.encode()
+		if err != nil {
+			return
+		}
+		resp, err = c.transact(Frame{Long: true, C: C_SND_UD, A: ADDR_NETWORK_LAYER, CI: CI_SELECT, Data: data}, true)
+	} else {
+		resp, err = c.transact(Frame{C: C_SND_NKE, A: c.primary}, true)
+	}
+	if err != nil {
+		return
+	}
+	if !resp.Ack {
+		err = errors.New("mbus slave did not acknowledge")
+		return
+	}
+	c.fcb = true
+	return
+}
+
+/*
+request user data class 2 by REQ_UD2 and decode variable data structure
+
+# Returns
+
+data VariableData: decoded header and records
+
+err error: error
+*/
+func (c *Client) ReadData() (data VariableData, err error) {
+	err = c.Reset()
+	if err != nil {
+		return
+	}
+	ctl := C_REQ_UD2
+	if c.fcb {
+		ctl |= C_FLAG_FCB
+	}
+	var resp Frame
+	resp, err = c.transact(Frame{C: ctl, A: c.primary}, true)
+	if err != nil {
+		return
+	}
+	c.fcb = !c.fcb
+	if !resp.Long || resp.C&0x4F != C_RSP_UD {
+		err = fmt.Errorf("mbus unexpected response, control 0x%02x", resp.C)
+		return
+	}
+	data, err = DecodeVariableData(resp.CI, resp.Data)
+	return
+}
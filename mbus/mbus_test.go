@@ -0,0 +1,57 @@
+package mbus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	cases := []struct {
+		name  string
+		frame Frame
+		buf   []byte
+	}{
+		{name: "ack", frame: Frame{Ack: true}, buf: []byte{0xE5}},
+		{name: "short", frame: Frame{C: C_REQ_UD2, A: 0x05}, buf: []byte{0x10, 0x5B, 0x05, 0x60, 0x16}},
+		{
+			name:  "long",
+			frame: Frame{Long: true, C: C_SND_UD, A: ADDR_NETWORK_LAYER, CI: CI_SELECT, Data: []byte{0x78, 0x56, 0x34, 0x12, 0xFF, 0xFF, 0xFF, 0xFF}},
+			buf:   []byte{0x68, 0x0B, 0x0B, 0x68, 0x53, 0xFD, 0x52, 0x78, 0x56, 0x34, 0x12, 0xFF, 0xFF, 0xFF, 0xFF, 0xB2, 0x16},
+		},
+	}
+	for _, c := range cases {
+		if buf := Encode(c.frame); !bytes.Equal(buf, c.buf) {
+			t.Errorf("%s: encoded % x, want % x", c.name, buf, c.buf)
+		}
+		f, err := Decode(c.buf)
+		if err != nil || f.Ack != c.frame.Ack || f.Long != c.frame.Long || f.C != c.frame.C || f.A != c.frame.A || f.CI != c.frame.CI || !bytes.Equal(f.Data, c.frame.Data) {
+			t.Errorf("%s: decoded %+v, err %v, want %+v", c.name, f, err, c.frame)
+		}
+		// noise before the frame is skipped
+		buf, err := ReadFrame(bytes.NewReader(append([]byte{0x00, 0xFF}, c.buf...)))
+		if err != nil || !bytes.Equal(buf, c.buf) {
+			t.Errorf("%s: read % x, err %v, want % x", c.name, buf, err, c.buf)
+		}
+	}
+}
+
+func TestDecodeBadFrame(t *testing.T) {
+	cases := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"bad start", []byte{0x11, 0x5B, 0x05, 0x60, 0x16}},
+		{"short frame checksum", []byte{0x10, 0x5B, 0x05, 0x61, 0x16}},
+		{"short frame tail", []byte{0x10, 0x5B, 0x05, 0x60, 0x17}},
+		{"long frame lengths differ", []byte{0x68, 0x03, 0x04, 0x68, 0x08, 0x05, 0x72, 0x7F, 0x16}},
+		{"long frame length below 3", []byte{0x68, 0x02, 0x02, 0x68, 0x08, 0x05, 0x0D, 0x16, 0x00}},
+		{"long frame truncated", []byte{0x68, 0x04, 0x04, 0x68, 0x08, 0x05, 0x72, 0x7F, 0x16}},
+		{"long frame checksum", []byte{0x68, 0x03, 0x03, 0x68, 0x08, 0x05, 0x72, 0x7E, 0x16}},
+	}
+	for _, c := range cases {
+		if f, err := Decode(c.buf); err == nil {
+			t.Errorf("%s: decoded %+v, want error", c.name, f)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * @filename	actuation.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Completion polling of actuator commands, such as motorised valves and power switches
+ * @comment		motorised valves take 10 - 30 s to travel, so status is polled until the end rather than read once
+ */
+
+package meter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// phases of actuation, judged from each status poll
+const (
+	// target state reached
+	ACTUATION_DONE uint8 = iota
+	// actuator travelling towards target state
+	ACTUATION_MOVING
+	// actuator still at the state it was commanded away from
+	ACTUATION_STALLED
+	// actuator reports fault, waiting stops at once
+	ACTUATION_FAULT
+	// status could not be read, such as a meter busy driving its motor
+	ACTUATION_UNKNOWN
+
+	// (reserved) actuation phase amount counter, must be at the end
+	ACTUATION_PHASE_AMOUNT__
+)
+
+var actuationPhaseNames = [ACTUATION_PHASE_AMOUNT__]string{
+	ACTUATION_DONE:    "done",
+	ACTUATION_MOVING:  "moving",
+	ACTUATION_STALLED: "stalled",
+	ACTUATION_FAULT:   "fault",
+	ACTUATION_UNKNOWN: "unknown",
+}
+
+// errors of actuation which was commanded but not completed, distinct from errors sending the command
+var (
+	// actuator did not leave its state before timeout
+	ErrActuationStalled = errors.New("actuator stalled")
+	// actuator was still travelling at timeout
+	ErrActuationTimeout = errors.New("actuator did not complete in time")
+	// actuator reports fault
+	ErrActuationFault = errors.New("actuator fault")
+	// waiting was stopped by the progress callback
+	ErrActuationStopped = errors.New("actuation waiting stopped")
+)
+
+// how actuator commands wait for completion
+type ActuationPolicy struct {
+	// time from command to give up waiting for target state
+	Timeout time.Duration
+	// time between status polls, the first poll is one interval after command
+	Interval time.Duration
+}
+
+// report of one actuator command
+type Actuation struct {
+	// target state, true to restore supply (close power switch or open valve)
+	Target bool
+	// phase of the last poll, using macro ACTUATION_*
+	Phase uint8
+	// state of the last successful poll in terms of the meter kind, such as watermeter.VALVE_STAT_*,
+	// or 1 for on and 0 for off for actuators with two states
+	State uint8
+	// time from command to the last poll
+	Elapsed time.Duration
+	// number of status polls
+	Polls int
+}
+
+/*
+format actuation phase into its name, such as "moving"
+
+# Params
+
+phase uint8: actuation phase, using macro ACTUATION_*
+
+# Returns
+
+name string: phase name, or "phase#<phase>" if phase is undefined
+*/
+func ActuationPhaseName(phase uint8) (name string) {
+	if phase >= ACTUATION_PHASE_AMOUNT__ {
+		name = fmt.Sprintf("phase#%d", phase)
+		return
+	}
+	name = actuationPhaseNames[phase]
+	return
+}
+
+/*
+poll actuator status after a command until it reaches the target, reports fault, or policy timeout
+
+read errors are tolerated until timeout, as some meters do not answer while driving their motor
+
+# Params
+
+target bool: target state, true to restore supply
+
+policy ActuationPolicy: timeout and poll interval
+
+poll func() (phase uint8, state uint8, err error): reads status once and judges its phase against target
+
+progress func(a Actuation) (stop bool): called after each poll, return true to stop waiting, could be nil
+
+# Returns
+
+a Actuation: report of the last poll
+
+err error: nil if done, ErrActuationStalled, ErrActuationTimeout, ErrActuationFault, ErrActuationStopped,
+or the last read error if status could never be read
+*/
+func WaitActuation(target bool, policy ActuationPolicy, poll func() (phase uint8, state uint8, err error), progress func(a Actuation) (stop bool)) (a Actuation, err error) {
+	if policy.Interval <= 0 {
+		err = errors.New("non-positive actuation poll interval")
+		return
+	}
+	a = Actuation{Target: target, Phase: ACTUATION_UNKNOWN}
+	start := time.Now()
+	var readErr error
+	// phase of the last successful poll
+	readPhase := ACTUATION_UNKNOWN
+	for {
+		time.Sleep(policy.Interval)
+		phase, state, e := poll()
+		a.Elapsed = time.Since(start)
+		a.Polls++
+		if e != nil {
+			readErr = e
+			a.Phase = ACTUATION_UNKNOWN
+		} else {
+			a.Phase, a.State = phase, state
+			readPhase = phase
+		}
+		if a.Phase == ACTUATION_DONE {
+			return
+		}
+		if a.Phase == ACTUATION_FAULT {
+			err = ErrActuationFault
+			return
+		}
+		if progress != nil && progress(a) {
+			err = ErrActuationStopped
+			return
+		}
+		if a.Elapsed+policy.Interval > policy.Timeout {
+			break
+		}
+	}
+	switch {
+	case readPhase == ACTUATION_UNKNOWN:
+		err = fmt.Errorf("failed to read actuator status: %w", readErr)
+	case readPhase == ACTUATION_STALLED:
+		err = ErrActuationStalled
+	default:
+		err = ErrActuationTimeout
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package meter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitActuation(t *testing.T) {
+	errRead := errors.New("no response")
+	policy := ActuationPolicy{Timeout: 20 * time.Millisecond, Interval: time.Millisecond}
+	tests := []struct {
+		name string
+		// phases returned by successive polls, the last one repeats, ACTUATION_UNKNOWN for a read error
+		phases []uint8
+		stop   func(a Actuation) bool
+		phase  uint8
+		err    error
+	}{
+		{name: "done at once", phases: []uint8{ACTUATION_DONE}, phase: ACTUATION_DONE},
+		{name: "moving then done", phases: []uint8{ACTUATION_STALLED, ACTUATION_MOVING, ACTUATION_MOVING, ACTUATION_DONE}, phase: ACTUATION_DONE},
+		{name: "busy then done", phases: []uint8{ACTUATION_UNKNOWN, ACTUATION_UNKNOWN, ACTUATION_DONE}, phase: ACTUATION_DONE},
+		{name: "fault", phases: []uint8{ACTUATION_MOVING, ACTUATION_FAULT}, phase: ACTUATION_FAULT, err: ErrActuationFault},
+		{name: "stalled", phases: []uint8{ACTUATION_STALLED}, phase: ACTUATION_STALLED, err: ErrActuationStalled},
+		{name: "still moving", phases: []uint8{ACTUATION_MOVING}, phase: ACTUATION_MOVING, err: ErrActuationTimeout},
+		{name: "stalled then unreadable", phases: []uint8{ACTUATION_STALLED, ACTUATION_UNKNOWN}, phase: ACTUATION_UNKNOWN, err: ErrActuationStalled},
+		{name: "never read", phases: []uint8{ACTUATION_UNKNOWN}, phase: ACTUATION_UNKNOWN, err: errRead},
+		{name: "stopped", phases: []uint8{ACTUATION_MOVING}, stop: func(a Actuation) bool { return a.Polls == 2 }, phase: ACTUATION_MOVING, err: ErrActuationStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polls := 0
+			poll := func() (phase uint8, state uint8, err error) {
+				phase = tt.phases[min(polls, len(tt.phases)-1)]
+				polls++
+				if phase == ACTUATION_UNKNOWN {
+					err = errRead
+				}
+				return
+			}
+			a, err := WaitActuation(true, policy, poll, tt.stop)
+			if !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+				t.Fatalf("err %v, want %v", err, tt.err)
+			}
+			if a.Phase != tt.phase || a.Polls != polls || !a.Target {
+				t.Fatalf("actuation %+v after %d polls, want phase %s", a, polls, ActuationPhaseName(tt.phase))
+			}
+		})
+	}
+}
+
+func TestWaitActuationInterval(t *testing.T) {
+	_, err := WaitActuation(true, ActuationPolicy{Timeout: time.Second}, func() (uint8, uint8, error) {
+		t.Fatal("polled without interval")
+		return ACTUATION_DONE, 0, nil
+	}, nil)
+	if err == nil {
+		t.Fatal("no error without interval")
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * @filename	addr.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Validation of Modbus-RTU slave address
+ * @comment		--
+ */
+
+package meter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Modbus-RTU slave address limits, 248 - 255 are reserved by the protocol
+const (
+	MODBUS_ADDR_BROADCAST uint8 = 0
+	MODBUS_ADDR_MIN       uint8 = 1
+	MODBUS_ADDR_MAX       uint8 = 247
+)
+
+// slave address 0 is given to a meter instance, which is only valid for broadcast writes
+var ErrBroadcastAddr = errors.New("slave address 0 is reserved for broadcast and can not address a single meter")
+
+// slave address is out of the range of protocol or meter model
+var ErrSlaveAddrRange = errors.New("slave address out of range")
+
+/*
+check if a Modbus-RTU slave address can address a single meter
+
+# Params
+
+slaveAddr uint8: slave address to check
+
+maxAddr uint8: highest address supported by meter model, 0 for protocol limit of 247
+
+# Returns
+
+err error: ErrBroadcastAddr or ErrSlaveAddrRange wrapped with the valid range, nil if valid
+*/
+func ValidateSlaveAddr(slaveAddr uint8, maxAddr uint8) (err error) {
+	if slaveAddr == MODBUS_ADDR_BROADCAST {
+		err = ErrBroadcastAddr
+		return
+	}
+	err = checkSlaveAddrRange(int(slaveAddr), maxAddr)
+	return
+}
+
+/*
+parse a Modbus-RTU slave address in decimal and check it
+
+# Params
+
+s string: slave address in decimal, such as "2"
+
+maxAddr uint8: highest address supported by meter model, 0 for protocol limit of 247
+
+# Returns
+
+slaveAddr uint8: slave address
+
+err error: error if not a number or not valid
+*/
+func ParseSlaveAddr(s string, maxAddr uint8) (slaveAddr uint8, err error) {
+	var n int
+	n, err = strconv.Atoi(s)
+	if err != nil {
+		err = fmt.Errorf("invalid slave address %q, decimal number expected", s)
+		return
+	}
+	if n == int(MODBUS_ADDR_BROADCAST) {
+		err = ErrBroadcastAddr
+		return
+	}
+	err = checkSlaveAddrRange(n, maxAddr)
+	if err != nil {
+		return
+	}
+	slaveAddr = uint8(n)
+	return
+}
+
+// check slave address against protocol and model limit
+func checkSlaveAddrRange(n int, maxAddr uint8) (err error) {
+	if maxAddr == 0 || maxAddr > MODBUS_ADDR_MAX {
+		maxAddr = MODBUS_ADDR_MAX
+	}
+	if n < int(MODBUS_ADDR_MIN) || n > int(maxAddr) {
+		err = fmt.Errorf("%w: %d, valid range is %d - %d", ErrSlaveAddrRange, n, MODBUS_ADDR_MIN, maxAddr)
+	}
+	return
+}
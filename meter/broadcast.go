@@ -0,0 +1,149 @@
+/*
+ * @filename	broadcast.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Broadcast commands sent once to all meters on a gateway and verified meter by meter
+ * @comment		--
+ */
+
+package meter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// broadcast command identifiers
+const (
+	// set clock to the given time
+	BROADCAST_CMD_TIME_SYNC uint8 = iota
+	// freeze energy and volume registers, such as for a billing cut-off
+	BROADCAST_CMD_FREEZE
+
+	// (reserved) command amount counter, must be at the end
+	BROADCAST_CMD_AMOUNT__
+)
+
+var broadcastCmdNames = [BROADCAST_CMD_AMOUNT__]string{
+	BROADCAST_CMD_TIME_SYNC: "time_sync",
+	BROADCAST_CMD_FREEZE:    "freeze",
+}
+
+// largest difference between meter clock and expected time accepted by verification of time sync
+const BROADCAST_CLOCK_TOLERANCE = 10 * time.Second
+
+// command is not broadcast-safe for the meter model, or for the mix of models on the gateway
+var ErrBroadcastUnsafe = errors.New("command is not broadcast-safe")
+
+// get name of broadcast command, such as "time_sync"
+func BroadcastCmdName(cmd uint8) string {
+	if cmd >= BROADCAST_CMD_AMOUNT__ {
+		return fmt.Sprintf("cmd#%d", cmd)
+	}
+	return broadcastCmdNames[cmd]
+}
+
+// meter which supports broadcast commands
+type Broadcaster interface {
+	Meter
+	// get broadcast-safe commands of the meter model, using macro BROADCAST_CMD_*
+	GetBroadcastCmds() (cmds []uint8)
+	// get key of the broadcast frame, meters with the same key on one gateway are reached by one broadcast
+	GetBroadcastKey(cmd uint8) (key string, err error)
+	// send command to all meters on the gateway of the instance, no response is expected
+	SendBroadcast(cmd uint8, t time.Time) (err error)
+	// read back the instance to check if the command took effect, t is the expected meter time for time sync
+	VerifyBroadcast(cmd uint8, t time.Time) (err error)
+}
+
+// result of broadcast command of one meter
+type BroadcastResult struct {
+	// identity of the meter
+	Identity Identity
+	// error of sending or verification, nil if the command took effect
+	Err error
+}
+
+/*
+send a command to meters by broadcast, once per gateway and frame, then read back each meter to verify
+
+Modbus-RTU broadcasts reach every slave on the bus, so they are only sent if all Modbus-RTU meters given on that gateway
+share the same frame, otherwise those meters fail with ErrBroadcastUnsafe and nothing is sent to them
+
+# Params
+
+cmd uint8: command, using macro BROADCAST_CMD_*
+
+t time.Time: time carried by the command, normally time.Now(), DL/T 645 meters freeze at once whatever it is
+
+meters []Broadcaster: initialized meter instances, including all Modbus-RTU meters on their gateways
+
+# Returns
+
+results []BroadcastResult: result of each meter in the order given
+*/
+func Broadcast(cmd uint8, t time.Time, meters []Broadcaster) (results []BroadcastResult) {
+	type group struct {
+		netAddr string
+		key     string
+	}
+	results = make([]BroadcastResult, len(meters))
+	keys := make([]string, len(meters))
+	// distinct Modbus-RTU frames per gateway, "" stands for a meter which can not take the command
+	modbusKeys := map[string]map[string]bool{}
+	for i, m := range meters {
+		results[i].Identity = m.GetIdentity()
+		keys[i], results[i].Err = m.GetBroadcastKey(cmd)
+		if results[i].Identity.Protocol != PROTOCOL_MODBUS_RTU {
+			continue
+		}
+		netAddr := results[i].Identity.NetAddr
+		if modbusKeys[netAddr] == nil {
+			modbusKeys[netAddr] = map[string]bool{}
+		}
+		if results[i].Err != nil {
+			modbusKeys[netAddr][""] = true
+		} else {
+			modbusKeys[netAddr][keys[i]] = true
+		}
+	}
+	for i := range meters {
+		ident := &results[i].Identity
+		if results[i].Err != nil || ident.Protocol != PROTOCOL_MODBUS_RTU || len(modbusKeys[ident.NetAddr]) == 1 {
+			continue
+		}
+		results[i].Err = fmt.Errorf("%w: %s on %s, meter models on the bus disagree", ErrBroadcastUnsafe, BroadcastCmdName(cmd), ident.NetAddr)
+	}
+	sent := map[group]time.Time{}
+	sendErr := map[group]error{}
+	for i, m := range meters {
+		if results[i].Err != nil {
+			continue
+		}
+		g := group{results[i].Identity.NetAddr, keys[i]}
+		if _, ok := sent[g]; ok {
+			continue
+		}
+		sent[g] = time.Now()
+		sendErr[g] = m.SendBroadcast(cmd, t)
+	}
+	// verification pass after all broadcasts, giving meters the most time to apply
+	for i, m := range meters {
+		if results[i].Err != nil {
+			continue
+		}
+		g := group{results[i].Identity.NetAddr, keys[i]}
+		if sendErr[g] != nil {
+			results[i].Err = sendErr[g]
+			continue
+		}
+		expected := t
+		if cmd == BROADCAST_CMD_TIME_SYNC {
+			// meter clock has run on since broadcast
+			expected = t.Add(time.Since(sent[g]))
+		}
+		results[i].Err = m.VerifyBroadcast(cmd, expected)
+	}
+	return
+}
@@ -0,0 +1,194 @@
+/*
+ * @filename	item.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Description of data items shared by all meter kinds
+ * @comment		--
+ */
+
+package meter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// quantity kind identifiers of data items
+const (
+	KIND_UNKNOWN uint8 = iota
+	// electric voltage
+	KIND_VOLTAGE
+	// electric current
+	KIND_CURRENT
+	// active, reactive or apparent power
+	KIND_POWER
+	// ratio without dimension, such as power factor
+	KIND_RATIO
+	// frequency
+	KIND_FREQUENCY
+	// active or reactive energy
+	KIND_ENERGY
+	// volume of fluid
+	KIND_VOLUME
+	// communication address
+	KIND_ADDRESS
+	// date and time
+	KIND_DATETIME
+	// temperature or temperature difference
+	KIND_TEMPERATURE
+	// flow rate of fluid
+	KIND_FLOW
+	// elapsed time
+	KIND_DURATION
+	// pressure
+	KIND_PRESSURE
+	// status flag or bit field
+	KIND_STATUS
+
+	// (reserved) kind amount counter, must be at the end
+	KIND_AMOUNT__
+)
+
+var kindNames = [KIND_AMOUNT__]string{
+	KIND_UNKNOWN:     "unknown",
+	KIND_VOLTAGE:     "voltage",
+	KIND_CURRENT:     "current",
+	KIND_POWER:       "power",
+	KIND_RATIO:       "ratio",
+	KIND_FREQUENCY:   "frequency",
+	KIND_ENERGY:      "energy",
+	KIND_VOLUME:      "volume",
+	KIND_ADDRESS:     "address",
+	KIND_DATETIME:    "datetime",
+	KIND_TEMPERATURE: "temperature",
+	KIND_FLOW:        "flow",
+	KIND_DURATION:    "duration",
+	KIND_PRESSURE:    "pressure",
+	KIND_STATUS:      "status",
+}
+
+// description of data item
+type ItemInfo struct {
+	// stable item name, lower case of the ID_* macro without prefix, such as "voltage"
+	Name string
+	// unit of value returned by GetVal, empty if dimensionless
+	Unit string
+	// quantity kind, using macro KIND_*
+	Kind uint8
+	// human readable description
+	Desc string
+}
+
+// item table of one meter kind ordered by item ID
+type ItemTable []ItemInfo
+
+/*
+get name of quantity kind
+
+# Params
+
+kind uint8: quantity kind, using macro KIND_*
+
+# Returns
+
+name string: kind name such as "voltage", "unknown" if kind is out of range
+*/
+func KindName(kind uint8) (name string) {
+	if kind >= KIND_AMOUNT__ {
+		kind = KIND_UNKNOWN
+	}
+	name = kindNames[kind]
+	return
+}
+
+/*
+get description of data item
+
+# Params
+
+id uint8: item id
+
+# Returns
+
+info ItemInfo: item description
+
+err error: error if item id is undefined
+*/
+func (tab ItemTable) Info(id uint8) (info ItemInfo, err error) {
+	if int(id) >= len(tab) || tab[id].Name == "" {
+		err = fmt.Errorf("undefined data item %d", id)
+		return
+	}
+	info = tab[id]
+	return
+}
+
+/*
+format item id into its name, used in logs, exporters and errors
+
+# Params
+
+id uint8: item id
+
+# Returns
+
+name string: item name, or "item#<id>" if item id is undefined
+*/
+func (tab ItemTable) Name(id uint8) (name string) {
+	if int(id) >= len(tab) || tab[id].Name == "" {
+		name = fmt.Sprintf("item#%d", id)
+		return
+	}
+	name = tab[id].Name
+	return
+}
+
+/*
+parse item name into item id, case-insensitive
+
+# Params
+
+name string: item name, such as "energy_active_posi_curr_all"
+
+# Returns
+
+id uint8: item id
+
+err error: error if name matches no item
+*/
+func (tab ItemTable) Parse(name string) (id uint8, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i := range tab {
+		if tab[i].Name != "" && tab[i].Name == name {
+			id = uint8(i)
+			return
+		}
+	}
+	err = fmt.Errorf("unknown data item name %q", name)
+	return
+}
+
+// if items of the quantity kind are cumulative counters, such as energy and volume
+func IsCumulative(kind uint8) bool {
+	return kind == KIND_ENERGY || kind == KIND_VOLUME
+}
+
+/*
+get rollover of an item, for ItemCapability
+
+# Params
+
+kind uint8: quantity kind of item, using macro KIND_*
+
+span float64: value at which the register or data wraps back to 0
+
+# Returns
+
+rollover float64: span if the item is cumulative, otherwise 0
+*/
+func Rollover(kind uint8, span float64) (rollover float64) {
+	if IsCumulative(kind) {
+		rollover = span
+	}
+	return
+}
@@ -0,0 +1,106 @@
+/*
+ * @filename	meter.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Common interface implemented by all meter kinds
+ * @comment		--
+ */
+
+package meter
+
+import "github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+
+// meter kind identifiers
+const (
+	METER_KIND_POWER uint8 = iota
+	METER_KIND_WATER
+	METER_KIND_HEAT
+	METER_KIND_GAS
+)
+
+// communication protocol identifiers
+const (
+	PROTOCOL_MODBUS_RTU uint8 = iota
+	PROTOCOL_DLT645
+	PROTOCOL_CJT188
+	PROTOCOL_MBUS
+)
+
+// identity of meter instance
+type Identity struct {
+	// meter kind, using macro METER_KIND_*
+	Kind uint8
+	// meter model id, using macro METER_MODEL_* of the meter kind package
+	Model uint8
+	// human readable model name
+	ModelName string
+	// address of the gateway which the meter is connected to, such as rtuovertcp://192.168.1.12:8802
+	NetAddr string
+	// communication protocol, using macro PROTOCOL_*
+	Protocol uint8
+	// slave address of the meter on the bus, Modbus-RTU only
+	SlaveAddr uint8
+	// address of the meter on the bus in its protocol format, such as "2" or "000012345678"
+	Addr string
+}
+
+// capability of one data item supported by meter model
+type ItemCapability struct {
+	// item identifier, using macro ID_* of the meter kind package
+	ID uint8
+	// stable item name, such as "voltage"
+	Name string
+	// unit of value returned by GetVal, empty if dimensionless
+	Unit string
+	// quantity kind, using macro KIND_*
+	Kind uint8
+	// a value multiplied onto the original value from the register
+	Scale float64
+	// if the value can be less than 0
+	Signed bool
+	// if the item can be read
+	Readable bool
+	// if the item can be written
+	Writable bool
+	// value at which a cumulative item such as energy or volume wraps back to 0, 0 if unknown or not cumulative
+	Rollover float64
+}
+
+// capabilities of meter instance, describing what the driver can do with it
+type Capabilities struct {
+	// meter kind, using macro METER_KIND_*
+	Kind uint8
+	// meter model id, using macro METER_MODEL_* of the meter kind package
+	Model uint8
+	// human readable model name
+	ModelName string
+	// communication protocol, using macro PROTOCOL_*
+	Protocol uint8
+	// supported data items ordered by item ID
+	Items []ItemCapability
+	// number of actuators (power switches or valves), give turn ranged 0 - ActuatorAmount-1
+	ActuatorAmount int
+	// if meter keeps a real-time clock which can be read
+	Clock bool
+	// if the real-time clock can be set
+	ClockWritable bool
+	// number of time-of-use tariff rates, 0 if meter has no tariff support
+	TariffAmount int
+}
+
+// common interface of meters, so that pollers, exporters and APIs can be written once for all meter kinds
+type Meter interface {
+	Init(gw *gateway.MBRTGateway, meterModel uint8, slaveAddr uint8) (err error)
+	// identity of meter instance
+	GetIdentity() (ident Identity)
+	// read data item, using macro ID_* of the meter kind package
+	GetVal(id uint8) (ret float64, err error)
+	// description of data item, using macro ID_* of the meter kind package
+	GetItemInfo(id uint8) (info ItemInfo, err error)
+	// actuator status, on is true when supply is available (power switch closed or valve opened)
+	GetActuator(turn uint8) (on bool, err error)
+	// actuator command, on is true to restore supply (close power switch or open valve)
+	SetActuator(turn uint8, on bool) (err error)
+	// capabilities of meter instance
+	GetCapabilities() (caps Capabilities)
+}
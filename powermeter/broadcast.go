@@ -0,0 +1,145 @@
+/*
+ * @filename	broadcast.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Broadcast commands of power meters, such as clock synchronization and freezing for billing cut-off
+ * @comment		used by meter.Broadcast, which sends once per gateway and verifies meter by meter
+ */
+
+package powermeter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/dlt645"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// freeze time of DL/T 645 meters is kept to the minute, and the meter may freeze a little after broadcast
+const freezeTolerance = 2 * time.Minute
+
+/*
+get broadcast-safe commands of the meter model
+
+# Returns
+
+cmds []uint8: commands, using macro meter.BROADCAST_CMD_*
+*/
+func (pm *PowerMeter) GetBroadcastCmds() (cmds []uint8) {
+	if pm.modelMeta == nil {
+		return
+	}
+	for _, bm := range pm.modelMeta.broadcastMeta {
+		cmds = append(cmds, bm.cmd)
+	}
+	return
+}
+
+/*
+get key of the broadcast frame of a command, meters with the same key on one gateway are reached by one broadcast
+
+# Params
+
+cmd uint8: command, using macro meter.BROADCAST_CMD_*
+
+# Returns
+
+key string: key of the broadcast frame
+
+err error: meter.ErrBroadcastUnsafe if the model does not support the command by broadcast
+*/
+func (pm *PowerMeter) GetBroadcastKey(cmd uint8) (key string, err error) {
+	_, err = pm.broadcastMeta(cmd)
+	if err != nil {
+		return
+	}
+	// frame of protocol, understood by all DL/T 645 meters
+	key = fmt.Sprintf("dlt645/%s", meter.BroadcastCmdName(cmd))
+	return
+}
+
+/*
+send a command to all meters on the gateway of the instance by broadcast, no response is expected
+
+use meter.Broadcast to reach several meters and verify them
+
+# Params
+
+cmd uint8: command, using macro meter.BROADCAST_CMD_*
+
+t time.Time: time carried by the command
+
+# Returns
+
+err error: error if the frame could not be sent
+*/
+func (pm *PowerMeter) SendBroadcast(cmd uint8, t time.Time) (err error) {
+	_, err = pm.broadcastMeta(cmd)
+	if err != nil {
+		return
+	}
+	switch cmd {
+	case meter.BROADCAST_CMD_TIME_SYNC:
+		err = dlt645.BroadcastTime(pm.gateway, t)
+	case meter.BROADCAST_CMD_FREEZE:
+		err = dlt645.BroadcastFreeze(pm.gateway)
+	}
+	return
+}
+
+/*
+read back the meter to check if a broadcast command took effect
+
+# Params
+
+cmd uint8: command, using macro meter.BROADCAST_CMD_*
+
+t time.Time: expected meter time for time sync, or time of freeze
+
+# Returns
+
+err error: error if the meter could not be read or the command did not take effect
+*/
+func (pm *PowerMeter) VerifyBroadcast(cmd uint8, t time.Time) (err error) {
+	_, err = pm.broadcastMeta(cmd)
+	if err != nil {
+		return
+	}
+	var got time.Time
+	switch cmd {
+	case meter.BROADCAST_CMD_TIME_SYNC:
+		got, err = pm.dlt.ReadDateTime()
+		if err != nil {
+			return
+		}
+		if d := got.Sub(t); d > meter.BROADCAST_CLOCK_TOLERANCE || d < -meter.BROADCAST_CLOCK_TOLERANCE {
+			err = fmt.Errorf("meter clock %s is %s off after time sync", got.Format(time.DateTime), d.Round(time.Second))
+		}
+	case meter.BROADCAST_CMD_FREEZE:
+		got, err = pm.dlt.ReadFreezeTime()
+		if err != nil {
+			return
+		}
+		if d := got.Sub(t); d > freezeTolerance || d < -freezeTolerance {
+			err = fmt.Errorf("last freeze at %s, not frozen by broadcast", got.Format(time.DateTime))
+		}
+	}
+	return
+}
+
+// get broadcast metadata of command
+func (pm *PowerMeter) broadcastMeta(cmd uint8) (bm *BroadcastMeta, err error) {
+	if pm.modelMeta == nil {
+		err = fmt.Errorf("%w: meter not initialized", meter.ErrBroadcastUnsafe)
+		return
+	}
+	for i := range pm.modelMeta.broadcastMeta {
+		if pm.modelMeta.broadcastMeta[i].cmd == cmd {
+			bm = &pm.modelMeta.broadcastMeta[i]
+			return
+		}
+	}
+	err = fmt.Errorf("%w: %s by %s", meter.ErrBroadcastUnsafe, meter.BroadcastCmdName(cmd), pm.modelMeta.name)
+	return
+}
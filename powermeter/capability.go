@@ -0,0 +1,107 @@
+/*
+ * @filename	capability.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Capability discovery of power meter instance
+ * @comment		--
+ */
+
+package powermeter
+
+import (
+	"math"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+/*
+get capabilities of the meter instance, the instance needs to be initialized in advance
+
+# Returns
+
+caps meter.Capabilities: supported data items, power switches and clock/tariff features
+*/
+func (pm *PowerMeter) GetCapabilities() (caps meter.Capabilities) {
+	caps.Kind = meter.METER_KIND_POWER
+	if pm.modelMeta == nil {
+		return
+	}
+	caps.Model = pm.model
+	caps.ModelName = pm.modelMeta.name
+	caps.Protocol = pm.modelMeta.protocol
+	if pm.isDLT645() {
+		for id := 0; id < len(pm.modelMeta.diMeta) && id < int(ID_DATA_ITEM_AMOUNT__); id++ {
+			dm := &pm.modelMeta.diMeta[id]
+			if dm.size == 0 {
+				continue
+			}
+			caps.Items = append(caps.Items, meter.ItemCapability{
+				ID:       uint8(id),
+				Name:     itemTable[id].Name,
+				Unit:     itemTable[id].Unit,
+				Kind:     itemTable[id].Kind,
+				Scale:    regio.Scale(dm.override) / math.Pow10(int(dm.decimals)),
+				Signed:   dm.hasSymbol,
+				Readable: dm.readable,
+				Writable: dm.writable,
+				Rollover: rolloverDLT645(itemTable[id].Kind, dm),
+			})
+		}
+		// DL/T 645 meters have one relay controlled by remote control command
+		caps.ActuatorAmount = 1
+		// date and time are read by their data identifiers, and set by broadcast time sync needing no password
+		caps.Clock = true
+		_, err := pm.broadcastMeta(meter.BROADCAST_CMD_TIME_SYNC)
+		caps.ClockWritable = err == nil
+	}
+	for id := 0; id < len(pm.regMeta) && id < int(ID_DATA_ITEM_AMOUNT__); id++ {
+		if pm.regMeta[id].length == 0 {
+			continue
+		}
+		caps.Items = append(caps.Items, meter.ItemCapability{
+			ID:       uint8(id),
+			Name:     itemTable[id].Name,
+			Unit:     itemTable[id].Unit,
+			Kind:     itemTable[id].Kind,
+			Scale:    regio.Scale(pm.regMeta[id].override),
+			Signed:   pm.regMeta[id].hasSymbol,
+			Readable: pm.regMeta[id].readable,
+			Writable: pm.regMeta[id].writable,
+			Rollover: meter.Rollover(itemTable[id].Kind, regio.Span(pm.regMeta[id].reg())),
+		})
+	}
+	if len(pm.SwitchMeta) > 0 {
+		caps.ActuatorAmount = len(pm.SwitchMeta)
+	}
+	if int(ID_DATETIME) < len(pm.regMeta) && pm.regMeta[ID_DATETIME].length != 0 {
+		caps.Clock = pm.regMeta[ID_DATETIME].readable
+		caps.ClockWritable = pm.regMeta[ID_DATETIME].writable
+	}
+	caps.TariffAmount = pm.modelMeta.tariffAmount
+	return
+}
+
+/*
+get fingerprints of the Modbus-RTU power meter models, used to identify meters by gateway.Scan
+
+# Returns
+
+fps []gateway.Fingerprint: fingerprints of all Modbus-RTU models
+*/
+func Fingerprints() (fps []gateway.Fingerprint) {
+	fps = append(fps, modelFingerprints(METER_MODEL_DDS4921, &modelMetaDDS4921)...)
+	return
+}
+
+// build fingerprints from plausible item ranges of a meter model
+func modelFingerprints(model uint8, modelMeta *ModelMeta) (fps []gateway.Fingerprint) {
+	for _, fm := range modelMeta.fingerprints {
+		if int(fm.id) >= len(modelMeta.regMeta) || modelMeta.regMeta[fm.id].length == 0 {
+			continue
+		}
+		fps = append(fps, regio.Fingerprint(meter.METER_KIND_POWER, model, modelMeta.name, modelMeta.regMeta[fm.id].reg(), fm.min, fm.max))
+	}
+	return
+}
@@ -0,0 +1,48 @@
+package powermeter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/consumption"
+)
+
+// energy of DDS4921 is a sign-magnitude register, it must still wrap as a counter
+func TestRolloverDDS4921Energy(t *testing.T) {
+	pm := PowerMeter{model: METER_MODEL_DDS4921, modelMeta: &modelMetaDDS4921, regMeta: modelMetaDDS4921.regMeta}
+	cfg, err := consumption.ConfigOf(pm.GetCapabilities(), ID_ENERGY_ACTIVE_CURR_ALL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := math.Ldexp(0.01, 31)
+	if math.Abs(cfg.Rollover-span) > 1e-6 {
+		t.Fatalf("rollover %g, want %g", cfg.Rollover, span)
+	}
+	var c consumption.Counter
+	if err = c.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	if _, _, err = c.Add(consumption.Reading{Time: t0, Value: span - 1}); err != nil {
+		t.Fatal(err)
+	}
+	u, ok, err := c.Add(consumption.Reading{Time: t0.Add(time.Hour), Value: 0.5})
+	if err != nil || !ok {
+		t.Fatalf("add: ok %v, err %v", ok, err)
+	}
+	if !u.Rollover || u.Implausible || math.Abs(u.Value-1.5) > 1e-6 {
+		t.Fatalf("usage %+v, want 1.5 with rollover", u)
+	}
+}
+
+func TestClockDLT645(t *testing.T) {
+	pm := PowerMeter{model: METER_MODEL_DLT645, modelMeta: &modelMetaDLT645}
+	if caps := pm.GetCapabilities(); !caps.Clock || !caps.ClockWritable {
+		t.Fatalf("clock %v writable %v, want clock set by broadcast", caps.Clock, caps.ClockWritable)
+	}
+	pm = PowerMeter{model: METER_MODEL_DDS4921, modelMeta: &modelMetaDDS4921, regMeta: modelMetaDDS4921.regMeta}
+	if caps := pm.GetCapabilities(); caps.Clock || caps.ClockWritable {
+		t.Fatalf("clock %v writable %v, want no clock", caps.Clock, caps.ClockWritable)
+	}
+}
@@ -1,5 +1,7 @@
 package powermeter
 
+import "github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+
 // (23/07/2024 kontornl) may use const here, need inspection
 // register metadata of DDS4921 oredered by item ID, such as ID_VOLTAGE
 var regMetaDDS4921 = []RegMeta{
@@ -184,3 +186,14 @@ var switchMetaDDS4921 = []SwitchMeta{
 		statusCloseVal: 0x0055,
 	},
 }
+
+var modelMetaDDS4921 = ModelMeta{
+	name:       "DDS4921",
+	protocol:   meter.PROTOCOL_MODBUS_RTU,
+	regMeta:    regMetaDDS4921,
+	switchMeta: switchMetaDDS4921,
+	fingerprints: []fingerprintMeta{
+		{id: ID_VOLTAGE, min: 50, max: 300},
+		{id: ID_FREQ, min: 45, max: 65},
+	},
+}
@@ -0,0 +1,72 @@
+/*
+ * @filename	demand.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Maximum demand of power meters, with time of occurrence and reset
+ * @comment		demand period and sliding window are items, see ID_DEMAND_PERIOD and ID_DEMAND_SLIDE
+ */
+
+package powermeter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+get values with the time they occurred, such as maximum demand
+
+# Params
+
+id uint8: item id, such as ID_DEMAND_ACTIVE_POSI_MAX
+
+# Returns
+
+ret float64: value in unit of item, see GetItemInfo
+
+t time.Time: time of occurrence in local time zone, to the minute
+
+err error: error
+*/
+func (pm *PowerMeter) GetValTime(id uint8) (ret float64, t time.Time, err error) {
+	if pm.modelMeta == nil {
+		err = errors.New("meter not initialized")
+		return
+	}
+	if !pm.isDLT645() || int(id) >= len(pm.modelMeta.diMeta) || !pm.modelMeta.diMeta[id].timed {
+		err = fmt.Errorf("time of occurrence of item %s is not kept by %s", ItemName(id), pm.modelMeta.name)
+		return
+	}
+	dm := &pm.modelMeta.diMeta[id]
+	if !dm.readable {
+		err = fmt.Errorf("unreadable data identifier of item %s", ItemName(id))
+		return
+	}
+	ret, t, err = pm.dlt.ReadValueTime(dm.di, int(dm.size), int(dm.decimals), dm.hasSymbol)
+	if err != nil {
+		return
+	}
+	ret *= float64(dm.override)
+	return
+}
+
+/*
+reset maximum demand, which needs password of DL/T 645 meters, see SetDLT645Password
+
+# Returns
+
+err error: error
+*/
+func (pm *PowerMeter) ResetDemand() (err error) {
+	if pm.modelMeta == nil {
+		err = errors.New("meter not initialized")
+		return
+	}
+	if !pm.modelMeta.demandReset {
+		err = fmt.Errorf("demand reset is not supported by %s", pm.modelMeta.name)
+		return
+	}
+	err = pm.dlt.DemandReset()
+	return
+}
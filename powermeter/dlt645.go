@@ -0,0 +1,264 @@
+/*
+ * @filename	dlt645.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		DL/T 645-2007 power meter model, reading items by data identifier
+ * @comment		forward and reverse energy are 4-byte BCD with 2 decimals, so they wrap at 1e6 kWh
+ */
+
+package powermeter
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/dlt645"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// data identifier metadata of DL/T 645-2007 meters ordered by item ID, such as ID_VOLTAGE
+var diMetaDLT645 = []DIMeta{
+	ID_VOLTAGE:        {di: dlt645.DI_VOLTAGE_PHASEA, size: 2, decimals: 1, readable: true, override: 1},
+	ID_VOLTAGE_PHASEA: {di: dlt645.DI_VOLTAGE_PHASEA, size: 2, decimals: 1, readable: true, override: 1},
+	ID_VOLTAGE_PHASEB: {di: dlt645.DI_VOLTAGE_PHASEB, size: 2, decimals: 1, readable: true, override: 1},
+	ID_VOLTAGE_PHASEC: {di: dlt645.DI_VOLTAGE_PHASEC, size: 2, decimals: 1, readable: true, override: 1},
+
+	ID_CURRENT:        {di: dlt645.DI_CURRENT_PHASEA, size: 3, decimals: 3, readable: true, hasSymbol: true, override: 1},
+	ID_CURRENT_PHASEA: {di: dlt645.DI_CURRENT_PHASEA, size: 3, decimals: 3, readable: true, hasSymbol: true, override: 1},
+	ID_CURRENT_PHASEB: {di: dlt645.DI_CURRENT_PHASEB, size: 3, decimals: 3, readable: true, hasSymbol: true, override: 1},
+	ID_CURRENT_PHASEC: {di: dlt645.DI_CURRENT_PHASEC, size: 3, decimals: 3, readable: true, hasSymbol: true, override: 1},
+
+	ID_POWER_ACTIVE:        {di: dlt645.DI_POWER_ACTIVE, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_ACTIVE_PHASEA: {di: dlt645.DI_POWER_ACTIVE_PHASEA, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_ACTIVE_PHASEB: {di: dlt645.DI_POWER_ACTIVE_PHASEB, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_ACTIVE_PHASEC: {di: dlt645.DI_POWER_ACTIVE_PHASEC, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+
+	ID_POWER_PASSIVE:        {di: dlt645.DI_POWER_REACTIVE, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_PASSIVE_PHASEA: {di: dlt645.DI_POWER_REACTIVE_PHASEA, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_PASSIVE_PHASEB: {di: dlt645.DI_POWER_REACTIVE_PHASEB, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_PASSIVE_PHASEC: {di: dlt645.DI_POWER_REACTIVE_PHASEC, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+
+	ID_POWER_APPARENT:        {di: dlt645.DI_POWER_APPARENT, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_APPARENT_PHASEA: {di: dlt645.DI_POWER_APPARENT_PHASEA, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_APPARENT_PHASEB: {di: dlt645.DI_POWER_APPARENT_PHASEB, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_POWER_APPARENT_PHASEC: {di: dlt645.DI_POWER_APPARENT_PHASEC, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+
+	ID_POWER_FACTOR:        {di: dlt645.DI_POWER_FACTOR, size: 2, decimals: 3, readable: true, hasSymbol: true, override: 1},
+	ID_POWER_FACTOR_PHASEA: {di: dlt645.DI_POWER_FACTOR_PHASEA, size: 2, decimals: 3, readable: true, hasSymbol: true, override: 1},
+	ID_POWER_FACTOR_PHASEB: {di: dlt645.DI_POWER_FACTOR_PHASEB, size: 2, decimals: 3, readable: true, hasSymbol: true, override: 1},
+	ID_POWER_FACTOR_PHASEC: {di: dlt645.DI_POWER_FACTOR_PHASEC, size: 2, decimals: 3, readable: true, hasSymbol: true, override: 1},
+
+	ID_FREQ: {di: dlt645.DI_FREQ, size: 2, decimals: 2, readable: true, override: 1},
+
+	ID_ENERGY_ACTIVE_CURR_ALL:       {di: dlt645.DI_ENERGY_ACTIVE_COMBINED, size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_ACTIVE_POSI_CURR_ALL:  {di: dlt645.DI_ENERGY_ACTIVE_POSI, size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_ACTIVE_NEGA_CURR_ALL:  {di: dlt645.DI_ENERGY_ACTIVE_NEGA, size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_PASSIVE_POSI_CURR_ALL: {di: dlt645.DI_ENERGY_REACTIVE_COMB1, size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_PASSIVE_NEGA_CURR_ALL: {di: dlt645.DI_ENERGY_REACTIVE_COMB2, size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+
+	ID_ENERGY_ACTIVE_CURR_SHARP:  {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_SHARP), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_ACTIVE_CURR_PEAK:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_PEAK), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_ACTIVE_CURR_FLAT:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_FLAT), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_ACTIVE_CURR_VALLEY: {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_VALLEY), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+
+	ID_ENERGY_ACTIVE_POSI_CURR_SHARP:  {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_SHARP), size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_ACTIVE_POSI_CURR_PEAK:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_PEAK), size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_ACTIVE_POSI_CURR_FLAT:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_FLAT), size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_ACTIVE_POSI_CURR_VALLEY: {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_VALLEY), size: 4, decimals: 2, readable: true, override: 1},
+
+	ID_ENERGY_ACTIVE_NEGA_CURR_SHARP:  {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_SHARP), size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_ACTIVE_NEGA_CURR_PEAK:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_PEAK), size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_ACTIVE_NEGA_CURR_FLAT:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_FLAT), size: 4, decimals: 2, readable: true, override: 1},
+	ID_ENERGY_ACTIVE_NEGA_CURR_VALLEY: {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_VALLEY), size: 4, decimals: 2, readable: true, override: 1},
+
+	ID_ENERGY_PASSIVE_POSI_CURR_SHARP:  {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_SHARP), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_PASSIVE_POSI_CURR_PEAK:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_PEAK), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_PASSIVE_POSI_CURR_FLAT:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_FLAT), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_PASSIVE_POSI_CURR_VALLEY: {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_VALLEY), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+
+	ID_ENERGY_PASSIVE_NEGA_CURR_SHARP:  {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_SHARP), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_PASSIVE_NEGA_CURR_PEAK:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_PEAK), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_PASSIVE_NEGA_CURR_FLAT:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_FLAT), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+	ID_ENERGY_PASSIVE_NEGA_CURR_VALLEY: {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_VALLEY), size: 4, decimals: 2, readable: true, hasSymbol: true, override: 1},
+
+	ID_DEMAND_ACTIVE:          {di: dlt645.DI_DEMAND_ACTIVE, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_DEMAND_PASSIVE:         {di: dlt645.DI_DEMAND_REACTIVE, size: 3, decimals: 4, readable: true, hasSymbol: true, override: 1000},
+	ID_DEMAND_ACTIVE_POSI_MAX: {di: dlt645.DI_MAX_DEMAND_ACTIVE_POSI, size: 3, decimals: 4, readable: true, override: 1000, timed: true},
+	ID_DEMAND_ACTIVE_NEGA_MAX: {di: dlt645.DI_MAX_DEMAND_ACTIVE_NEGA, size: 3, decimals: 4, readable: true, override: 1000, timed: true},
+	ID_DEMAND_PERIOD:          {di: dlt645.DI_DEMAND_PERIOD, size: 1, decimals: 0, readable: true, writable: true, override: 1},
+	ID_DEMAND_SLIDE:           {di: dlt645.DI_DEMAND_SLIDE, size: 1, decimals: 0, readable: true, writable: true, override: 1},
+}
+
+var modelMetaDLT645 = ModelMeta{
+	name:         "DL/T 645-2007",
+	protocol:     meter.PROTOCOL_DLT645,
+	diMeta:       diMetaDLT645,
+	dltCloseCmd:  dlt645.REMOTE_CLOSE,
+	tariffAmount: 4,
+	demandReset:  true,
+	historyMeta: []HistoryMeta{
+		HISTORY_DAILY: {
+			depth:    62,
+			timeDI:   dlt645.DI_FREEZE_DAY_TIME,
+			size:     4,
+			decimals: 2,
+			// each record holds the total followed by rates
+			items: map[uint8]HistoryItem{
+				ID_ENERGY_ACTIVE_POSI_CURR_ALL:    {di: dlt645.DI_FREEZE_DAY_ACTIVE_POSI, index: 0},
+				ID_ENERGY_ACTIVE_POSI_CURR_SHARP:  {di: dlt645.DI_FREEZE_DAY_ACTIVE_POSI, index: 1},
+				ID_ENERGY_ACTIVE_POSI_CURR_PEAK:   {di: dlt645.DI_FREEZE_DAY_ACTIVE_POSI, index: 2},
+				ID_ENERGY_ACTIVE_POSI_CURR_FLAT:   {di: dlt645.DI_FREEZE_DAY_ACTIVE_POSI, index: 3},
+				ID_ENERGY_ACTIVE_POSI_CURR_VALLEY: {di: dlt645.DI_FREEZE_DAY_ACTIVE_POSI, index: 4},
+				ID_ENERGY_ACTIVE_NEGA_CURR_ALL:    {di: dlt645.DI_FREEZE_DAY_ACTIVE_NEGA, index: 0},
+				ID_ENERGY_ACTIVE_NEGA_CURR_SHARP:  {di: dlt645.DI_FREEZE_DAY_ACTIVE_NEGA, index: 1},
+				ID_ENERGY_ACTIVE_NEGA_CURR_PEAK:   {di: dlt645.DI_FREEZE_DAY_ACTIVE_NEGA, index: 2},
+				ID_ENERGY_ACTIVE_NEGA_CURR_FLAT:   {di: dlt645.DI_FREEZE_DAY_ACTIVE_NEGA, index: 3},
+				ID_ENERGY_ACTIVE_NEGA_CURR_VALLEY: {di: dlt645.DI_FREEZE_DAY_ACTIVE_NEGA, index: 4},
+			},
+		},
+		HISTORY_MONTHLY: {
+			depth:    12,
+			size:     4,
+			decimals: 2,
+			items: map[uint8]HistoryItem{
+				ID_ENERGY_ACTIVE_CURR_ALL:          {di: dlt645.DI_ENERGY_ACTIVE_COMBINED},
+				ID_ENERGY_ACTIVE_CURR_SHARP:        {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_SHARP)},
+				ID_ENERGY_ACTIVE_CURR_PEAK:         {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_PEAK)},
+				ID_ENERGY_ACTIVE_CURR_FLAT:         {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_FLAT)},
+				ID_ENERGY_ACTIVE_CURR_VALLEY:       {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_COMBINED, TARIFF_VALLEY)},
+				ID_ENERGY_ACTIVE_POSI_CURR_ALL:     {di: dlt645.DI_ENERGY_ACTIVE_POSI},
+				ID_ENERGY_ACTIVE_POSI_CURR_SHARP:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_SHARP)},
+				ID_ENERGY_ACTIVE_POSI_CURR_PEAK:    {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_PEAK)},
+				ID_ENERGY_ACTIVE_POSI_CURR_FLAT:    {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_FLAT)},
+				ID_ENERGY_ACTIVE_POSI_CURR_VALLEY:  {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_POSI, TARIFF_VALLEY)},
+				ID_ENERGY_ACTIVE_NEGA_CURR_ALL:     {di: dlt645.DI_ENERGY_ACTIVE_NEGA},
+				ID_ENERGY_ACTIVE_NEGA_CURR_SHARP:   {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_SHARP)},
+				ID_ENERGY_ACTIVE_NEGA_CURR_PEAK:    {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_PEAK)},
+				ID_ENERGY_ACTIVE_NEGA_CURR_FLAT:    {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_FLAT)},
+				ID_ENERGY_ACTIVE_NEGA_CURR_VALLEY:  {di: dlt645.RateDI(dlt645.DI_ENERGY_ACTIVE_NEGA, TARIFF_VALLEY)},
+				ID_ENERGY_PASSIVE_POSI_CURR_ALL:    {di: dlt645.DI_ENERGY_REACTIVE_COMB1},
+				ID_ENERGY_PASSIVE_POSI_CURR_SHARP:  {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_SHARP)},
+				ID_ENERGY_PASSIVE_POSI_CURR_PEAK:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_PEAK)},
+				ID_ENERGY_PASSIVE_POSI_CURR_FLAT:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_FLAT)},
+				ID_ENERGY_PASSIVE_POSI_CURR_VALLEY: {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB1, TARIFF_VALLEY)},
+				ID_ENERGY_PASSIVE_NEGA_CURR_ALL:    {di: dlt645.DI_ENERGY_REACTIVE_COMB2},
+				ID_ENERGY_PASSIVE_NEGA_CURR_SHARP:  {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_SHARP)},
+				ID_ENERGY_PASSIVE_NEGA_CURR_PEAK:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_PEAK)},
+				ID_ENERGY_PASSIVE_NEGA_CURR_FLAT:   {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_FLAT)},
+				ID_ENERGY_PASSIVE_NEGA_CURR_VALLEY: {di: dlt645.RateDI(dlt645.DI_ENERGY_REACTIVE_COMB2, TARIFF_VALLEY)},
+			},
+		},
+	},
+	broadcastMeta: []BroadcastMeta{
+		{cmd: meter.BROADCAST_CMD_TIME_SYNC},
+		{cmd: meter.BROADCAST_CMD_FREEZE},
+	},
+}
+
+// validity of remote control commands sent to DL/T 645 meters
+const dltRemoteExpiry = 5 * time.Minute
+
+// if meter speaks DL/T 645 rather than Modbus-RTU
+func (pm *PowerMeter) isDLT645() bool {
+	return pm.modelMeta != nil && pm.modelMeta.protocol == meter.PROTOCOL_DLT645
+}
+
+// GetVal of DL/T 645 meters
+func (pm *PowerMeter) getValDLT645(id uint8) (ret float64, err error) {
+	if int(id) >= len(pm.modelMeta.diMeta) || pm.modelMeta.diMeta[id].size == 0 {
+		err = fmt.Errorf("undefined data identifier metadata of item %s", ItemName(id))
+		return
+	}
+	dm := &pm.modelMeta.diMeta[id]
+	if !dm.readable {
+		err = fmt.Errorf("unreadable data identifier of item %s", ItemName(id))
+		return
+	}
+	ret, err = pm.dlt.ReadValue(dm.di, int(dm.size), int(dm.decimals), dm.hasSymbol)
+	if err != nil {
+		return
+	}
+	ret *= float64(dm.override)
+	return
+}
+
+// SetVal of DL/T 645 meters
+func (pm *PowerMeter) setValDLT645(id uint8, val float64) (err error) {
+	if int(id) >= len(pm.modelMeta.diMeta) || pm.modelMeta.diMeta[id].size == 0 {
+		err = fmt.Errorf("undefined data identifier metadata of item %s", ItemName(id))
+		return
+	}
+	dm := &pm.modelMeta.diMeta[id]
+	if !dm.writable {
+		err = fmt.Errorf("unwritable data identifier of item %s", ItemName(id))
+		return
+	}
+	var data []byte
+	data, err = dlt645.EncodeBCD(val/float64(dm.override), int(dm.size), int(dm.decimals), dm.hasSymbol)
+	if err != nil {
+		return
+	}
+	err = pm.dlt.Write(dm.di, data)
+	return
+}
+
+// rollover of DL/T 645 item, where the BCD digits run out
+func rolloverDLT645(kind uint8, dm *DIMeta) (rollover float64) {
+	if dm.hasSymbol {
+		// signed values do not wrap as counters
+		return
+	}
+	rollover = meter.Rollover(kind, math.Pow10(2*int(dm.size)-int(dm.decimals))*regio.Scale(dm.override))
+	return
+}
+
+// GetSwitchStatus of DL/T 645 meters, by relay bit of run status word 3
+func (pm *PowerMeter) getSwitchDLT645(turn uint8) (stat bool, err error) {
+	if turn != POWERSWITCH_TURN_1 {
+		err = fmt.Errorf("invalid power switch turn %d", turn)
+		return
+	}
+	var data []byte
+	data, err = pm.dlt.Read(dlt645.DI_RUN_STATUS_3)
+	if err != nil {
+		return
+	}
+	if len(data) < 2 {
+		err = fmt.Errorf("dlt645 short run status word, %d bytes", len(data))
+		return
+	}
+	word := uint16(data[0]) | uint16(data[1])<<8
+	stat = word&dlt645.RUN_STATUS_3_RELAY_TRIPPED == 0
+	return
+}
+
+// trip or close command of DL/T 645 meters
+func (pm *PowerMeter) setSwitchDLT645(turn uint8, stat bool) (err error) {
+	if turn != POWERSWITCH_TURN_1 {
+		err = fmt.Errorf("invalid power switch turn %d", turn)
+		return
+	}
+	cmd := dlt645.REMOTE_TRIP
+	if stat {
+		cmd = pm.modelMeta.dltCloseCmd
+	}
+	err = pm.dlt.RemoteControl(cmd, time.Now().Add(dltRemoteExpiry))
+	return
+}
+
+/*
+set password and operator code for DL/T 645 meters, used by remote trip/close and writes
+
+# Params
+
+level byte: password level, such as 0x02 or 0x04
+
+password [3]byte: password, lowest byte first
+
+operator [4]byte: operator code
+*/
+func (pm *PowerMeter) SetDLT645Password(level byte, password [3]byte, operator [4]byte) {
+	pm.dlt.SetPassword(level, password, operator)
+}
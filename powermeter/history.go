@@ -0,0 +1,182 @@
+/*
+ * @filename	history.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Frozen readings kept by power meters, such as daily freeze and monthly settlement
+ * @comment		only DL/T 645 meters keep history for now
+ */
+
+package powermeter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/dlt645"
+)
+
+// history kinds
+const (
+	// daily freeze, recorded at the start of each day
+	HISTORY_DAILY uint8 = iota
+	// monthly settlement, recorded at the settlement day of each month
+	HISTORY_MONTHLY
+)
+
+// frozen reading of one item
+type FrozenReading struct {
+	// record number, 1 for the last one
+	N int
+	// time of freeze in local time zone
+	Time time.Time
+	// value in unit of item, see GetItemInfo
+	Value float64
+}
+
+/*
+read the n-th last frozen reading of an item
+
+# Params
+
+kind uint8: history kind, using macro HISTORY_*
+
+id uint8: item id, such as ID_ENERGY_ACTIVE_POSI_CURR_ALL
+
+n int: record number, 1 for the last one
+
+# Returns
+
+reading FrozenReading: value with freeze time
+
+err error: error
+*/
+func (pm *PowerMeter) GetFrozenVal(kind uint8, id uint8, n int) (reading FrozenReading, err error) {
+	var hm *HistoryMeta
+	var hi HistoryItem
+	hm, hi, err = pm.historyItem(kind, id)
+	if err != nil {
+		return
+	}
+	if n < 1 || n > hm.depth {
+		err = fmt.Errorf("invalid record number %d, 1 - %d expected", n, hm.depth)
+		return
+	}
+	reading.N = n
+	var data []byte
+	data, err = pm.dlt.Read(dlt645.RecordDI(hi.di, uint8(n)))
+	if err != nil {
+		return
+	}
+	offset := hi.index * int(hm.size)
+	if len(data) < offset+int(hm.size) {
+		err = fmt.Errorf("dlt645 short record of item %s, %d bytes", ItemName(id), len(data))
+		return
+	}
+	reading.Value, err = dlt645.DecodeBCD(data[offset:offset+int(hm.size)], int(hm.decimals), pm.modelMeta.diMeta[id].hasSymbol)
+	if err != nil {
+		return
+	}
+	reading.Value *= float64(pm.modelMeta.diMeta[id].override)
+	if hm.timeDI != 0 {
+		reading.Time, err = pm.dlt.ReadTimestamp(dlt645.RecordDI(hm.timeDI, uint8(n)))
+	} else {
+		reading.Time, err = pm.settlementTime(n)
+	}
+	return
+}
+
+/*
+read the last frozen readings of an item, ordered from the latest
+
+# Params
+
+kind uint8: history kind, using macro HISTORY_*
+
+id uint8: item id, such as ID_ENERGY_ACTIVE_POSI_CURR_ALL
+
+last int: number of records, limited to what the meter keeps
+
+# Returns
+
+readings []FrozenReading: values with freeze time, those read before an error are returned
+
+err error: error
+*/
+func (pm *PowerMeter) GetFrozenVals(kind uint8, id uint8, last int) (readings []FrozenReading, err error) {
+	var hm *HistoryMeta
+	hm, _, err = pm.historyItem(kind, id)
+	if err != nil {
+		return
+	}
+	if last > hm.depth {
+		last = hm.depth
+	}
+	for n := 1; n <= last; n++ {
+		var reading FrozenReading
+		reading, err = pm.GetFrozenVal(kind, id, n)
+		if err != nil {
+			return
+		}
+		readings = append(readings, reading)
+	}
+	return
+}
+
+/*
+get number of records the meter model keeps
+
+# Params
+
+kind uint8: history kind, using macro HISTORY_*
+
+# Returns
+
+depth int: number of records, 0 if not supported
+*/
+func (pm *PowerMeter) GetHistoryDepth(kind uint8) (depth int) {
+	if pm.modelMeta == nil || int(kind) >= len(pm.modelMeta.historyMeta) {
+		return
+	}
+	depth = pm.modelMeta.historyMeta[kind].depth
+	return
+}
+
+// get history metadata of item
+func (pm *PowerMeter) historyItem(kind uint8, id uint8) (hm *HistoryMeta, hi HistoryItem, err error) {
+	if pm.modelMeta == nil {
+		err = errors.New("meter not initialized")
+		return
+	}
+	if int(kind) >= len(pm.modelMeta.historyMeta) || pm.modelMeta.historyMeta[kind].depth == 0 {
+		err = fmt.Errorf("history kind %d is not kept by %s", kind, pm.modelMeta.name)
+		return
+	}
+	hm = &pm.modelMeta.historyMeta[kind]
+	var ok bool
+	hi, ok = hm.items[id]
+	if !ok {
+		err = fmt.Errorf("item %s is not kept in history by %s", ItemName(id), pm.modelMeta.name)
+	}
+	return
+}
+
+// time of the n-th last monthly settlement, derived from settlement day and meter clock
+func (pm *PowerMeter) settlementTime(n int) (t time.Time, err error) {
+	var day, hour int
+	day, hour, err = pm.dlt.ReadSettlementDay()
+	if err != nil {
+		return
+	}
+	var now time.Time
+	now, err = pm.dlt.ReadDateTime()
+	if err != nil {
+		return
+	}
+	t = time.Date(now.Year(), now.Month(), day, hour, 0, 0, 0, time.Local)
+	if t.After(now) {
+		t = t.AddDate(0, -1, 0)
+	}
+	t = t.AddDate(0, 1-n, 0)
+	return
+}
@@ -0,0 +1,140 @@
+/*
+ * @filename	items.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Names, units and descriptions of power meter data items
+ * @comment		--
+ */
+
+package powermeter
+
+import "github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+
+// description of data items ordered by item ID, such as ID_VOLTAGE
+var itemTable = meter.ItemTable{
+	ID_VOLTAGE:        {Name: "voltage", Unit: "Vrms", Kind: meter.KIND_VOLTAGE, Desc: "voltage"},
+	ID_VOLTAGE_PHASEA: {Name: "voltage_phasea", Unit: "Vrms", Kind: meter.KIND_VOLTAGE, Desc: "phase A voltage"},
+	ID_VOLTAGE_PHASEB: {Name: "voltage_phaseb", Unit: "Vrms", Kind: meter.KIND_VOLTAGE, Desc: "phase B voltage"},
+	ID_VOLTAGE_PHASEC: {Name: "voltage_phasec", Unit: "Vrms", Kind: meter.KIND_VOLTAGE, Desc: "phase C voltage"},
+
+	ID_CURRENT:        {Name: "current", Unit: "Arms", Kind: meter.KIND_CURRENT, Desc: "current"},
+	ID_CURRENT_PHASEA: {Name: "current_phasea", Unit: "Arms", Kind: meter.KIND_CURRENT, Desc: "phase A current"},
+	ID_CURRENT_PHASEB: {Name: "current_phaseb", Unit: "Arms", Kind: meter.KIND_CURRENT, Desc: "phase B current"},
+	ID_CURRENT_PHASEC: {Name: "current_phasec", Unit: "Arms", Kind: meter.KIND_CURRENT, Desc: "phase C current"},
+
+	ID_POWER_ACTIVE:        {Name: "power_active", Unit: "W", Kind: meter.KIND_POWER, Desc: "active power"},
+	ID_POWER_ACTIVE_PHASEA: {Name: "power_active_phasea", Unit: "W", Kind: meter.KIND_POWER, Desc: "phase A active power"},
+	ID_POWER_ACTIVE_PHASEB: {Name: "power_active_phaseb", Unit: "W", Kind: meter.KIND_POWER, Desc: "phase B active power"},
+	ID_POWER_ACTIVE_PHASEC: {Name: "power_active_phasec", Unit: "W", Kind: meter.KIND_POWER, Desc: "phase C active power"},
+
+	ID_POWER_PASSIVE:        {Name: "power_passive", Unit: "var", Kind: meter.KIND_POWER, Desc: "passive power"},
+	ID_POWER_PASSIVE_PHASEA: {Name: "power_passive_phasea", Unit: "var", Kind: meter.KIND_POWER, Desc: "phase A passive power"},
+	ID_POWER_PASSIVE_PHASEB: {Name: "power_passive_phaseb", Unit: "var", Kind: meter.KIND_POWER, Desc: "phase B passive power"},
+	ID_POWER_PASSIVE_PHASEC: {Name: "power_passive_phasec", Unit: "var", Kind: meter.KIND_POWER, Desc: "phase C passive power"},
+
+	ID_POWER_APPARENT:        {Name: "power_apparent", Unit: "VA", Kind: meter.KIND_POWER, Desc: "apparent power"},
+	ID_POWER_APPARENT_PHASEA: {Name: "power_apparent_phasea", Unit: "VA", Kind: meter.KIND_POWER, Desc: "phase A apparent power"},
+	ID_POWER_APPARENT_PHASEB: {Name: "power_apparent_phaseb", Unit: "VA", Kind: meter.KIND_POWER, Desc: "phase B apparent power"},
+	ID_POWER_APPARENT_PHASEC: {Name: "power_apparent_phasec", Unit: "VA", Kind: meter.KIND_POWER, Desc: "phase C apparent power"},
+
+	ID_POWER_FACTOR:        {Name: "power_factor", Unit: "", Kind: meter.KIND_RATIO, Desc: "power factor, ranged 0 - 1"},
+	ID_POWER_FACTOR_PHASEA: {Name: "power_factor_phasea", Unit: "", Kind: meter.KIND_RATIO, Desc: "phase A power factor, ranged 0 - 1"},
+	ID_POWER_FACTOR_PHASEB: {Name: "power_factor_phaseb", Unit: "", Kind: meter.KIND_RATIO, Desc: "phase B power factor, ranged 0 - 1"},
+	ID_POWER_FACTOR_PHASEC: {Name: "power_factor_phasec", Unit: "", Kind: meter.KIND_RATIO, Desc: "phase C power factor, ranged 0 - 1"},
+
+	ID_FREQ: {Name: "freq", Unit: "Hz", Kind: meter.KIND_FREQUENCY, Desc: "power line frequency"},
+
+	ID_ENERGY_ACTIVE_CURR_ALL:       {Name: "energy_active_curr_all", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current active energy of all rates"},
+	ID_ENERGY_ACTIVE_POSI_CURR_ALL:  {Name: "energy_active_posi_curr_all", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current positive active energy of all rates"},
+	ID_ENERGY_ACTIVE_NEGA_CURR_ALL:  {Name: "energy_active_nega_curr_all", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current negative active energy of all rates"},
+	ID_ENERGY_PASSIVE_CURR_ALL:      {Name: "energy_passive_curr_all", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current passive energy of all rates"},
+	ID_ENERGY_PASSIVE_POSI_CURR_ALL: {Name: "energy_passive_posi_curr_all", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current positive passive energy of all rates"},
+	ID_ENERGY_PASSIVE_NEGA_CURR_ALL: {Name: "energy_passive_nega_curr_all", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current negative passive energy of all rates"},
+
+	ID_SLAVE_ADDR: {Name: "slave_addr", Unit: "", Kind: meter.KIND_ADDRESS, Desc: "Modbus-RTU slave address, ranged 1 - 247"},
+	ID_DATETIME:   {Name: "datetime", Unit: "", Kind: meter.KIND_DATETIME, Desc: "date and time"},
+
+	ID_ENERGY_ACTIVE_CURR_SHARP:  {Name: "energy_active_curr_sharp", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current active energy of sharp rate"},
+	ID_ENERGY_ACTIVE_CURR_PEAK:   {Name: "energy_active_curr_peak", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current active energy of peak rate"},
+	ID_ENERGY_ACTIVE_CURR_FLAT:   {Name: "energy_active_curr_flat", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current active energy of flat rate"},
+	ID_ENERGY_ACTIVE_CURR_VALLEY: {Name: "energy_active_curr_valley", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current active energy of valley rate"},
+
+	ID_ENERGY_ACTIVE_POSI_CURR_SHARP:  {Name: "energy_active_posi_curr_sharp", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current positive active energy of sharp rate"},
+	ID_ENERGY_ACTIVE_POSI_CURR_PEAK:   {Name: "energy_active_posi_curr_peak", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current positive active energy of peak rate"},
+	ID_ENERGY_ACTIVE_POSI_CURR_FLAT:   {Name: "energy_active_posi_curr_flat", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current positive active energy of flat rate"},
+	ID_ENERGY_ACTIVE_POSI_CURR_VALLEY: {Name: "energy_active_posi_curr_valley", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current positive active energy of valley rate"},
+
+	ID_ENERGY_ACTIVE_NEGA_CURR_SHARP:  {Name: "energy_active_nega_curr_sharp", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current negative active energy of sharp rate"},
+	ID_ENERGY_ACTIVE_NEGA_CURR_PEAK:   {Name: "energy_active_nega_curr_peak", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current negative active energy of peak rate"},
+	ID_ENERGY_ACTIVE_NEGA_CURR_FLAT:   {Name: "energy_active_nega_curr_flat", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current negative active energy of flat rate"},
+	ID_ENERGY_ACTIVE_NEGA_CURR_VALLEY: {Name: "energy_active_nega_curr_valley", Unit: "kWh", Kind: meter.KIND_ENERGY, Desc: "current negative active energy of valley rate"},
+
+	ID_ENERGY_PASSIVE_POSI_CURR_SHARP:  {Name: "energy_passive_posi_curr_sharp", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current positive passive energy of sharp rate"},
+	ID_ENERGY_PASSIVE_POSI_CURR_PEAK:   {Name: "energy_passive_posi_curr_peak", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current positive passive energy of peak rate"},
+	ID_ENERGY_PASSIVE_POSI_CURR_FLAT:   {Name: "energy_passive_posi_curr_flat", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current positive passive energy of flat rate"},
+	ID_ENERGY_PASSIVE_POSI_CURR_VALLEY: {Name: "energy_passive_posi_curr_valley", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current positive passive energy of valley rate"},
+
+	ID_ENERGY_PASSIVE_NEGA_CURR_SHARP:  {Name: "energy_passive_nega_curr_sharp", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current negative passive energy of sharp rate"},
+	ID_ENERGY_PASSIVE_NEGA_CURR_PEAK:   {Name: "energy_passive_nega_curr_peak", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current negative passive energy of peak rate"},
+	ID_ENERGY_PASSIVE_NEGA_CURR_FLAT:   {Name: "energy_passive_nega_curr_flat", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current negative passive energy of flat rate"},
+	ID_ENERGY_PASSIVE_NEGA_CURR_VALLEY: {Name: "energy_passive_nega_curr_valley", Unit: "kvarh", Kind: meter.KIND_ENERGY, Desc: "current negative passive energy of valley rate"},
+
+	ID_DEMAND_ACTIVE:          {Name: "demand_active", Unit: "W", Kind: meter.KIND_POWER, Desc: "current active demand"},
+	ID_DEMAND_PASSIVE:         {Name: "demand_passive", Unit: "var", Kind: meter.KIND_POWER, Desc: "current passive demand"},
+	ID_DEMAND_ACTIVE_POSI_MAX: {Name: "demand_active_posi_max", Unit: "W", Kind: meter.KIND_POWER, Desc: "maximum positive active demand since last reset"},
+	ID_DEMAND_ACTIVE_NEGA_MAX: {Name: "demand_active_nega_max", Unit: "W", Kind: meter.KIND_POWER, Desc: "maximum negative active demand since last reset"},
+	ID_DEMAND_PERIOD:          {Name: "demand_period", Unit: "min", Kind: meter.KIND_DURATION, Desc: "demand period"},
+	ID_DEMAND_SLIDE:           {Name: "demand_slide", Unit: "min", Kind: meter.KIND_DURATION, Desc: "sliding window step of demand"},
+}
+
+/*
+get description of data item
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+info meter.ItemInfo: item name, unit, quantity kind and description
+
+err error: error if item id is undefined
+*/
+func GetItemInfo(id uint8) (info meter.ItemInfo, err error) {
+	info, err = itemTable.Info(id)
+	return
+}
+
+/*
+format item id into its stable name, such as "energy_active_posi_curr_all"
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+name string: item name, or "item#<id>" if item id is undefined
+*/
+func ItemName(id uint8) (name string) {
+	name = itemTable.Name(id)
+	return
+}
+
+/*
+parse item name into item id, case-insensitive
+
+# Params
+
+name string: item name, such as "energy_active_posi_curr_all"
+
+# Returns
+
+id uint8: item id, one of macro ID_*
+
+err error: error if name matches no item
+*/
+func ParseItem(name string) (id uint8, err error) {
+	id, err = itemTable.Parse(name)
+	return
+}
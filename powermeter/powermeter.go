@@ -11,11 +11,13 @@ package powermeter
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/dlt645"
 	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
-
-	"github.com/kontornl/modbus"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
 )
 
 // data item identifiers in serial form
@@ -96,6 +98,64 @@ const (
 	// date and time
 	ID_DATETIME
 
+	// indicating value of current active energy of sharp rate, in kWh
+	ID_ENERGY_ACTIVE_CURR_SHARP
+	// indicating value of current active energy of peak rate, in kWh
+	ID_ENERGY_ACTIVE_CURR_PEAK
+	// indicating value of current active energy of flat rate, in kWh
+	ID_ENERGY_ACTIVE_CURR_FLAT
+	// indicating value of current active energy of valley rate, in kWh
+	ID_ENERGY_ACTIVE_CURR_VALLEY
+
+	// indicating value of current positive active energy of sharp rate, in kWh
+	ID_ENERGY_ACTIVE_POSI_CURR_SHARP
+	// indicating value of current positive active energy of peak rate, in kWh
+	ID_ENERGY_ACTIVE_POSI_CURR_PEAK
+	// indicating value of current positive active energy of flat rate, in kWh
+	ID_ENERGY_ACTIVE_POSI_CURR_FLAT
+	// indicating value of current positive active energy of valley rate, in kWh
+	ID_ENERGY_ACTIVE_POSI_CURR_VALLEY
+
+	// indicating value of current negative active energy of sharp rate, in kWh
+	ID_ENERGY_ACTIVE_NEGA_CURR_SHARP
+	// indicating value of current negative active energy of peak rate, in kWh
+	ID_ENERGY_ACTIVE_NEGA_CURR_PEAK
+	// indicating value of current negative active energy of flat rate, in kWh
+	ID_ENERGY_ACTIVE_NEGA_CURR_FLAT
+	// indicating value of current negative active energy of valley rate, in kWh
+	ID_ENERGY_ACTIVE_NEGA_CURR_VALLEY
+
+	// indicating value of current positive passive energy of sharp rate, in kvarh
+	ID_ENERGY_PASSIVE_POSI_CURR_SHARP
+	// indicating value of current positive passive energy of peak rate, in kvarh
+	ID_ENERGY_PASSIVE_POSI_CURR_PEAK
+	// indicating value of current positive passive energy of flat rate, in kvarh
+	ID_ENERGY_PASSIVE_POSI_CURR_FLAT
+	// indicating value of current positive passive energy of valley rate, in kvarh
+	ID_ENERGY_PASSIVE_POSI_CURR_VALLEY
+
+	// indicating value of current negative passive energy of sharp rate, in kvarh
+	ID_ENERGY_PASSIVE_NEGA_CURR_SHARP
+	// indicating value of current negative passive energy of peak rate, in kvarh
+	ID_ENERGY_PASSIVE_NEGA_CURR_PEAK
+	// indicating value of current negative passive energy of flat rate, in kvarh
+	ID_ENERGY_PASSIVE_NEGA_CURR_FLAT
+	// indicating value of current negative passive energy of valley rate, in kvarh
+	ID_ENERGY_PASSIVE_NEGA_CURR_VALLEY
+
+	// current active demand, average active power over demand period, in W
+	ID_DEMAND_ACTIVE
+	// current passive demand, average passive power over demand period, in var
+	ID_DEMAND_PASSIVE
+	// maximum positive active demand since last reset, in W
+	ID_DEMAND_ACTIVE_POSI_MAX
+	// maximum negative active demand since last reset, in W
+	ID_DEMAND_ACTIVE_NEGA_MAX
+	// demand period, in min
+	ID_DEMAND_PERIOD
+	// sliding window step of demand, in min
+	ID_DEMAND_SLIDE
+
 	// (reserved) ID amount counter, must be at the end
 	ID_DATA_ITEM_AMOUNT__
 )
@@ -111,9 +171,25 @@ const (
 	POWERSWITCH_TURN_8
 )
 
+// default policy of waiting for power switches, relays answer at once but motorised breakers take seconds
+var switchPolicy = meter.ActuationPolicy{
+	Timeout:  3 * time.Second,
+	Interval: 200 * time.Millisecond,
+}
+
+// time-of-use tariff rates, numbered as DL/T 645 does
+const (
+	TARIFF_SHARP uint8 = iota + 1
+	TARIFF_PEAK
+	TARIFF_FLAT
+	TARIFF_VALLEY
+)
+
 // meter model definitions
 const (
 	METER_MODEL_DDS4921 uint8 = iota
+	// generic meter speaking DL/T 645-2007
+	METER_MODEL_DLT645
 )
 
 /*
@@ -125,27 +201,73 @@ gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, an
 
 meterModel uint8: meter model id, using macro METER_MODEL_*
 
-slaveAddr uint8: Modbus-RTU address of the meter
+slaveAddr uint8: Modbus-RTU address of the meter, or DL/T 645 address in decimal for DL/T 645 meters
 
 # Returns
 
 err error: error
 */
 func (pm *PowerMeter) Init(gw *gateway.MBRTGateway, meterModel uint8, slaveAddr uint8) (err error) {
-	if slaveAddr > 60 {
-		err = errors.New("invalid slave address which exceeds 60")
-		return
-	}
-	if meterModel == METER_MODEL_DDS4921 {
-		pm.regMeta = regMetaDDS4921
-		pm.SwitchMeta = switchMetaDDS4921
-	} else {
+	err = pm.InitWithAddr(gw, meterModel, strconv.Itoa(int(slaveAddr)))
+	return
+}
+
+/*
+initialize power meter instance with address in protocol format
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, and needs to be initialized in advance
+
+meterModel uint8: meter model id, using macro METER_MODEL_*
+
+addr string: Modbus-RTU address in decimal such as "2", or DL/T 645 address up to 12 digits such as "000012345678"
+
+# Returns
+
+err error: error
+*/
+func (pm *PowerMeter) InitWithAddr(gw *gateway.MBRTGateway, meterModel uint8, addr string) (err error) {
+	var modelMeta *ModelMeta
+	switch meterModel {
+	case METER_MODEL_DDS4921:
+		modelMeta = &modelMetaDDS4921
+	case METER_MODEL_DLT645:
+		modelMeta = &modelMetaDLT645
+	default:
 		// invalid meter model
 		err = errors.New("invalid meter type")
 		return
 	}
+	var slaveAddr uint8
+	if modelMeta.protocol == meter.PROTOCOL_DLT645 {
+		var dltAddr [6]byte
+		dltAddr, err = dlt645.ParseAddr(addr)
+		if err != nil {
+			return
+		}
+		err = pm.dlt.Init(gw, dltAddr)
+		if err != nil {
+			return
+		}
+		addr = dlt645.FormatAddr(dltAddr)
+	} else {
+		slaveAddr, err = meter.ParseSlaveAddr(addr, modelMeta.maxSlaveAddr)
+		if err != nil {
+			return
+		}
+		err = pm.engine.Init(gw, slaveAddr, regio.DefaultPolicy)
+		if err != nil {
+			return
+		}
+	}
+	pm.model = meterModel
+	pm.modelMeta = modelMeta
+	pm.regMeta = modelMeta.regMeta
+	pm.SwitchMeta = modelMeta.switchMeta
 	pm.gateway = gw
 	pm.slaveAddr = slaveAddr
+	pm.addr = addr
 	return
 }
 
@@ -163,46 +285,49 @@ ret float64: value in float64, the unit might be one of the following: Vrms, Arm
 err error: error
 */
 func (pm *PowerMeter) GetVal(id uint8) (ret float64, err error) {
-	time.Sleep(5 * time.Millisecond)
-	if pm.gateway.GetClient() == nil {
-		pm.gateway.Reinit()
+	if pm.isDLT645() {
+		ret, err = pm.getValDLT645(id)
+		return
 	}
-	pm.gateway.GetClient().SetUnitId(pm.slaveAddr)
-	var regval []uint16
-	ret = 0.0
-	if pm.regMeta[id].length == 0 {
-		err = errors.New("undefined register metadata")
+	if int(id) >= len(pm.regMeta) || pm.regMeta[id].length == 0 {
+		err = fmt.Errorf("undefined register metadata of item %s", ItemName(id))
 		return
 	}
 	if !pm.regMeta[id].readable {
-		err = errors.New("unreadable register")
+		err = fmt.Errorf("unreadable register of item %s", ItemName(id))
 		return
 	}
-	for retry := 3; ; retry-- {
-		regval, err = pm.gateway.GetClient().ReadRegisters(
-			pm.regMeta[id].regAddr,
-			pm.regMeta[id].length,
-			modbus.HOLDING_REGISTER,
-		)
-		if err != nil {
-			if retry > 0 {
-				err = pm.gateway.Reconnect()
-			}
-			if err != nil {
-				return
-			}
-		} else {
-			break
-		}
+	ret, err = pm.engine.Read(pm.regMeta[id].reg())
+	return
+}
+
+/*
+set values such as demand period
+
+# Params
+
+id uint8: item id, specifies which value should be set, using macro ID_*
+
+val float64: value in unit of item, see GetItemInfo
+
+# Returns
+
+err error: error
+*/
+func (pm *PowerMeter) SetVal(id uint8, val float64) (err error) {
+	if pm.isDLT645() {
+		err = pm.setValDLT645(id, val)
+		return
 	}
-	for i := 0; i < len(regval); i++ {
-		ret *= 65536
-		ret += float64(regval[i])
+	if int(id) >= len(pm.regMeta) || pm.regMeta[id].length == 0 {
+		err = fmt.Errorf("undefined register metadata of item %s", ItemName(id))
+		return
 	}
-	ret *= float64(pm.regMeta[id].override)
-	if pm.regMeta[id].hasSymbol && (regval[0]/32768 == 1) {
-		ret *= -1
+	if !pm.regMeta[id].writable {
+		err = fmt.Errorf("unwritable register of item %s", ItemName(id))
+		return
 	}
+	err = pm.engine.Write(pm.regMeta[id].reg(), val, true)
 	return
 }
 
@@ -220,39 +345,32 @@ stat bool: switch status, true is closed (turned on), false is tripped (turned o
 err error: error
 */
 func (pm *PowerMeter) GetSwitchStatus(turn uint8) (stat bool, err error) {
-	time.Sleep(5 * time.Millisecond)
-	if pm.gateway.GetClient() == nil {
-		pm.gateway.Reinit()
+	if pm.isDLT645() {
+		stat, err = pm.getSwitchDLT645(turn)
+		return
 	}
-	pm.gateway.GetClient().SetUnitId(pm.slaveAddr)
-	var regval uint16
-	stat = false
+	if int(turn) >= len(pm.SwitchMeta) {
+		err = fmt.Errorf("invalid power switch turn %d", turn)
+		return
+	}
+	var regval []uint16
 	// (23/07/2024 kontornl) the register may just a coil, not a holding register
-	for retry := 3; ; retry-- {
-		regval, err = pm.gateway.GetClient().ReadRegister(pm.SwitchMeta[turn].statusAddr, modbus.HOLDING_REGISTER)
-		if err != nil {
-			if retry > 0 {
-				err = pm.gateway.Reconnect()
-			}
-			if err != nil {
-				return
-			}
-		} else {
-			break
-		}
+	regval, err = pm.engine.ReadRaw(pm.SwitchMeta[turn].statusAddr, 1, regio.REGTYPE_HOLDING)
+	if err != nil {
+		return
 	}
-	if regval == pm.SwitchMeta[turn].statusTripVal {
+	if regval[0] == pm.SwitchMeta[turn].statusTripVal {
 		stat = false
-	} else if regval == pm.SwitchMeta[turn].statusCloseVal {
+	} else if regval[0] == pm.SwitchMeta[turn].statusCloseVal {
 		stat = true
 	} else {
-		err = fmt.Errorf("bad register value 0x%04x", regval)
+		err = fmt.Errorf("bad register value 0x%04x", regval[0])
 	}
 	return
 }
 
 /*
-power switch trip (turn off) command
+power switch trip (turn off) command, waiting until the switch reports tripped, see ActuateSwitch
 
 # Params
 
@@ -263,38 +381,12 @@ turn uint8: which switch should be operated, give operand using macro such as PO
 err error: error
 */
 func (pm *PowerMeter) Trip(turn uint8) (err error) {
-	time.Sleep(5 * time.Millisecond)
-	if pm.gateway.GetClient() == nil {
-		pm.gateway.Reinit()
-	}
-	pm.gateway.GetClient().SetUnitId(pm.slaveAddr)
-	for retry := 3; ; retry-- {
-		err = pm.gateway.GetClient().WriteRegisters(pm.SwitchMeta[turn].ctlAddr, []uint16{pm.SwitchMeta[turn].ctlTripCmd})
-		if err != nil {
-			if retry > 0 {
-				err = pm.gateway.Reconnect()
-			}
-			if err != nil {
-				return
-			}
-		} else {
-			time.Sleep(200 * time.Millisecond)
-			break
-		}
-	}
-	var stat bool
-	stat, err = pm.GetSwitchStatus(turn)
-	if err != nil {
-		return
-	}
-	if stat != false {
-		err = errors.New("power switch status mismatch")
-	}
+	_, err = pm.ActuateSwitch(turn, false, nil)
 	return
 }
 
 /*
-power switch close (turn on) command
+power switch close (turn on) command, waiting until the switch reports closed, see ActuateSwitch
 
 # Params
 
@@ -305,32 +397,161 @@ turn uint8: which switch should be operated, give operand using macro such as PO
 err error: error
 */
 func (pm *PowerMeter) Close(turn uint8) (err error) {
-	time.Sleep(5 * time.Millisecond)
-	if pm.gateway.GetClient() == nil {
-		pm.gateway.Reinit()
-	}
-	pm.gateway.GetClient().SetUnitId(pm.slaveAddr)
-	for retry := 3; ; retry-- {
-		err = pm.gateway.GetClient().WriteRegisters(pm.SwitchMeta[turn].ctlAddr, []uint16{pm.SwitchMeta[turn].ctlCloseCmd})
-		if err != nil {
-			if retry > 0 {
-				err = pm.gateway.Reconnect()
-			}
-			if err != nil {
-				return
-			}
-		} else {
-			time.Sleep(200 * time.Millisecond)
-			break
+	_, err = pm.ActuateSwitch(turn, true, nil)
+	return
+}
+
+/*
+write trip or close command, then poll switch status until it reaches the target or times out
+
+# Params
+
+turn uint8: which switch should be operated, give operand using macro such as POWERSWITCH_TURN_1
+
+stat bool: true to close (turn on), false to trip (turn off)
+
+progress func(a meter.Actuation) (stop bool): called after each poll with intermediate state, return true to stop waiting, could be nil
+
+# Returns
+
+a meter.Actuation: last switch state with 1 for closed and 0 for tripped, phase and elapsed actuation time
+
+err error: error sending the command, or meter.ErrActuation* if the switch did not complete
+*/
+func (pm *PowerMeter) ActuateSwitch(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error) {
+	if pm.isDLT645() {
+		err = pm.setSwitchDLT645(turn, stat)
+	} else {
+		if int(turn) >= len(pm.SwitchMeta) {
+			err = fmt.Errorf("invalid power switch turn %d", turn)
+			return
+		}
+		cmd := pm.SwitchMeta[turn].ctlTripCmd
+		if stat {
+			cmd = pm.SwitchMeta[turn].ctlCloseCmd
 		}
+		err = pm.engine.WriteRaw(pm.SwitchMeta[turn].ctlAddr, []uint16{cmd}, false, -1)
 	}
-	var stat bool
-	stat, err = pm.GetSwitchStatus(turn)
 	if err != nil {
 		return
 	}
-	if stat != true {
-		err = errors.New("power switch status mismatch")
+	a, err = meter.WaitActuation(stat, pm.GetActuationPolicy(), func() (phase uint8, state uint8, err error) {
+		var on bool
+		on, err = pm.GetSwitchStatus(turn)
+		if on {
+			state = 1
+		}
+		phase = meter.ACTUATION_STALLED
+		if on == stat {
+			phase = meter.ACTUATION_DONE
+		}
+		return
+	}, progress)
+	return
+}
+
+/*
+get policy of waiting for switch actions
+
+# Returns
+
+policy meter.ActuationPolicy: policy set by SetActuationPolicy, or the default of 3 s timeout polling every 200 ms
+*/
+func (pm *PowerMeter) GetActuationPolicy() (policy meter.ActuationPolicy) {
+	policy = pm.actuationPolicy
+	if policy == (meter.ActuationPolicy{}) {
+		policy = switchPolicy
+	}
+	return
+}
+
+/*
+set policy of waiting for switch actions, such as a longer timeout for motorised breakers
+
+# Params
+
+policy meter.ActuationPolicy: timeout and poll interval, zero value for default
+*/
+func (pm *PowerMeter) SetActuationPolicy(policy meter.ActuationPolicy) {
+	pm.actuationPolicy = policy
+}
+
+/*
+get identity of the meter instance
+
+# Returns
+
+ident meter.Identity: meter kind, model, gateway address and slave address
+*/
+func (pm *PowerMeter) GetIdentity() (ident meter.Identity) {
+	ident.Kind = meter.METER_KIND_POWER
+	ident.Model = pm.model
+	if pm.modelMeta != nil {
+		ident.ModelName = pm.modelMeta.name
+		ident.Protocol = pm.modelMeta.protocol
+	}
+	if pm.gateway != nil {
+		ident.NetAddr = pm.gateway.GetNetAddr()
+	}
+	ident.SlaveAddr = pm.slaveAddr
+	ident.Addr = pm.addr
+	return
+}
+
+/*
+get description of data item, same as GetItemInfo of the package
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+info meter.ItemInfo: item name, unit, quantity kind and description
+
+err error: error if item id is undefined
+*/
+func (pm *PowerMeter) GetItemInfo(id uint8) (info meter.ItemInfo, err error) {
+	info, err = GetItemInfo(id)
+	return
+}
+
+/*
+fetch actuator status, implementing meter.Meter, same as GetSwitchStatus
+
+# Params
+
+turn uint8: which switch should be operated, using macro POWERSWITCH_TURN_*
+
+# Returns
+
+on bool: true is closed (turned on), false is tripped (turned off)
+
+err error: error
+*/
+func (pm *PowerMeter) GetActuator(turn uint8) (on bool, err error) {
+	on, err = pm.GetSwitchStatus(turn)
+	return
+}
+
+/*
+actuator command, implementing meter.Meter, calls Close if on is true, otherwise Trip
+
+# Params
+
+turn uint8: which switch should be operated, using macro POWERSWITCH_TURN_*
+
+on bool: true to close (turn on), false to trip (turn off)
+
+# Returns
+
+err error: error
+*/
+func (pm *PowerMeter) SetActuator(turn uint8, on bool) (err error) {
+	if on {
+		err = pm.Close(turn)
+	} else {
+		err = pm.Trip(turn)
 	}
 	return
 }
@@ -351,6 +572,18 @@ type RegMeta struct {
 	override float32
 }
 
+// register description used by the register access engine
+func (rm *RegMeta) reg() (reg regio.Reg) {
+	reg = regio.Reg{
+		Addr:   rm.regAddr,
+		Length: rm.length,
+		Type:   regio.REGTYPE_HOLDING,
+		Signed: rm.hasSymbol,
+		Scale:  regio.Scale(rm.override),
+	}
+	return
+}
+
 // metadata of power switch
 type SwitchMeta struct {
 	// switch controlling register address
@@ -367,17 +600,121 @@ type SwitchMeta struct {
 	statusCloseVal uint16
 }
 
+// metadata of DL/T 645 data identifier holding one item, for meters speaking DL/T 645-2007 instead of Modbus-RTU
+type DIMeta struct {
+	// data identifier
+	di uint32
+	// number of BCD bytes used to hold one value, 0 if item is not supported
+	size uint8
+	// number of decimal digits
+	decimals uint8
+	// if data identifier readable
+	readable bool
+	// if data identifier writable
+	writable bool
+	// if the highest bit is the sign
+	hasSymbol bool
+	// a value multiplied onto the original value, such as 1000 for kW to W
+	override float32
+	// if the value is followed by the time it occurred, such as maximum demand
+	timed bool
+}
+
+// plausible range of an item, used as fingerprint of meter model when scanning the bus
+type fingerprintMeta struct {
+	// item id, using macro ID_*
+	id uint8
+	// lowest plausible value
+	min float64
+	// highest plausible value
+	max float64
+}
+
+// location of one item in history records
+type HistoryItem struct {
+	// data identifier of the record, DI0 is replaced by record number
+	di uint32
+	// index of the value in the data block, such as rate number in freeze records holding all rates
+	index int
+}
+
+// where a meter model keeps frozen readings of one history kind
+type HistoryMeta struct {
+	// number of records kept, 0 if the history kind is not supported
+	depth int
+	// data identifier of freeze time of records, 0 if records are settlements timed by settlement day
+	timeDI uint32
+	// number of BCD bytes used to hold one value
+	size uint8
+	// number of decimal digits
+	decimals uint8
+	// location of items in records by item ID
+	items map[uint8]HistoryItem
+}
+
+// metadata of a broadcast-safe command of meter model
+type BroadcastMeta struct {
+	// command, using macro meter.BROADCAST_CMD_*
+	cmd uint8
+}
+
+// metadata of meter model, collecting register, switch and feature descriptions
+type ModelMeta struct {
+	// human readable model name
+	name string
+	// communication protocol, using macro meter.PROTOCOL_*
+	protocol uint8
+	// register metadata ordered by item ID, Modbus-RTU only
+	regMeta []RegMeta
+	// power switch metadata ordered by turn, Modbus-RTU only
+	switchMeta []SwitchMeta
+	// data identifier metadata ordered by item ID, DL/T 645 only
+	diMeta []DIMeta
+	// remote control command to close the relay, DL/T 645 only
+	dltCloseCmd byte
+	// number of time-of-use tariff rates, 0 if not supported
+	tariffAmount int
+	// highest slave address the model accepts, 0 for protocol limit of 247, Modbus-RTU only
+	maxSlaveAddr uint8
+	// items with plausible ranges identifying the model on bus scan, Modbus-RTU only
+	fingerprints []fingerprintMeta
+	// broadcast-safe commands, DL/T 645 only as no Modbus-RTU model documents clock or freeze registers
+	broadcastMeta []BroadcastMeta
+	// frozen readings ordered by history kind, such as HISTORY_DAILY, DL/T 645 only
+	historyMeta []HistoryMeta
+	// if maximum demand can be reset by command, DL/T 645 only
+	demandReset bool
+}
+
 type PowerMeter struct {
 	gateway    *gateway.MBRTGateway
+	engine     regio.Engine
+	dlt        dlt645.Client
 	slaveAddr  uint8
+	addr       string
+	model      uint8
+	modelMeta  *ModelMeta
 	regMeta    []RegMeta
 	SwitchMeta []SwitchMeta
+	// policy of waiting for switch actions, zero value for switchPolicy
+	actuationPolicy meter.ActuationPolicy
 }
 
 type IPowerMeter interface {
 	Init(gw *gateway.MBRTGateway, meterModel uint8, slaveAddr uint8) (err error)
+	InitWithAddr(gw *gateway.MBRTGateway, meterModel uint8, addr string) (err error)
 	GetVal(id uint8) (ret float64, err error)
 	GetSwitchStatus(turn uint8) (stat bool, err error)
 	Trip(turn uint8) (err error)
 	Close(turn uint8) (err error)
+	ActuateSwitch(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error)
+	SetActuationPolicy(policy meter.ActuationPolicy)
+	GetCapabilities() (caps meter.Capabilities)
+	SetVal(id uint8, val float64) (err error)
+	ResetDemand() (err error)
+	GetTariffSchedule(set uint8, table uint8) (segs []TariffSegment, err error)
+	SetTariffSchedule(set uint8, table uint8, segs []TariffSegment) (err error)
 }
+
+var _ meter.Meter = (*PowerMeter)(nil)
+var _ meter.Broadcaster = (*PowerMeter)(nil)
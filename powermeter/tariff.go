@@ -0,0 +1,99 @@
+/*
+ * @filename	tariff.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Time-of-use tariff schedule of power meters
+ * @comment		only DL/T 645 meters keep a tariff schedule for now
+ */
+
+package powermeter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/dlt645"
+)
+
+// tariff table sets, the standby one takes over at the switch time configured in meter
+const (
+	TARIFF_SET_CURRENT uint8 = iota + 1
+	TARIFF_SET_STANDBY
+)
+
+// segment of daily tariff schedule, rate from start time until the start of next segment
+type TariffSegment = dlt645.TimeSegment
+
+/*
+read daily tariff schedule of meter
+
+# Params
+
+set uint8: table set, using macro TARIFF_SET_*
+
+table uint8: day table number, 1 - 8
+
+# Returns
+
+segs []TariffSegment: segments ordered by start time, Rate using macro TARIFF_*
+
+err error: error
+*/
+func (pm *PowerMeter) GetTariffSchedule(set uint8, table uint8) (segs []TariffSegment, err error) {
+	err = pm.checkTariffSupport()
+	if err != nil {
+		return
+	}
+	segs, err = pm.dlt.ReadDayTable(set, table)
+	return
+}
+
+/*
+write daily tariff schedule of meter, which needs password of the meter, see SetDLT645Password
+
+# Params
+
+set uint8: table set, using macro TARIFF_SET_*, meters may refuse to write the current one
+
+table uint8: day table number, 1 - 8
+
+segs []TariffSegment: segments ordered by start time, Rate using macro TARIFF_*
+
+# Returns
+
+err error: error
+*/
+func (pm *PowerMeter) SetTariffSchedule(set uint8, table uint8, segs []TariffSegment) (err error) {
+	err = pm.checkTariffSupport()
+	if err != nil {
+		return
+	}
+	for i, seg := range segs {
+		if seg.Hour > 23 || seg.Minute > 59 {
+			err = fmt.Errorf("invalid start time %02d:%02d of tariff segment %d", seg.Hour, seg.Minute, i)
+			return
+		}
+		if seg.Rate < 1 || int(seg.Rate) > pm.modelMeta.tariffAmount {
+			err = fmt.Errorf("invalid rate %d of tariff segment %d, 1 - %d expected", seg.Rate, i, pm.modelMeta.tariffAmount)
+			return
+		}
+		if i > 0 && int(seg.Hour)*60+int(seg.Minute) <= int(segs[i-1].Hour)*60+int(segs[i-1].Minute) {
+			err = fmt.Errorf("tariff segment %d does not start after the previous one", i)
+			return
+		}
+	}
+	err = pm.dlt.WriteDayTable(set, table, segs)
+	return
+}
+
+// check if the meter model keeps a tariff schedule
+func (pm *PowerMeter) checkTariffSupport() (err error) {
+	if pm.modelMeta == nil {
+		err = errors.New("meter not initialized")
+		return
+	}
+	if pm.modelMeta.tariffAmount == 0 || !pm.isDLT645() {
+		err = fmt.Errorf("tariff schedule is not supported by %s", pm.modelMeta.name)
+	}
+	return
+}
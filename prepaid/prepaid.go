@@ -0,0 +1,413 @@
+/*
+ * @filename	prepaid.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Prepaid credit tracking with automatic disconnection and reconnection of supply
+ * @comment		works with any meter.Meter, such as PowerMeter tripping its switch or WaterMeter closing its valve
+ */
+
+package prepaid
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/consumption"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// meter of a supply point, the part of meter.Meter used by the controller
+type Meter interface {
+	// read data item, using macro ID_* of the meter kind package
+	GetVal(id uint8) (ret float64, err error)
+	// actuator command, on is true to restore supply (close power switch or open valve)
+	SetActuator(turn uint8, on bool) (err error)
+	// capabilities of meter instance
+	GetCapabilities() (caps meter.Capabilities)
+}
+
+// supply point of a tenant, billed by a cumulative item of one meter
+type Account struct {
+	// initialized meter, such as *powermeter.PowerMeter or *watermeter.WaterMeter
+	Meter Meter
+	// cumulative item billed, such as powermeter.ID_ENERGY_ACTIVE_CURR_ALL or watermeter.ID_VOLUME
+	Item uint8
+	// actuator cutting supply, using macro POWERSWITCH_TURN_* or VALVE_TURN_*
+	Turn uint8
+	// price per unit of item, such as per kWh or per m^3
+	Price float64
+}
+
+// daily window of time in which supply is never cut, may wrap over midnight such as 22:00 - 08:00
+type Window struct {
+	// start as offset from midnight
+	Start time.Duration
+	// end as offset from midnight
+	End time.Duration
+}
+
+// configuration of prepaid controller
+type Config struct {
+	// supply is cut when credit falls to it, 0 normally, negative for overdraft
+	CutoffCredit float64
+	// time from credit exhausted until supply is cut
+	Grace time.Duration
+	// daily windows in which supply is never cut, such as nights
+	Protected []Window
+	// days on which supply is never cut, only the date is taken
+	Holidays []time.Time
+	// time zone of windows and holidays, time.Local if nil
+	Location *time.Location
+	// clock of polls and grace period, time.Now if nil
+	Now func() time.Time
+}
+
+// state of an account
+type Status struct {
+	// credit left
+	Credit float64
+	// time when credit fell to CutoffCredit, zero if credit is left
+	ExhaustedAt time.Time
+	// if supply is cut by the controller
+	Cut bool
+	// usage of the last poll
+	LastUsage consumption.Usage
+	// amount charged at the last poll, 0 if usage is implausible and not charged
+	LastCharge float64
+}
+
+// prepaid controller of a group of accounts
+type Controller struct {
+	cfg Config
+	// guards cfg and accounts, never held across meter I/O
+	mtx      sync.Mutex
+	accounts map[string]*account
+}
+
+type account struct {
+	Account
+	// guards counter and status, held across meter I/O of this account only
+	mtx     sync.Mutex
+	counter consumption.Counter
+	status  Status
+}
+
+/*
+initialize controller with configuration
+
+# Params
+
+cfg Config: configuration
+
+# Returns
+
+err error: error
+*/
+func (c *Controller) Init(cfg Config) (err error) {
+	if cfg.Grace < 0 {
+		err = errors.New("negative grace period")
+		return
+	}
+	for _, w := range cfg.Protected {
+		if w.Start < 0 || w.Start >= 24*time.Hour || w.End < 0 || w.End > 24*time.Hour {
+			err = fmt.Errorf("invalid protected window %s - %s", w.Start, w.End)
+			return
+		}
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.cfg = cfg
+	c.accounts = map[string]*account{}
+	return
+}
+
+/*
+add an account, the counter of its item starts at the first poll
+
+# Params
+
+id string: account id, such as tenant or room number
+
+a Account: supply point
+
+credit float64: initial credit
+
+# Returns
+
+err error: error if the id is taken or the item is not cumulative
+*/
+func (c *Controller) AddAccount(id string, a Account, credit float64) (err error) {
+	if a.Meter == nil {
+		err = errors.New("nil meter")
+		return
+	}
+	if a.Price < 0 {
+		err = errors.New("negative price")
+		return
+	}
+	caps := a.Meter.GetCapabilities()
+	if int(a.Turn) >= caps.ActuatorAmount {
+		err = fmt.Errorf("invalid actuator turn %d of %s", a.Turn, caps.ModelName)
+		return
+	}
+	var cfg consumption.Config
+	cfg, err = consumption.ConfigOf(caps, a.Item)
+	if err != nil {
+		return
+	}
+	acc := &account{Account: a, status: Status{Credit: credit}}
+	err = acc.counter.Init(cfg)
+	if err != nil {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.accounts == nil {
+		err = errors.New("prepaid controller not initialized")
+		return
+	}
+	if _, ok := c.accounts[id]; ok {
+		err = fmt.Errorf("account %s exists", id)
+		return
+	}
+	c.accounts[id] = acc
+	return
+}
+
+/*
+remove an account, supply is left as it is
+
+# Params
+
+id string: account id
+*/
+func (c *Controller) RemoveAccount(id string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.accounts, id)
+}
+
+/*
+add credit, supply is restored at once if it was cut and credit is left
+
+# Params
+
+id string: account id
+
+amount float64: credit to add
+
+# Returns
+
+st Status: state after top-up
+
+err error: error if the account is unknown or supply could not be restored, which is retried by next poll
+*/
+func (c *Controller) TopUp(id string, amount float64) (st Status, err error) {
+	if amount <= 0 {
+		err = errors.New("top-up amount must be positive")
+		return
+	}
+	acc, cfg, err := c.account(id)
+	if err != nil {
+		return
+	}
+	acc.mtx.Lock()
+	defer acc.mtx.Unlock()
+	acc.status.Credit += amount
+	err = apply(acc, cfg, cfg.Now())
+	st = acc.status
+	return
+}
+
+/*
+read the meter of an account, charge usage since the last poll, and cut or restore supply
+
+supply is cut once credit has been exhausted for the grace period and not in protected time,
+implausible usage such as counter reset is not charged
+
+# Params
+
+id string: account id
+
+# Returns
+
+st Status: state after poll
+
+err error: error reading the meter or operating the actuator
+*/
+func (c *Controller) Poll(id string) (st Status, err error) {
+	acc, cfg, err := c.account(id)
+	if err != nil {
+		return
+	}
+	acc.mtx.Lock()
+	defer acc.mtx.Unlock()
+	now := cfg.Now()
+	var val float64
+	val, err = acc.Meter.GetVal(acc.Item)
+	if err != nil {
+		st = acc.status
+		return
+	}
+	var u consumption.Usage
+	var ok bool
+	u, ok, err = acc.counter.Add(consumption.Reading{Time: now, Value: val})
+	if err != nil {
+		st = acc.status
+		return
+	}
+	if ok {
+		acc.status.LastUsage = u
+		acc.status.LastCharge = 0
+		if !u.Implausible {
+			acc.status.LastCharge = u.Value * acc.Price
+			acc.status.Credit -= acc.status.LastCharge
+		}
+	}
+	err = apply(acc, cfg, now)
+	st = acc.status
+	return
+}
+
+/*
+poll all accounts
+
+# Returns
+
+errs map[string]error: errors by account id, empty if all succeeded
+*/
+func (c *Controller) PollAll() (errs map[string]error) {
+	errs = map[string]error{}
+	for _, id := range c.ids() {
+		if _, err := c.Poll(id); err != nil {
+			errs[id] = err
+		}
+	}
+	return
+}
+
+/*
+get state of an account
+
+# Params
+
+id string: account id
+
+# Returns
+
+st Status: state
+
+err error: error if the account is unknown
+*/
+func (c *Controller) GetStatus(id string) (st Status, err error) {
+	acc, _, err := c.account(id)
+	if err != nil {
+		return
+	}
+	acc.mtx.Lock()
+	defer acc.mtx.Unlock()
+	st = acc.status
+	return
+}
+
+/*
+check if supply must not be cut at a time
+
+# Params
+
+t time.Time: time to check
+
+# Returns
+
+protected bool: true in protected windows and on holidays
+*/
+func (c *Controller) IsProtected(t time.Time) (protected bool) {
+	c.mtx.Lock()
+	cfg := c.cfg
+	c.mtx.Unlock()
+	return isProtected(cfg, t)
+}
+
+// IsProtected with a configuration
+func isProtected(cfg Config, t time.Time) (protected bool) {
+	t = t.In(cfg.Location)
+	for _, h := range cfg.Holidays {
+		h = h.In(cfg.Location)
+		if h.Year() == t.Year() && h.YearDay() == t.YearDay() {
+			return true
+		}
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, cfg.Location)
+	offset := t.Sub(midnight)
+	for _, w := range cfg.Protected {
+		if w.Start <= w.End {
+			if offset >= w.Start && offset < w.End {
+				return true
+			}
+		} else if offset >= w.Start || offset < w.End {
+			// window over midnight
+			return true
+		}
+	}
+	return false
+}
+
+// cut or restore supply of account by its credit, called with the account locked
+func apply(acc *account, cfg Config, now time.Time) (err error) {
+	if acc.status.Credit > cfg.CutoffCredit {
+		acc.status.ExhaustedAt = time.Time{}
+		if acc.status.Cut {
+			err = acc.Meter.SetActuator(acc.Turn, true)
+			if err != nil {
+				err = fmt.Errorf("failed to restore supply: %w", err)
+				return
+			}
+			acc.status.Cut = false
+		}
+		return
+	}
+	if acc.status.ExhaustedAt.IsZero() {
+		acc.status.ExhaustedAt = now
+	}
+	if acc.status.Cut || now.Sub(acc.status.ExhaustedAt) < cfg.Grace || isProtected(cfg, now) {
+		return
+	}
+	err = acc.Meter.SetActuator(acc.Turn, false)
+	if err != nil {
+		err = fmt.Errorf("failed to cut supply: %w", err)
+		return
+	}
+	acc.status.Cut = true
+	return
+}
+
+// account by id with the configuration it is polled with
+func (c *Controller) account(id string) (acc *account, cfg Config, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	acc, ok := c.accounts[id]
+	if !ok {
+		err = fmt.Errorf("unknown account %s", id)
+		return
+	}
+	cfg = c.cfg
+	return
+}
+
+// ids of all accounts
+func (c *Controller) ids() (ids []string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for id := range c.accounts {
+		ids = append(ids, id)
+	}
+	return
+}
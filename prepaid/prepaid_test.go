@@ -0,0 +1,230 @@
+package prepaid
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// meter with one actuator, counting energy set by the test
+type fakeMeter struct {
+	energy float64
+	on     bool
+	setErr error
+}
+
+func (m *fakeMeter) GetVal(id uint8) (ret float64, err error) {
+	ret = m.energy
+	return
+}
+
+func (m *fakeMeter) SetActuator(turn uint8, on bool) (err error) {
+	if m.setErr != nil {
+		return m.setErr
+	}
+	m.on = on
+	return
+}
+
+func (m *fakeMeter) GetCapabilities() (caps meter.Capabilities) {
+	caps = meter.Capabilities{
+		ModelName:      "fake",
+		Items:          []meter.ItemCapability{{ID: 0, Name: "energy", Kind: meter.KIND_ENERGY}, {ID: 1, Name: "voltage", Kind: meter.KIND_VOLTAGE}},
+		ActuatorAmount: 1,
+	}
+	return
+}
+
+// windows and holidays are judged in cst
+var cst = time.FixedZone("CST", 8*3600)
+
+var midnight = time.Date(2026, 1, 5, 0, 0, 0, 0, cst)
+
+// night and lunch break protected, with a holiday the day after midnight
+var cfgProtected = Config{
+	Protected: []Window{{Start: 22 * time.Hour, End: 8 * time.Hour}, {Start: 12 * time.Hour, End: 13 * time.Hour}},
+	Holidays:  []time.Time{time.Date(2026, 1, 6, 12, 0, 0, 0, time.UTC)},
+	Location:  cst,
+}
+
+func TestIsProtected(t *testing.T) {
+	var c Controller
+	if err := c.Init(cfgProtected); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		hours     float64
+		protected bool
+	}{
+		{hours: -1, protected: true},
+		{hours: 7.99, protected: true},
+		{hours: 8, protected: false},
+		{hours: 12.5, protected: true},
+		{hours: 13, protected: false},
+		{hours: 21.99, protected: false},
+		{hours: 22, protected: true},
+		// all of the holiday in cst
+		{hours: 24 + 10, protected: true},
+		{hours: 24 + 21, protected: true},
+		{hours: 48 + 10, protected: false},
+	}
+	for _, tt := range tests {
+		at := midnight.Add(time.Duration(tt.hours * float64(time.Hour)))
+		if protected := c.IsProtected(at); protected != tt.protected {
+			t.Errorf("%s: protected %v, want %v", at, protected, tt.protected)
+		}
+		// the time zone of the time given does not matter
+		if protected := c.IsProtected(at.UTC()); protected != tt.protected {
+			t.Errorf("%s: protected %v, want %v", at.UTC(), protected, tt.protected)
+		}
+	}
+}
+
+func TestCutoff(t *testing.T) {
+	type step struct {
+		// hours from midnight
+		hours float64
+		// energy used since the previous poll
+		usage  float64
+		credit float64
+		cut    bool
+	}
+	tests := []struct {
+		name  string
+		cfg   Config
+		steps []step
+	}{
+		{
+			name:  "cut at once",
+			steps: []step{{hours: 10, credit: 10}, {hours: 11, usage: 12, credit: -2, cut: true}},
+		},
+		{
+			name: "cut after grace",
+			cfg:  Config{Grace: time.Hour},
+			steps: []step{
+				{hours: 10, credit: 10},
+				{hours: 10.5, usage: 10, credit: 0},
+				{hours: 11, credit: 0},
+				{hours: 11.5, usage: 1, credit: -1, cut: true},
+			},
+		},
+		{
+			name: "overdraft",
+			cfg:  Config{CutoffCredit: -5},
+			steps: []step{
+				{hours: 10, credit: 10},
+				{hours: 11, usage: 12, credit: -2},
+				{hours: 12, usage: 3, credit: -5, cut: true},
+			},
+		},
+		{
+			name: "grace ending in protected hours",
+			cfg:  Config{Grace: time.Hour, Protected: cfgProtected.Protected, Location: cst},
+			steps: []step{
+				{hours: 21, credit: 10},
+				{hours: 21.5, usage: 11, credit: -1},
+				{hours: 23, usage: 1, credit: -2},
+				{hours: 24 + 7.5, usage: 1, credit: -3},
+				{hours: 24 + 8, credit: -3, cut: true},
+			},
+		},
+		{
+			name: "counter reset not charged",
+			steps: []step{
+				{hours: 10, credit: 10},
+				{hours: 11, usage: -5, credit: 10},
+				{hours: 12, usage: 1, credit: 9},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeMeter{energy: 100, on: true}
+			var now time.Time
+			cfg := tt.cfg
+			cfg.Now = func() time.Time { return now }
+			var c Controller
+			if err := c.Init(cfg); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.AddAccount("101", Account{Meter: m, Price: 1}, 10); err != nil {
+				t.Fatal(err)
+			}
+			for i, st := range tt.steps {
+				now = midnight.Add(time.Duration(st.hours * float64(time.Hour)))
+				m.energy += st.usage
+				s, err := c.Poll("101")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(s.Credit-st.credit) > 1e-9 || s.Cut != st.cut || m.on == st.cut {
+					t.Fatalf("step %d: status %+v, supply on %v, want credit %g cut %v", i, s, m.on, st.credit, st.cut)
+				}
+			}
+		})
+	}
+}
+
+func TestTopUp(t *testing.T) {
+	errSwitch := errors.New("no response")
+	m := &fakeMeter{energy: 100, on: true}
+	now := midnight.Add(10 * time.Hour)
+	var c Controller
+	c.Init(Config{Now: func() time.Time { return now }})
+	c.AddAccount("101", Account{Meter: m, Price: 1}, 1)
+	c.Poll("101")
+	now = now.Add(time.Hour)
+	m.energy += 2
+	if s, err := c.Poll("101"); err != nil || !s.Cut || m.on {
+		t.Fatalf("status %+v, err %v, want cut", s, err)
+	}
+	m.setErr = errSwitch
+	s, err := c.TopUp("101", 5)
+	if !errors.Is(err, errSwitch) || !s.Cut || math.Abs(s.Credit-4) > 1e-9 {
+		t.Fatalf("status %+v, err %v, want credit 4 and restore failed", s, err)
+	}
+	m.setErr = nil
+	now = now.Add(time.Hour)
+	// restore retried by next poll
+	if s, err = c.Poll("101"); err != nil || s.Cut || !m.on || !s.ExhaustedAt.IsZero() {
+		t.Fatalf("status %+v, err %v, want supply restored", s, err)
+	}
+	if _, err = c.TopUp("101", 0); err == nil {
+		t.Fatal("no error topping up nothing")
+	}
+	if _, err = c.TopUp("102", 1); err == nil {
+		t.Fatal("no error topping up unknown account")
+	}
+}
+
+func TestAddAccount(t *testing.T) {
+	var c Controller
+	m := &fakeMeter{}
+	if err := c.AddAccount("101", Account{Meter: m}, 0); err == nil {
+		t.Fatal("no error before Init")
+	}
+	c.Init(Config{})
+	tests := []struct {
+		name string
+		a    Account
+		bad  bool
+	}{
+		{name: "energy", a: Account{Meter: m, Price: 1}},
+		{name: "nil meter", a: Account{Price: 1}, bad: true},
+		{name: "negative price", a: Account{Meter: m, Price: -1}, bad: true},
+		{name: "no such actuator", a: Account{Meter: m, Turn: 1}, bad: true},
+		{name: "not cumulative", a: Account{Meter: m, Item: 1}, bad: true},
+		{name: "no such item", a: Account{Meter: m, Item: 2}, bad: true},
+	}
+	for i, tt := range tests {
+		if err := c.AddAccount(string(rune('a'+i)), tt.a, 0); (err != nil) != tt.bad {
+			t.Errorf("%s: err %v, want error %v", tt.name, err, tt.bad)
+		}
+	}
+	if err := c.AddAccount("a", Account{Meter: m}, 0); err == nil {
+		t.Fatal("no error adding account twice")
+	}
+}
@@ -0,0 +1,426 @@
+/*
+ * @filename	protection.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Load limiting of power meters by rules on live readings, with automatic trip and reclose
+ * @comment		such as dormitory load limiting, rules are evaluated at each poll so their time resolution is the poll interval
+ */
+
+package protection
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/powermeter"
+)
+
+// power meter guarded, such as *powermeter.PowerMeter
+type Meter interface {
+	// read data item, using macro powermeter.ID_*
+	GetVal(id uint8) (ret float64, err error)
+	// open power switch, cutting supply
+	Trip(turn uint8) (err error)
+	// close power switch, restoring supply
+	Close(turn uint8) (err error)
+}
+
+// rule kinds
+const (
+	// active power above Limit in W
+	RULE_MAX_POWER uint8 = iota
+	// current above Limit in A
+	RULE_MAX_CURRENT
+	// step of active power of at least Limit in W with power factor at least MinPowerFactor, such as electric heaters and kettles
+	RULE_RESISTIVE_LOAD
+)
+
+// event kinds
+const (
+	// switch tripped by a rule
+	EVENT_TRIP uint8 = iota
+	// trip command failed
+	EVENT_TRIP_FAILED
+	// switch reclosed automatically after RecloseDelay
+	EVENT_RECLOSE_AUTO
+	// switch reclosed by Reclose
+	EVENT_RECLOSE_MANUAL
+	// reclose command failed
+	EVENT_RECLOSE_FAILED
+	// tripped again after MaxRecloses automatic recloses, waiting for Reclose
+	EVENT_LOCKOUT
+)
+
+// protection rule
+type Rule struct {
+	// rule name, recorded in events
+	Name string
+	// rule kind, using macro RULE_*
+	Kind uint8
+	// limit of the rule, W for power and power step, A for current
+	Limit float64
+	// lowest power factor taken as resistive load, RULE_RESISTIVE_LOAD only, 0.95 if 0
+	MinPowerFactor float64
+	// time the rule must be violated before trip, 0 to trip at first violating poll
+	Duration time.Duration
+}
+
+// configuration of guard
+type Config struct {
+	// rules, the first violated for its Duration trips the switch
+	Rules []Rule
+	// power switch operated, using macro powermeter.POWERSWITCH_TURN_*
+	Turn uint8
+	// time after trip to reclose automatically, 0 for manual reclose only
+	RecloseDelay time.Duration
+	// automatic recloses allowed within RecloseWindow, the next trip locks out until Reclose, 0 locks out at first trip
+	MaxRecloses int
+	// window counting automatic recloses, 1 h if 0
+	RecloseWindow time.Duration
+	// number of events kept, 1000 if 0
+	Retention int
+	// clock of rules and events, time.Now if nil
+	Now func() time.Time
+	// called on each event after the guard is unlocked, so it may call methods of the guard, could be nil
+	OnEvent func(ev Event)
+}
+
+// record of trip and reclose
+type Event struct {
+	// time of event
+	Time time.Time
+	// event kind, using macro EVENT_*
+	Kind uint8
+	// name of the rule which tripped, empty for reclose
+	Rule string
+	// active power at event, in W
+	Power float64
+	// current at event, in A
+	Current float64
+	// power factor at event
+	PowerFactor float64
+	// error of failed command
+	Err error
+}
+
+// load limiting guard of one power switch
+type Guard struct {
+	pm        Meter
+	cfg       Config
+	mtx       sync.Mutex
+	since     []time.Time
+	baseline  float64
+	hasBase   bool
+	tripped   bool
+	lockout   bool
+	trippedAt time.Time
+	recloses  []time.Time
+	events    []Event
+}
+
+/*
+initialize guard of a power meter switch
+
+# Params
+
+pm Meter: initialized power meter, such as *powermeter.PowerMeter
+
+cfg Config: configuration
+
+# Returns
+
+err error: error if a rule is invalid
+*/
+func (g *Guard) Init(pm Meter, cfg Config) (err error) {
+	if pm == nil {
+		err = errors.New("nil power meter")
+		return
+	}
+	if len(cfg.Rules) == 0 {
+		err = errors.New("no protection rule")
+		return
+	}
+	// keep a copy, defaults are filled in below
+	cfg.Rules = append([]Rule{}, cfg.Rules...)
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Kind > RULE_RESISTIVE_LOAD {
+			err = fmt.Errorf("invalid kind %d of rule %s", r.Kind, r.Name)
+			return
+		}
+		if r.Limit <= 0 || r.Duration < 0 {
+			err = fmt.Errorf("invalid limit or duration of rule %s", r.Name)
+			return
+		}
+		if r.Kind == RULE_RESISTIVE_LOAD && r.MinPowerFactor == 0 {
+			r.MinPowerFactor = 0.95
+		}
+	}
+	if cfg.RecloseDelay < 0 || cfg.MaxRecloses < 0 {
+		err = errors.New("negative reclose configuration")
+		return
+	}
+	if cfg.RecloseWindow == 0 {
+		cfg.RecloseWindow = time.Hour
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 1000
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.pm = pm
+	g.cfg = cfg
+	g.since = make([]time.Time, len(cfg.Rules))
+	g.hasBase = false
+	g.tripped, g.lockout = false, false
+	g.recloses = nil
+	g.events = nil
+	return
+}
+
+/*
+read live values, evaluate rules and trip, or reclose automatically when tripped
+
+# Returns
+
+events []Event: events of this poll
+
+err error: error reading the meter
+*/
+func (g *Guard) Poll() (events []Event, err error) {
+	var onEvent func(ev Event)
+	events, onEvent, err = g.poll()
+	notify(onEvent, events)
+	return
+}
+
+// Poll under lock, events are reported by the caller
+func (g *Guard) poll() (events []Event, onEvent func(ev Event), err error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	onEvent = g.cfg.OnEvent
+	if g.pm == nil {
+		err = errors.New("guard not initialized")
+		return
+	}
+	now := g.cfg.Now()
+	if g.tripped {
+		if !g.lockout && g.cfg.RecloseDelay > 0 && now.Sub(g.trippedAt) >= g.cfg.RecloseDelay {
+			events = append(events, g.reclose(now, EVENT_RECLOSE_AUTO))
+		}
+		return
+	}
+	var ev Event
+	ev, err = g.read(now)
+	if err != nil {
+		return
+	}
+	for i := range g.cfg.Rules {
+		r := &g.cfg.Rules[i]
+		if !g.violated(r, &ev) {
+			g.since[i] = time.Time{}
+			continue
+		}
+		if g.since[i].IsZero() {
+			g.since[i] = now
+		}
+		if now.Sub(g.since[i]) < r.Duration {
+			continue
+		}
+		ev.Rule = r.Name
+		events = append(events, g.trip(ev)...)
+		return
+	}
+	if !g.violating() {
+		// load settled, steps are measured from here
+		g.baseline, g.hasBase = ev.Power, true
+	}
+	return
+}
+
+/*
+reclose the switch by hand, also clearing lockout
+
+# Returns
+
+err error: error if the switch is not tripped by the guard or could not be closed
+*/
+func (g *Guard) Reclose() (err error) {
+	g.mtx.Lock()
+	if !g.tripped {
+		g.mtx.Unlock()
+		err = errors.New("switch is not tripped by guard")
+		return
+	}
+	ev := g.reclose(g.cfg.Now(), EVENT_RECLOSE_MANUAL)
+	onEvent := g.cfg.OnEvent
+	g.mtx.Unlock()
+	notify(onEvent, []Event{ev})
+	err = ev.Err
+	return
+}
+
+/*
+get state of guard
+
+# Returns
+
+tripped bool: if the switch is tripped by the guard
+
+lockout bool: if automatic reclose is locked out until Reclose
+*/
+func (g *Guard) GetState() (tripped bool, lockout bool) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	tripped, lockout = g.tripped, g.lockout
+	return
+}
+
+/*
+get recorded events, ordered by time
+
+# Returns
+
+events []Event: events, at most Retention of them
+*/
+func (g *Guard) Events() (events []Event) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	events = append(events, g.events...)
+	return
+}
+
+// read values needed by rules
+func (g *Guard) read(now time.Time) (ev Event, err error) {
+	ev.Time = now
+	var needPower, needCurrent, needPF bool
+	for _, r := range g.cfg.Rules {
+		switch r.Kind {
+		case RULE_MAX_POWER:
+			needPower = true
+		case RULE_MAX_CURRENT:
+			needCurrent = true
+		case RULE_RESISTIVE_LOAD:
+			needPower, needPF = true, true
+		}
+	}
+	if needPower {
+		ev.Power, err = g.pm.GetVal(powermeter.ID_POWER_ACTIVE)
+		if err != nil {
+			return
+		}
+	}
+	if needCurrent {
+		ev.Current, err = g.pm.GetVal(powermeter.ID_CURRENT)
+		if err != nil {
+			return
+		}
+	}
+	if needPF {
+		ev.PowerFactor, err = g.pm.GetVal(powermeter.ID_POWER_FACTOR)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// if values violate rule
+func (g *Guard) violated(r *Rule, ev *Event) bool {
+	switch r.Kind {
+	case RULE_MAX_POWER:
+		return ev.Power > r.Limit
+	case RULE_MAX_CURRENT:
+		return math.Abs(ev.Current) > r.Limit
+	case RULE_RESISTIVE_LOAD:
+		return g.hasBase && ev.Power-g.baseline >= r.Limit && math.Abs(ev.PowerFactor) >= r.MinPowerFactor
+	}
+	return false
+}
+
+// if any rule is being violated
+func (g *Guard) violating() bool {
+	for _, since := range g.since {
+		if !since.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// trip switch, locking out if automatic recloses are used up
+func (g *Guard) trip(ev Event) (events []Event) {
+	ev.Kind = EVENT_TRIP
+	ev.Err = g.pm.Trip(g.cfg.Turn)
+	if ev.Err != nil {
+		ev.Kind = EVENT_TRIP_FAILED
+		g.record(ev)
+		events = append(events, ev)
+		return
+	}
+	g.tripped, g.trippedAt = true, ev.Time
+	for i := range g.since {
+		g.since[i] = time.Time{}
+	}
+	g.record(ev)
+	events = append(events, ev)
+	// forget recloses out of window
+	var recent []time.Time
+	for _, t := range g.recloses {
+		if ev.Time.Sub(t) < g.cfg.RecloseWindow {
+			recent = append(recent, t)
+		}
+	}
+	g.recloses = recent
+	if g.cfg.RecloseDelay > 0 && len(g.recloses) >= g.cfg.MaxRecloses {
+		g.lockout = true
+		lock := Event{Time: ev.Time, Kind: EVENT_LOCKOUT, Rule: ev.Rule}
+		g.record(lock)
+		events = append(events, lock)
+	}
+	return
+}
+
+// close switch after trip
+func (g *Guard) reclose(now time.Time, kind uint8) (ev Event) {
+	ev = Event{Time: now, Kind: kind}
+	ev.Err = g.pm.Close(g.cfg.Turn)
+	if ev.Err != nil {
+		ev.Kind = EVENT_RECLOSE_FAILED
+		g.record(ev)
+		return
+	}
+	if kind == EVENT_RECLOSE_AUTO {
+		g.recloses = append(g.recloses, now)
+	} else {
+		g.recloses = nil
+	}
+	// baseline is kept from before trip, so the same appliance trips again
+	g.tripped, g.lockout = false, false
+	g.record(ev)
+	return
+}
+
+// keep event within retention
+func (g *Guard) record(ev Event) {
+	g.events = append(g.events, ev)
+	if len(g.events) > g.cfg.Retention {
+		g.events = append([]Event{}, g.events[len(g.events)-g.cfg.Retention:]...)
+	}
+}
+
+// report events to callback, called without lock
+func notify(onEvent func(ev Event), events []Event) {
+	if onEvent == nil {
+		return
+	}
+	for _, ev := range events {
+		onEvent(ev)
+	}
+}
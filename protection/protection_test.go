@@ -0,0 +1,189 @@
+package protection
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/powermeter"
+)
+
+// power meter with one switch, giving values set by the test
+type fakeMeter struct {
+	vals     map[uint8]float64
+	closed   bool
+	tripErr  error
+	closeErr error
+}
+
+func (m *fakeMeter) GetVal(id uint8) (ret float64, err error) {
+	ret, ok := m.vals[id]
+	if !ok {
+		err = errors.New("unsupported item")
+	}
+	return
+}
+
+func (m *fakeMeter) Trip(turn uint8) (err error) {
+	if m.tripErr != nil {
+		return m.tripErr
+	}
+	m.closed = false
+	return
+}
+
+func (m *fakeMeter) Close(turn uint8) (err error) {
+	if m.closeErr != nil {
+		return m.closeErr
+	}
+	m.closed = true
+	return
+}
+
+// live values of a poll
+type reading struct {
+	power, current, pf float64
+}
+
+func (r reading) vals() map[uint8]float64 {
+	return map[uint8]float64{powermeter.ID_POWER_ACTIVE: r.power, powermeter.ID_CURRENT: r.current, powermeter.ID_POWER_FACTOR: r.pf}
+}
+
+var t0 = time.Date(2026, 1, 5, 20, 0, 0, 0, time.UTC)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		// polls 30 s apart
+		readings []reading
+		// poll tripping the switch, -1 for none
+		trip int
+	}{
+		{name: "max power", rule: Rule{Kind: RULE_MAX_POWER, Limit: 1000}, readings: []reading{{power: 500}, {power: 1000}, {power: 1200}}, trip: 2},
+		{name: "max power for a minute", rule: Rule{Kind: RULE_MAX_POWER, Limit: 1000, Duration: time.Minute},
+			readings: []reading{{power: 1200}, {power: 1200}, {power: 1200}}, trip: 2},
+		{name: "max power dipping", rule: Rule{Kind: RULE_MAX_POWER, Limit: 1000, Duration: time.Minute},
+			readings: []reading{{power: 1200}, {power: 1200}, {power: 500}, {power: 1200}, {power: 1200}}, trip: -1},
+		{name: "max current reversed", rule: Rule{Kind: RULE_MAX_CURRENT, Limit: 10}, readings: []reading{{current: 5}, {current: -12}}, trip: 1},
+		{name: "resistive step", rule: Rule{Kind: RULE_RESISTIVE_LOAD, Limit: 800},
+			readings: []reading{{power: 100, pf: 0.5}, {power: 1000, pf: 0.99}}, trip: 1},
+		{name: "inductive step", rule: Rule{Kind: RULE_RESISTIVE_LOAD, Limit: 800},
+			readings: []reading{{power: 100, pf: 0.5}, {power: 1000, pf: 0.6}}, trip: -1},
+		{name: "resistive load at start", rule: Rule{Kind: RULE_RESISTIVE_LOAD, Limit: 800},
+			readings: []reading{{power: 1000, pf: 0.99}, {power: 1000, pf: 0.99}}, trip: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &fakeMeter{closed: true}
+			now := t0
+			var g Guard
+			if err := g.Init(pm, Config{Rules: []Rule{tt.rule}, Now: func() time.Time { return now }}); err != nil {
+				t.Fatal(err)
+			}
+			for i, r := range tt.readings {
+				now = t0.Add(time.Duration(i) * 30 * time.Second)
+				pm.vals = r.vals()
+				events, err := g.Poll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := 0
+				if i == tt.trip {
+					want = 1
+				}
+				if len(events) != want || (want == 1 && (events[0].Kind != EVENT_TRIP || !events[0].Time.Equal(now))) {
+					t.Fatalf("poll %d: events %+v, want %d trip", i, events, want)
+				}
+			}
+			if tripped, _ := g.GetState(); tripped != (tt.trip >= 0) || pm.closed == tripped {
+				t.Fatalf("tripped %v, switch closed %v, want trip at poll %d", tripped, pm.closed, tt.trip)
+			}
+		})
+	}
+}
+
+func TestTripAndReclose(t *testing.T) {
+	errSwitch := errors.New("no response")
+	// one automatic reclose a minute after trip, the next trip locks out
+	cfg := Config{Rules: []Rule{{Kind: RULE_MAX_POWER, Limit: 1000}}, RecloseDelay: time.Minute, MaxRecloses: 1}
+	steps := []struct {
+		name string
+		// minutes from start
+		at    float64
+		power float64
+		// reclose by hand instead of polling
+		manual bool
+		// switch command fails
+		fail    bool
+		events  []uint8
+		tripped bool
+		lockout bool
+	}{
+		{name: "trip", at: 0, power: 2000, events: []uint8{EVENT_TRIP}, tripped: true},
+		{name: "waiting reclose delay", at: 0.5, power: 2000, tripped: true},
+		{name: "automatic reclose", at: 1, power: 2000, events: []uint8{EVENT_RECLOSE_AUTO}},
+		{name: "trip again and lock out", at: 1.5, power: 2000, events: []uint8{EVENT_TRIP, EVENT_LOCKOUT}, tripped: true, lockout: true},
+		{name: "locked out after delay", at: 5, power: 500, tripped: true, lockout: true},
+		{name: "manual reclose failed", at: 6, manual: true, fail: true, events: []uint8{EVENT_RECLOSE_FAILED}, tripped: true, lockout: true},
+		{name: "manual reclose", at: 7, manual: true, events: []uint8{EVENT_RECLOSE_MANUAL}},
+		{name: "trip after manual reclose", at: 8, power: 2000, events: []uint8{EVENT_TRIP}, tripped: true},
+		{name: "automatic reclose failed", at: 9, power: 2000, fail: true, events: []uint8{EVENT_RECLOSE_FAILED}, tripped: true},
+		{name: "automatic reclose retried", at: 9.5, power: 2000, events: []uint8{EVENT_RECLOSE_AUTO}},
+		{name: "trip failed", at: 10, power: 2000, fail: true, events: []uint8{EVENT_TRIP_FAILED}},
+	}
+	pm := &fakeMeter{closed: true}
+	now := t0
+	cfg.Now = func() time.Time { return now }
+	var g Guard
+	var reported []uint8
+	cfg.OnEvent = func(ev Event) {
+		// called without the lock held
+		g.GetState()
+		reported = append(reported, ev.Kind)
+	}
+	if err := g.Init(pm, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Reclose(); err == nil {
+		t.Fatal("no error reclosing switch not tripped")
+	}
+	for _, st := range steps {
+		now = t0.Add(time.Duration(st.at * float64(time.Minute)))
+		pm.vals = reading{power: st.power}.vals()
+		pm.tripErr, pm.closeErr = nil, nil
+		if st.fail {
+			pm.tripErr, pm.closeErr = errSwitch, errSwitch
+		}
+		reported = nil
+		var kinds []uint8
+		if st.manual {
+			err := g.Reclose()
+			if st.fail != (err != nil) {
+				t.Fatalf("%s: err %v", st.name, err)
+			}
+		} else {
+			events, err := g.Poll()
+			if err != nil {
+				t.Fatalf("%s: %v", st.name, err)
+			}
+			for _, ev := range events {
+				kinds = append(kinds, ev.Kind)
+			}
+			if !slices.Equal(kinds, st.events) {
+				t.Fatalf("%s: events %v, want %v", st.name, kinds, st.events)
+			}
+		}
+		if !slices.Equal(reported, st.events) {
+			t.Fatalf("%s: reported %v, want %v", st.name, reported, st.events)
+		}
+		tripped, lockout := g.GetState()
+		if tripped != st.tripped || lockout != st.lockout || pm.closed == st.tripped {
+			t.Fatalf("%s: tripped %v lockout %v switch closed %v, want tripped %v lockout %v", st.name, tripped, lockout, pm.closed, st.tripped, st.lockout)
+		}
+	}
+	if events := g.Events(); len(events) != 10 {
+		t.Fatalf("%d events recorded, want 10", len(events))
+	}
+}
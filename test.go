@@ -32,14 +32,14 @@ func main() {
 	var ret float64
 	ret, err = pm.GetVal(powermeter.ID_POWER_FACTOR)
 	if err == nil {
-		fmt.Printf("value: %3.03f\n", ret)
+		fmt.Printf("%s: %3.03f\n", powermeter.ItemName(powermeter.ID_POWER_FACTOR), ret)
 	} else {
 		fmt.Printf("error: %v\n", err)
 	}
 	time.Sleep(500 * time.Millisecond)
 	ret, err = wm.GetVal(watermeter.ID_VOLUME)
 	if err == nil {
-		fmt.Printf("value: %3.03f\n", ret)
+		fmt.Printf("%s: %3.03f\n", watermeter.ItemName(watermeter.ID_VOLUME), ret)
 	} else {
 		fmt.Printf("error: %v\n", err)
 	}
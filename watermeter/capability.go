@@ -0,0 +1,109 @@
+/*
+ * @filename	capability.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Capability discovery of water meter instance
+ * @comment		--
+ */
+
+package watermeter
+
+import (
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/cjt188"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+/*
+get capabilities of the meter instance, the instance needs to be initialized in advance
+
+# Returns
+
+caps meter.Capabilities: supported data items and valves
+*/
+func (wm *WaterMeter) GetCapabilities() (caps meter.Capabilities) {
+	caps.Kind = meter.METER_KIND_WATER
+	if wm.modelMeta == nil {
+		return
+	}
+	caps.Model = wm.model
+	caps.ModelName = wm.modelMeta.name
+	caps.Protocol = wm.modelMeta.protocol
+	if wm.isCJT188() {
+		for id := 0; id < ID_DATA_ITEM_AMOUNT__; id++ {
+			scale, ok := cjtItemScale[uint8(id)]
+			if !ok {
+				continue
+			}
+			caps.Items = append(caps.Items, meter.ItemCapability{
+				ID:       uint8(id),
+				Name:     itemTable[id].Name,
+				Unit:     itemTable[id].Unit,
+				Kind:     itemTable[id].Kind,
+				Scale:    scale,
+				Rollover: meter.Rollover(itemTable[id].Kind, cjt188.COUNTER_SPAN),
+				Readable: true,
+			})
+		}
+		caps.ActuatorAmount = wm.modelMeta.cjtValveAmount
+		return
+	}
+	if wm.isMBus() {
+		for id := 0; id < ID_DATA_ITEM_AMOUNT__; id++ {
+			if _, ok := mbusItemQuantity[uint8(id)]; !ok {
+				continue
+			}
+			// scale is given by VIF of each record, unknown until read
+			caps.Items = append(caps.Items, meter.ItemCapability{
+				ID:       uint8(id),
+				Name:     itemTable[id].Name,
+				Unit:     itemTable[id].Unit,
+				Kind:     itemTable[id].Kind,
+				Readable: true,
+			})
+		}
+		return
+	}
+	for id := 0; id < len(wm.regMeta) && id < ID_DATA_ITEM_AMOUNT__; id++ {
+		if wm.regMeta[id].length == 0 {
+			continue
+		}
+		caps.Items = append(caps.Items, meter.ItemCapability{
+			ID:       uint8(id),
+			Name:     itemTable[id].Name,
+			Unit:     itemTable[id].Unit,
+			Kind:     itemTable[id].Kind,
+			Scale:    regio.Scale(wm.regMeta[id].override),
+			Signed:   wm.regMeta[id].hasSymbol,
+			Readable: wm.regMeta[id].readable,
+			Writable: wm.regMeta[id].writable,
+			Rollover: meter.Rollover(itemTable[id].Kind, regio.Span(wm.regMeta[id].reg())),
+		})
+	}
+	caps.ActuatorAmount = len(wm.valveMeta)
+	return
+}
+
+/*
+get fingerprints of the Modbus-RTU water meter models, used to identify meters by gateway.Scan
+
+# Returns
+
+fps []gateway.Fingerprint: fingerprints of all Modbus-RTU models
+*/
+func Fingerprints() (fps []gateway.Fingerprint) {
+	fps = append(fps, modelFingerprints(METER_MODEL_HYLSY, &modelMetaHYLSY)...)
+	return
+}
+
+// build fingerprints from plausible item ranges of a meter model
+func modelFingerprints(model uint8, modelMeta *ModelMeta) (fps []gateway.Fingerprint) {
+	for _, fm := range modelMeta.fingerprints {
+		if int(fm.id) >= len(modelMeta.regMeta) || modelMeta.regMeta[fm.id].length == 0 {
+			continue
+		}
+		fps = append(fps, regio.Fingerprint(meter.METER_KIND_WATER, model, modelMeta.name, modelMeta.regMeta[fm.id].reg(), fm.min, fm.max))
+	}
+	return
+}
@@ -0,0 +1,84 @@
+package watermeter
+
+import (
+	"fmt"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/cjt188"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+// items picked from metering data of CJ/T 188 meters, with their scale
+var cjtItemScale = map[uint8]float64{
+	ID_VOLUME:      0.01,
+	ID_BATTERY_LOW: 1,
+	ID_VALVE_FAULT: 1,
+}
+
+var modelMetaCJT188 = ModelMeta{
+	name:           "CJ/T 188",
+	protocol:       meter.PROTOCOL_CJT188,
+	cjtType:        cjt188.TYPE_WATER_COLD,
+	cjtValveAmount: 1,
+}
+
+// if meter speaks CJ/T 188 rather than Modbus-RTU
+func (wm *WaterMeter) isCJT188() bool {
+	return wm.modelMeta != nil && wm.modelMeta.protocol == meter.PROTOCOL_CJT188
+}
+
+// GetVal of CJ/T 188 meters, items are picked from metering data
+func (wm *WaterMeter) getValCJT188(id uint8) (ret float64, err error) {
+	var wd cjt188.WaterData
+	switch id {
+	case ID_VOLUME:
+		wd, err = wm.cjt.ReadWaterData()
+		ret = wd.Volume
+	case ID_BATTERY_LOW:
+		wd, err = wm.cjt.ReadWaterData()
+		if wd.Status&cjt188.STATUS_BATTERY_LOW != 0 {
+			ret = 1
+		}
+	case ID_VALVE_FAULT:
+		wd, err = wm.cjt.ReadWaterData()
+		if uint8(wd.Status&cjt188.STATUS_VALVE_MASK) == cjt188.VALVE_STAT_ABNORMAL {
+			ret = 1
+		}
+	default:
+		err = fmt.Errorf("undefined data item %s of CJ/T 188 meter", ItemName(id))
+	}
+	return
+}
+
+// GetValveState of CJ/T 188 meters, by valve bits of status word
+func (wm *WaterMeter) getValveStateCJT188(turn uint8) (state uint8, err error) {
+	if int(turn) >= wm.modelMeta.cjtValveAmount {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	}
+	var wd cjt188.WaterData
+	wd, err = wm.cjt.ReadWaterData()
+	if err != nil {
+		return
+	}
+	switch uint8(wd.Status & cjt188.STATUS_VALVE_MASK) {
+	case cjt188.VALVE_STAT_OPENED:
+		state = VALVE_STAT_OPENED
+	case cjt188.VALVE_STAT_CLOSED:
+		state = VALVE_STAT_CLOSED
+	case cjt188.VALVE_STAT_ABNORMAL:
+		state = VALVE_STAT_FAULT
+	default:
+		err = fmt.Errorf("abnormal valve status 0x%04x", wd.Status)
+	}
+	return
+}
+
+// valve command of CJ/T 188 meters
+func (wm *WaterMeter) setValveCJT188(turn uint8, stat bool) (err error) {
+	if int(turn) >= wm.modelMeta.cjtValveAmount {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	}
+	err = wm.cjt.SetValve(stat)
+	return
+}
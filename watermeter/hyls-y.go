@@ -1,11 +1,14 @@
 package watermeter
 
+import "github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+
 // (23/07/2024 kontornl) may use const here, need inspection
 // register metadata of HYLS-Y oredered by item ID, such as ID_VOLUME
 var regMetaHYLSY = []RegMeta{
 	{
 		regAddr:   0x0000,
 		length:    2,
+		regType:   REGTYPE_HOLDING,
 		readable:  true,
 		writable:  false,
 		hasSymbol: false,
@@ -25,3 +28,13 @@ var valveMetaHYLSY = []ValveMeta{
 		statusOpenVal:  0x0001,
 	},
 }
+
+var modelMetaHYLSY = ModelMeta{
+	name:      "HYLS-Y",
+	protocol:  meter.PROTOCOL_MODBUS_RTU,
+	regMeta:   regMetaHYLSY,
+	valveMeta: valveMetaHYLSY,
+	fingerprints: []fingerprintMeta{
+		{id: ID_VOLUME, min: 0, max: 999999.99},
+	},
+}
@@ -0,0 +1,72 @@
+/*
+ * @filename	items.go
+ * @author		kontornl
+ * @date		18/10/2026
+ * @desc		Names, units and descriptions of water meter data items
+ * @comment		--
+ */
+
+package watermeter
+
+import "github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+
+// description of data items ordered by item ID, such as ID_VOLUME
+var itemTable = meter.ItemTable{
+	ID_VOLUME:         {Name: "volume", Unit: "m^3", Kind: meter.KIND_VOLUME, Desc: "indicating number of water volume"},
+	ID_FLOW:           {Name: "flow", Unit: "m^3/h", Kind: meter.KIND_FLOW, Desc: "instantaneous flow"},
+	ID_VOLUME_REVERSE: {Name: "volume_reverse", Unit: "m^3", Kind: meter.KIND_VOLUME, Desc: "cumulative volume of reverse flow"},
+	ID_BATTERY_LOW:    {Name: "battery_low", Unit: "", Kind: meter.KIND_STATUS, Desc: "battery low alarm"},
+	ID_VALVE_FAULT:    {Name: "valve_fault", Unit: "", Kind: meter.KIND_STATUS, Desc: "valve stuck or abnormal status"},
+}
+
+/*
+get description of data item
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+info meter.ItemInfo: item name, unit, quantity kind and description
+
+err error: error if item id is undefined
+*/
+func GetItemInfo(id uint8) (info meter.ItemInfo, err error) {
+	info, err = itemTable.Info(id)
+	return
+}
+
+/*
+format item id into its stable name, such as "volume"
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+name string: item name, or "item#<id>" if item id is undefined
+*/
+func ItemName(id uint8) (name string) {
+	name = itemTable.Name(id)
+	return
+}
+
+/*
+parse item name into item id, case-insensitive
+
+# Params
+
+name string: item name, such as "volume"
+
+# Returns
+
+id uint8: item id, one of macro ID_*
+
+err error: error if name matches no item
+*/
+func ParseItem(name string) (id uint8, err error) {
+	id, err = itemTable.Parse(name)
+	return
+}
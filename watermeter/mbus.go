@@ -0,0 +1,66 @@
+package watermeter
+
+import (
+	"fmt"
+
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/mbus"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
+)
+
+var modelMetaMBus = ModelMeta{
+	name:     "M-Bus",
+	protocol: meter.PROTOCOL_MBUS,
+}
+
+// record quantities of M-Bus variable data holding items, ordered by item ID
+var mbusItemQuantity = map[uint8]uint8{
+	ID_VOLUME:         mbus.QUANTITY_VOLUME,
+	ID_FLOW:           mbus.QUANTITY_VOLUME_FLOW,
+	ID_VOLUME_REVERSE: mbus.QUANTITY_VOLUME,
+}
+
+// factors converting M-Bus flow units into m^3/h
+var mbusFlowFactor = map[string]float64{
+	"m^3/h":   1,
+	"m^3/min": 60,
+	"m^3/s":   3600,
+}
+
+// if meter is read over M-Bus rather than Modbus-RTU
+func (wm *WaterMeter) isMBus() bool {
+	return wm.modelMeta != nil && wm.modelMeta.protocol == meter.PROTOCOL_MBUS
+}
+
+// GetVal of M-Bus meters, items are picked from current records of variable data
+func (wm *WaterMeter) getValMBus(id uint8) (ret float64, err error) {
+	quantity, ok := mbusItemQuantity[id]
+	if !ok {
+		err = fmt.Errorf("undefined data item %s of M-Bus meter", ItemName(id))
+		return
+	}
+	var vd mbus.VariableData
+	vd, err = wm.mbus.ReadData()
+	if err != nil {
+		return
+	}
+	find := vd.Find
+	if id == ID_VOLUME_REVERSE {
+		// volume record accumulated only on backward flow
+		find = vd.FindBackward
+	}
+	rec, ok := find(quantity)
+	if !ok {
+		err = fmt.Errorf("no record of item %s in M-Bus data", ItemName(id))
+		return
+	}
+	ret = rec.Value
+	if quantity == mbus.QUANTITY_VOLUME_FLOW {
+		factor, ok := mbusFlowFactor[rec.Unit]
+		if !ok {
+			err = fmt.Errorf("unknown flow unit %s in M-Bus data", rec.Unit)
+			return
+		}
+		ret *= factor
+	}
+	return
+}
@@ -11,11 +11,14 @@ package watermeter
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/cjt188"
 	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/gateway"
-
-	"github.com/kontornl/modbus"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/internal/regio"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/mbus"
+	"github.com/The-Healthist/meter_reader_meterdrv_mbrt/meter"
 )
 
 // data item identifiers in serial form
@@ -23,6 +26,15 @@ import (
 const (
 	// indicating number of water volume, in m^3
 	ID_VOLUME = iota
+	// instantaneous flow, in m^3/h
+	ID_FLOW
+	// cumulative volume of reverse flow, in m^3
+	ID_VOLUME_REVERSE
+	// battery low alarm, 1 if the meter reports its battery running low
+	ID_BATTERY_LOW
+	// valve fault, 1 if the valve is stuck or reports abnormal status
+	ID_VALVE_FAULT
+
 	// (reserved) ID amount counter, must be at the end
 	ID_DATA_ITEM_AMOUNT__
 )
@@ -34,20 +46,64 @@ const (
 	VALVE_TURN_4
 )
 
+// valve states reported by GetValveState
+const (
+	VALVE_STAT_CLOSED uint8 = iota
+	VALVE_STAT_OPENED
+	// valve travelling towards opened
+	VALVE_STAT_OPENING
+	// valve travelling towards closed
+	VALVE_STAT_CLOSING
+	// valve stuck, motor fault or abnormal status
+	VALVE_STAT_FAULT
+
+	// (reserved) valve state amount counter, must be at the end
+	VALVE_STAT_AMOUNT__
+)
+
+var valveStateNames = [VALVE_STAT_AMOUNT__]string{
+	VALVE_STAT_CLOSED:  "closed",
+	VALVE_STAT_OPENED:  "opened",
+	VALVE_STAT_OPENING: "opening",
+	VALVE_STAT_CLOSING: "closing",
+	VALVE_STAT_FAULT:   "fault",
+}
+
 // meter model identifiers
 const (
 	METER_MODEL_HYLSY uint8 = iota
+	// generic cold water meter speaking CJ/T 188
+	METER_MODEL_CJT188
+	// generic water meter on wired M-Bus (EN 13757)
+	METER_MODEL_MBUS
 )
 
 // Modbus-RTU register type identifiers
 const (
-	REGTYPE_COIL = iota
-	REGTYPE_INPUT
-	REGTYPE_HOLDING
+	REGTYPE_COIL     = regio.REGTYPE_COIL
+	REGTYPE_INPUT    = regio.REGTYPE_INPUT
+	REGTYPE_HOLDING  = regio.REGTYPE_HOLDING
+	REGTYPE_DISCRETE = regio.REGTYPE_DISCRETE
 )
 
+// retry policy of water meters, which need a longer rest between requests
+var retryPolicy = regio.RetryPolicy{
+	Retries:     3,
+	Delay:       50 * time.Millisecond,
+	SettleDelay: 200 * time.Millisecond,
+}
+
+// number of retries of valve commands
+const valveCmdRetries = 30
+
+// default policy of waiting for valves, motorised valves take 10 - 30 s to travel
+var valvePolicy = meter.ActuationPolicy{
+	Timeout:  30 * time.Second,
+	Interval: 500 * time.Millisecond,
+}
+
 /*
-initialize power meter instance
+initialize water meter instance
 
 # Params
 
@@ -55,28 +111,86 @@ gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, an
 
 meterModel uint8: meter model id, using macro METER_MODEL_*
 
-slaveAddr uint8: Modbus-RTU address of the meter
+slaveAddr uint8: Modbus-RTU address of the meter, or CJ/T 188 address in decimal for CJ/T 188 meters
 
 # Returns
 
 err error: error
 */
 func (wm *WaterMeter) Init(gw *gateway.MBRTGateway, meterModel uint8, slaveAddr uint8) (err error) {
-	if slaveAddr > 60 {
-		err = errors.New("invalid slave address which exceeds 60")
+	err = wm.InitWithAddr(gw, meterModel, strconv.Itoa(int(slaveAddr)))
+	return
+}
+
+/*
+initialize water meter instance with address in protocol format
+
+# Params
+
+gw *gateway.MBRTGateway: the gateway instance that meter actually connect to, and needs to be initialized in advance
+
+meterModel uint8: meter model id, using macro METER_MODEL_*
+
+addr string: Modbus-RTU address in decimal such as "21", CJ/T 188 address up to 14 digits such as "00000012345678",
+or M-Bus primary address such as "5" or 8 digit secondary address such as "12345678"
+
+# Returns
+
+err error: error
+*/
+func (wm *WaterMeter) InitWithAddr(gw *gateway.MBRTGateway, meterModel uint8, addr string) (err error) {
+	var modelMeta *ModelMeta
+	switch meterModel {
+	case METER_MODEL_HYLSY:
+		modelMeta = &modelMetaHYLSY
+	case METER_MODEL_CJT188:
+		modelMeta = &modelMetaCJT188
+	case METER_MODEL_MBUS:
+		modelMeta = &modelMetaMBus
+	default:
+		// invalid meter model
+		err = errors.New("invalid meter type")
 		return
 	}
-	if meterModel == METER_MODEL_HYLSY {
-		wm.regMeta = regMetaHYLSY
-		wm.valveMeta = valveMetaHYLSY
+	var slaveAddr uint8
+	if modelMeta.protocol == meter.PROTOCOL_CJT188 {
+		var cjtAddr [7]byte
+		cjtAddr, err = cjt188.ParseAddr(addr)
+		if err != nil {
+			return
+		}
+		err = wm.cjt.Init(gw, modelMeta.cjtType, cjtAddr)
+		if err != nil {
+			return
+		}
+		addr = cjt188.FormatAddr(cjtAddr)
+	} else if modelMeta.protocol == meter.PROTOCOL_MBUS {
+		err = wm.mbus.Init(gw, addr)
+		if err != nil {
+			return
+		}
+	} else {
+		slaveAddr, err = meter.ParseSlaveAddr(addr, modelMeta.maxSlaveAddr)
+		if err != nil {
+			return
+		}
+		err = wm.engine.Init(gw, slaveAddr, retryPolicy)
+		if err != nil {
+			return
+		}
 	}
+	wm.model = meterModel
+	wm.modelMeta = modelMeta
+	wm.regMeta = modelMeta.regMeta
+	wm.valveMeta = modelMeta.valveMeta
 	wm.gateway = gw
 	wm.slaveAddr = slaveAddr
+	wm.addr = addr
 	return
 }
 
 /*
-get values such as water volume
+get values such as water volume, flow or alarms
 
 # Params
 
@@ -84,167 +198,333 @@ id uint8: item id, specifies which value should be fetched, using macro ID_*
 
 # Returns
 
-ret float64: value in float64, the unit might be one of the following: m^3
+ret float64: value in float64, the unit might be one of the following: m^3, m^3/h, V, or 1/0 for alarms
 
 err error: error
 */
 func (wm *WaterMeter) GetVal(id uint8) (ret float64, err error) {
-	var regval []uint16
-	ret = 0.0
-	time.Sleep(50 * time.Millisecond)
-	if wm.gateway.GetClient() == nil {
-		wm.gateway.Reinit()
+	if wm.isCJT188() {
+		ret, err = wm.getValCJT188(id)
+		return
 	}
-	wm.gateway.GetClient().SetUnitId(wm.slaveAddr)
-	if wm.regMeta[id].length == 0 {
-		err = errors.New("undefined register metadata")
+	if wm.isMBus() {
+		ret, err = wm.getValMBus(id)
 		return
 	}
-	if !wm.regMeta[id].readable {
-		err = errors.New("unreadable register")
+	if int(id) >= len(wm.regMeta) || wm.regMeta[id].length == 0 {
+		err = fmt.Errorf("undefined register metadata of item %s", ItemName(id))
 		return
 	}
-	for retry := 3; ; retry-- {
-		regval, err = wm.gateway.GetClient().ReadRegisters(
-			wm.regMeta[id].regAddr,
-			wm.regMeta[id].length,
-			modbus.HOLDING_REGISTER,
-		)
-		if err != nil {
-			if retry > 0 {
-				err = wm.gateway.Reconnect()
-			}
-			if err != nil {
-				return
-			}
-		} else {
-			break
-		}
+	if !wm.regMeta[id].readable {
+		err = fmt.Errorf("unreadable register of item %s", ItemName(id))
+		return
 	}
-	for i := 0; i < len(regval); i++ {
-		ret *= 65536
-		ret += float64(regval[i])
+	ret, err = wm.engine.Read(wm.regMeta[id].reg())
+	return
+}
+
+/*
+fetch valve status
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+# Returns
+
+stat bool: valve status, true is opened (turned on), false is closed (turned off)
+
+err error: error, also if the valve is travelling or in fault, see GetValveState
+*/
+func (wm *WaterMeter) GetValve(turn uint8) (stat bool, err error) {
+	var state uint8
+	state, err = wm.GetValveState(turn)
+	if err != nil {
+		return
 	}
-	ret *= float64(wm.regMeta[id].override)
-	if wm.regMeta[id].hasSymbol && (regval[0]/32768 == 1) {
-		ret *= -1
+	switch state {
+	case VALVE_STAT_OPENED:
+		stat = true
+	case VALVE_STAT_CLOSED:
+		stat = false
+	default:
+		err = fmt.Errorf("valve is %s", ValveStateName(state))
 	}
 	return
 }
 
 /*
-fetch valve status
+fetch valve state, telling travelling and fault states apart from opened and closed
 
 # Params
 
-turn uint8: which switch should be operated, using macro POWERSWITCH_TURN_*
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
 
 # Returns
 
-stat bool: switch status, true is opened (turned on), false is closed (turned off)
+state uint8: valve state, using macro VALVE_STAT_*
 
 err error: error
 */
-func (wm *WaterMeter) GetValve(turn uint8) (stat bool, err error) {
-	var regval uint16
-	stat = false
-	if wm.gateway.GetClient() == nil {
-		wm.gateway.Reinit()
+func (wm *WaterMeter) GetValveState(turn uint8) (state uint8, err error) {
+	if wm.isCJT188() {
+		state, err = wm.getValveStateCJT188(turn)
+		return
 	}
-	wm.gateway.GetClient().SetUnitId(wm.slaveAddr)
-	time.Sleep(50 * time.Millisecond)
-	// (23/07/2024 kontornl) the register may just a coil, not a holding register
-	for retry := 3; ; retry-- {
-		if wm.valveMeta[turn].statusRegType == REGTYPE_COIL {
-			stat, err = wm.gateway.GetClient().ReadCoil(wm.valveMeta[turn].statusAddr)
-		} else if wm.valveMeta[turn].statusRegType == REGTYPE_HOLDING {
-			regval, err = wm.gateway.GetClient().ReadRegister(wm.valveMeta[turn].statusAddr, modbus.HOLDING_REGISTER)
-			if regval == wm.valveMeta[turn].statusCloseVal {
-				stat = false
-			} else if regval == wm.valveMeta[turn].statusOpenVal {
-				stat = true
-			} else {
-				err = fmt.Errorf("bad register value 0x%04x", regval)
-			}
-		} else {
-			err = errors.New("invalid register type")
+	if wm.isMBus() {
+		err = errors.New("M-Bus meter has no valve")
+		return
+	}
+	if int(turn) >= len(wm.valveMeta) {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	}
+	vm := &wm.valveMeta[turn]
+	switch vm.statusRegType {
+	case REGTYPE_COIL, REGTYPE_DISCRETE:
+		// (23/07/2024 kontornl) the register may just a coil, not a holding register
+		var on bool
+		on, err = wm.engine.ReadBool(vm.statusAddr, vm.statusRegType)
+		if err != nil {
 			return
 		}
+		state = VALVE_STAT_CLOSED
+		if on {
+			state = VALVE_STAT_OPENED
+		}
+	case REGTYPE_HOLDING, REGTYPE_INPUT:
+		var regval []uint16
+		regval, err = wm.engine.ReadRaw(vm.statusAddr, 1, vm.statusRegType)
 		if err != nil {
-			if retry > 0 {
-				err = wm.gateway.Reconnect()
-			}
-			if err != nil {
-				return
-			}
-		} else {
-			break
+			return
 		}
+		state, err = vm.state(regval[0])
+	default:
+		err = errors.New("invalid register type")
 	}
 	return
 }
 
 /*
-valve action (open or close) command
+check if a valve reports its travel, so that a valve still reading its former state while actuated is seized rather than moving
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+# Returns
+
+travel bool: true if the status register tells opening or closing apart from opened and closed
+*/
+func (wm *WaterMeter) ValveReportsTravel(turn uint8) (travel bool) {
+	if wm.isCJT188() || wm.isMBus() || int(turn) >= len(wm.valveMeta) {
+		return false
+	}
+	return wm.valveMeta[turn].reportsTravel()
+}
+
+/*
+valve action (open or close) command, waiting until the valve reaches the state, see ActuateValve
 
 # Params
 
-turn uint8: which switch should be operated, give operand using macro such as POWERSWITCH_TURN_1
+turn uint8: which valve should be operated, give operand using macro such as VALVE_TURN_1
 
-stat bool: switch status, true is opened (turned on), false is closed (turned off)
+stat bool: valve status, true is opened (turned on), false is closed (turned off)
 
 # Returns
 
 err error: error
 */
 func (wm *WaterMeter) SetValve(turn uint8, stat bool) (err error) {
-	time.Sleep(50 * time.Millisecond)
-	if wm.gateway.GetClient() == nil {
-		wm.gateway.Reinit()
-	}
-	wm.gateway.GetClient().SetUnitId(wm.slaveAddr)
-	for retry := 30; ; retry-- {
-		if wm.valveMeta[turn].statusRegType == REGTYPE_COIL {
-			err = wm.gateway.GetClient().WriteCoil(
-				wm.valveMeta[turn].ctlAddr,
-				stat,
-			)
-		} else if wm.valveMeta[turn].statusRegType == REGTYPE_HOLDING {
-			var cmd uint16
-			if stat {
-				cmd = wm.valveMeta[turn].ctlOpenCmd
-			} else {
-				cmd = wm.valveMeta[turn].ctlCloseCmd
-			}
-			err = wm.gateway.GetClient().WriteRegisters(
-				wm.valveMeta[turn].ctlAddr,
-				[]uint16{cmd},
-			)
-		} else {
-			err = errors.New("invalid register type")
-			return
+	_, err = wm.ActuateValve(turn, stat, nil)
+	return
+}
+
+/*
+valve action (open or close) command, polling valve state until it reaches the target, reports fault, or times out
+
+# Params
+
+turn uint8: which valve should be operated, give operand using macro such as VALVE_TURN_1
+
+stat bool: valve status, true is opened (turned on), false is closed (turned off)
+
+progress func(a meter.Actuation) (stop bool): called after each poll with intermediate state, return true to stop waiting, could be nil
+
+# Returns
+
+a meter.Actuation: last valve state with macro VALVE_STAT_*, phase and elapsed actuation time
+
+err error: error sending the command, or meter.ErrActuation* if the valve did not complete
+*/
+func (wm *WaterMeter) ActuateValve(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error) {
+	if wm.isCJT188() {
+		err = wm.setValveCJT188(turn, stat)
+	} else if wm.isMBus() {
+		err = errors.New("M-Bus meter has no valve")
+		return
+	} else if int(turn) >= len(wm.valveMeta) {
+		err = fmt.Errorf("invalid valve turn %d", turn)
+		return
+	} else if wm.valveMeta[turn].ctlRegType == REGTYPE_COIL {
+		err = wm.engine.WriteBool(wm.valveMeta[turn].ctlAddr, stat, valveCmdRetries)
+	} else if wm.valveMeta[turn].ctlRegType == REGTYPE_HOLDING {
+		cmd := wm.valveMeta[turn].ctlCloseCmd
+		if stat {
+			cmd = wm.valveMeta[turn].ctlOpenCmd
 		}
-		if err != nil {
-			if retry > 0 {
-				err = wm.gateway.Reconnect()
-			}
-			if err != nil {
-				return
-			}
+		err = wm.engine.WriteRaw(wm.valveMeta[turn].ctlAddr, []uint16{cmd}, false, valveCmdRetries)
+	} else {
+		err = errors.New("invalid register type")
+	}
+	if err != nil {
+		return
+	}
+	a, err = meter.WaitActuation(stat, wm.GetActuationPolicy(), func() (phase uint8, state uint8, err error) {
+		state, err = wm.GetValveState(turn)
+		phase = valvePhase(state, stat)
+		return
+	}, progress)
+	return
+}
+
+/*
+get policy of waiting for valve actions
+
+# Returns
+
+policy meter.ActuationPolicy: policy set by SetActuationPolicy, or the default of 30 s timeout polling every 500 ms
+*/
+func (wm *WaterMeter) GetActuationPolicy() (policy meter.ActuationPolicy) {
+	policy = wm.actuationPolicy
+	if policy == (meter.ActuationPolicy{}) {
+		policy = valvePolicy
+	}
+	return
+}
+
+/*
+set policy of waiting for valve actions, such as a longer timeout for slow valves
+
+# Params
+
+policy meter.ActuationPolicy: timeout and poll interval, zero value for default
+*/
+func (wm *WaterMeter) SetActuationPolicy(policy meter.ActuationPolicy) {
+	wm.actuationPolicy = policy
+}
+
+// judge valve state against target state
+func valvePhase(state uint8, target bool) (phase uint8) {
+	switch state {
+	case VALVE_STAT_OPENED, VALVE_STAT_CLOSED:
+		if (state == VALVE_STAT_OPENED) == target {
+			phase = meter.ACTUATION_DONE
 		} else {
-			time.Sleep(200 * time.Millisecond)
-			break
+			phase = meter.ACTUATION_STALLED
 		}
+	case VALVE_STAT_OPENING, VALVE_STAT_CLOSING:
+		phase = meter.ACTUATION_MOVING
+	default:
+		phase = meter.ACTUATION_FAULT
 	}
-	// time.Sleep(6 * time.Second)
-	var newstat bool
-	newstat, err = wm.GetValve(turn)
-	if err != nil {
+	return
+}
+
+/*
+format valve state into its name, such as "opening"
+
+# Params
+
+state uint8: valve state, using macro VALVE_STAT_*
+
+# Returns
+
+name string: state name, or "state#<state>" if state is undefined
+*/
+func ValveStateName(state uint8) (name string) {
+	if state >= VALVE_STAT_AMOUNT__ {
+		name = fmt.Sprintf("state#%d", state)
 		return
 	}
-	if stat != newstat {
-		err = errors.New("water switch status mismatch")
+	name = valveStateNames[state]
+	return
+}
+
+/*
+get identity of the meter instance
+
+# Returns
+
+ident meter.Identity: meter kind, model, gateway address and slave address
+*/
+func (wm *WaterMeter) GetIdentity() (ident meter.Identity) {
+	ident.Kind = meter.METER_KIND_WATER
+	ident.Model = wm.model
+	if wm.modelMeta != nil {
+		ident.ModelName = wm.modelMeta.name
+		ident.Protocol = wm.modelMeta.protocol
+	}
+	if wm.gateway != nil {
+		ident.NetAddr = wm.gateway.GetNetAddr()
 	}
+	ident.SlaveAddr = wm.slaveAddr
+	ident.Addr = wm.addr
+	return
+}
+
+/*
+get description of data item, same as GetItemInfo of the package
+
+# Params
+
+id uint8: item id, using macro ID_*
+
+# Returns
+
+info meter.ItemInfo: item name, unit, quantity kind and description
+
+err error: error if item id is undefined
+*/
+func (wm *WaterMeter) GetItemInfo(id uint8) (info meter.ItemInfo, err error) {
+	info, err = GetItemInfo(id)
+	return
+}
+
+/*
+fetch actuator status, implementing meter.Meter, same as GetValve
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+# Returns
+
+on bool: true is opened (turned on), false is closed (turned off)
+
+err error: error
+*/
+func (wm *WaterMeter) GetActuator(turn uint8) (on bool, err error) {
+	on, err = wm.GetValve(turn)
+	return
+}
+
+/*
+actuator command, implementing meter.Meter, same as SetValve
+
+# Params
+
+turn uint8: which valve should be operated, using macro VALVE_TURN_*
+
+on bool: true to open (turn on), false to close (turn off)
+
+# Returns
+
+err error: error
+*/
+func (wm *WaterMeter) SetActuator(turn uint8, on bool) (err error) {
+	err = wm.SetValve(turn, on)
 	return
 }
 
@@ -254,6 +534,8 @@ type RegMeta struct {
 	regAddr uint16
 	// number of successive registers used to hold one value
 	length uint16
+	// register type (holding or input)
+	regType uint8
 	// if register readable
 	readable bool
 	// if register writable
@@ -262,13 +544,28 @@ type RegMeta struct {
 	hasSymbol bool
 	// a value multiplied onto the original value from the register
 	override float32
+	// if not 0, the item is a flag of these bits in the register
+	bitMask uint16
+}
+
+// register description used by the register access engine
+func (rm *RegMeta) reg() (reg regio.Reg) {
+	reg = regio.Reg{
+		Addr:    rm.regAddr,
+		Length:  rm.length,
+		Type:    rm.regType,
+		Signed:  rm.hasSymbol,
+		Scale:   regio.Scale(rm.override),
+		BitMask: rm.bitMask,
+	}
+	return
 }
 
 // metadata of valve
 type ValveMeta struct {
 	// valve controlling register address
 	ctlAddr uint16
-	// valve controlling register type (coil or holding)
+	// valve controlling register type (coil or holding), independent of status register type
 	ctlRegType uint8
 	// valve close command to write to register
 	ctlCloseCmd uint16
@@ -276,24 +573,95 @@ type ValveMeta struct {
 	ctlOpenCmd uint16
 	// valve status register address
 	statusAddr uint16
-	// valve status register type (coil or holding)
+	// valve status register type (coil, discrete input, input or holding)
 	statusRegType uint8
 	// value indicates that valve is closed
 	statusCloseVal uint16
 	// value indicates that valve is opened
 	statusOpenVal uint16
+	// other status values and the states they indicate, such as opening, closing or fault, input or holding only
+	statusStates map[uint16]uint8
+}
+
+// valve state indicated by value of status register, input or holding only
+func (vm *ValveMeta) state(regval uint16) (state uint8, err error) {
+	if regval == vm.statusCloseVal {
+		state = VALVE_STAT_CLOSED
+	} else if regval == vm.statusOpenVal {
+		state = VALVE_STAT_OPENED
+	} else if s, ok := vm.statusStates[regval]; ok {
+		state = s
+	} else {
+		err = fmt.Errorf("bad register value 0x%04x", regval)
+	}
+	return
+}
+
+// if status register has travel states, input or holding only
+func (vm *ValveMeta) reportsTravel() (travel bool) {
+	for _, s := range vm.statusStates {
+		if s == VALVE_STAT_OPENING || s == VALVE_STAT_CLOSING {
+			return true
+		}
+	}
+	return false
+}
+
+// plausible range of an item, used as fingerprint of meter model when scanning the bus
+type fingerprintMeta struct {
+	// item id, using macro ID_*
+	id uint8
+	// lowest plausible value
+	min float64
+	// highest plausible value
+	max float64
+}
+
+// metadata of meter model, collecting register and valve descriptions
+type ModelMeta struct {
+	// human readable model name
+	name string
+	// communication protocol, using macro meter.PROTOCOL_*
+	protocol uint8
+	// register metadata ordered by item ID, Modbus-RTU only
+	regMeta []RegMeta
+	// valve metadata ordered by turn, Modbus-RTU only
+	valveMeta []ValveMeta
+	// meter type of frames, CJ/T 188 only
+	cjtType byte
+	// number of valves, CJ/T 188 only
+	cjtValveAmount int
+	// highest slave address the model accepts, 0 for protocol limit of 247, Modbus-RTU only
+	maxSlaveAddr uint8
+	// items with plausible ranges identifying the model on bus scan, Modbus-RTU only
+	fingerprints []fingerprintMeta
 }
 
 type WaterMeter struct {
 	gateway   *gateway.MBRTGateway
+	engine    regio.Engine
+	cjt       cjt188.Client
+	mbus      mbus.Client
 	slaveAddr uint8
+	addr      string
+	model     uint8
+	modelMeta *ModelMeta
 	regMeta   []RegMeta
 	valveMeta []ValveMeta
+	// policy of waiting for valve actions, zero value for valvePolicy
+	actuationPolicy meter.ActuationPolicy
 }
 
 type IWaterMeter interface {
 	Init(gw *gateway.MBRTGateway, meterModel uint8, slaveAddr uint8) (err error)
+	InitWithAddr(gw *gateway.MBRTGateway, meterModel uint8, addr string) (err error)
 	GetVal(id uint8) (ret float64, err error)
 	GetValve(turn uint8) (stat bool, err error)
+	GetValveState(turn uint8) (state uint8, err error)
 	SetValve(turn uint8, stat bool) (err error)
+	ActuateValve(turn uint8, stat bool, progress func(a meter.Actuation) (stop bool)) (a meter.Actuation, err error)
+	SetActuationPolicy(policy meter.ActuationPolicy)
+	GetCapabilities() (caps meter.Capabilities)
 }
+
+var _ meter.Meter = (*WaterMeter)(nil)